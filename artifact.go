@@ -0,0 +1,76 @@
+package paxos
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+)
+
+// writeFailure writes the error, the steps and the trace of the failed
+// test case to the directory. Trace is written only if it is not empty,
+// see WithTracing.
+func writeFailure(dir string, tc *TestCase, err error) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := map[string]string{
+		"error.txt":    err.Error() + "\n",
+		"schedule.txt": tc.String() + "\n",
+	}
+	if trace := tc.Trace(); len(trace) > 0 {
+		files["trace.txt"] = strings.Join(trace, "\n") + "\n"
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profile executes the test case again with a CPU profile, and optionally
+// with an execution trace, and writes them and a heap profile after
+// the execution to the directory. Profiles include other workers that
+// are running at the same time.
+func profile(dir string, run Runner, tc *TestCase, execution bool) error {
+	cpu, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	defer cpu.Close()
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		return err
+	}
+	if execution {
+		f, err := os.Create(filepath.Join(dir, "execution.trace"))
+		if err != nil {
+			pprof.StopCPUProfile()
+			return err
+		}
+		defer f.Close()
+		if err := trace.Start(f); err != nil {
+			pprof.StopCPUProfile()
+			return err
+		}
+	}
+	// error is expected, the test case failed before
+	_ = run(&TestCase{gen: tc.gen, states: append([]int(nil), tc.states...)})
+	if execution {
+		trace.Stop()
+	}
+	pprof.StopCPUProfile()
+
+	heap, err := os.Create(filepath.Join(dir, "heap.pprof"))
+	if err != nil {
+		return err
+	}
+	defer heap.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(heap); err != nil {
+		return err
+	}
+	return cpu.Close()
+}
@@ -0,0 +1,55 @@
+package paxos
+
+// NewByzantine wraps a replica that doesn't follow the protocol in the steps
+// where it is marked with ActionByzantine.
+func NewByzantine(p *Paxos) *Byzantine {
+	return &Byzantine{Paxos: p}
+}
+
+// Byzantine replica equivocates: it promises every ballot without reporting
+// its vote, and accepts every value without changing its state. As a result
+// it can be a member of two majorities that chose different values, and
+// classic Paxos doesn't tolerate it.
+type Byzantine struct {
+	*Paxos
+
+	faulty bool
+	out    []Message
+}
+
+var _ Node = (*Byzantine)(nil)
+
+// CloneNode returns a deep copy of the replica.
+func (b *Byzantine) CloneNode() Node {
+	return &Byzantine{Paxos: b.Paxos.Clone(), faulty: b.faulty}
+}
+
+// Act is called by the cluster at the start of every step.
+func (b *Byzantine) Act(actions Actions) {
+	b.faulty = actions.IsByzantine(b.ID)
+}
+
+func (b *Byzantine) Step(m Message) []Message {
+	b.out = b.out[:0]
+	switch {
+	case b.faulty && m.Type == MessagePrepare:
+		b.out = append(b.out, Message{
+			From:   b.ID,
+			To:     m.From,
+			Type:   MessagePromise,
+			Ballot: m.Ballot,
+		})
+	case b.faulty && m.Type == MessageAccept:
+		b.out = append(b.out, Message{
+			From:   b.ID,
+			To:     m.From,
+			Type:   MessageAccepted,
+			Ballot: m.Ballot,
+			Value:  m.Value,
+		})
+	case m.Type != MessageEmpty:
+		b.Paxos.Next(m)
+	}
+	b.out = b.Paxos.ReadMessages(b.out)
+	return b.out
+}
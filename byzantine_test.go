@@ -0,0 +1,47 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestByzantineEquivocation(t *testing.T) {
+	RunExpectFailure(t, Simulate(func(id int, nodes []int) (Node, error) {
+		p, err := NewPaxos(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		return NewByzantine(p), nil
+	}),
+		// replicas 1 and 3 can't reach each other, and both can get a promise
+		// and a vote from replica 2
+		WithExplicitPartitions([][]int{{1, 2}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithByzantine(2),
+		WithSteps(5),
+	)
+}
+
+func TestByzantineActions(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2}}),
+		WithReplicas(1, 2),
+		WithLeaders(1),
+		WithByzantine(2),
+		WithSteps(1),
+	)
+	require.NoError(t, err)
+	var rst []string
+	for tc := gen.Next(); tc != nil; tc = gen.Next() {
+		_, actions := tc.Next()
+		rst = append(rst, actions.String())
+	}
+	require.Equal(t, []string{
+		"Cluster()",
+		"Cluster(leader=1)",
+		"Cluster(byzantine=2)",
+		"Cluster(leader=1,byzantine=2)",
+	}, rst)
+}
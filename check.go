@@ -0,0 +1,359 @@
+package paxos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// Exit codes of CheckMain.
+const (
+	CheckPassed    = 0
+	CheckViolation = 1
+	CheckError     = 2
+)
+
+var models = struct {
+	sync.Mutex
+	runners map[string]Runner
+}{runners: map[string]Runner{}}
+
+// RegisterModel makes the runner available to CheckMain under the name.
+// Panics if the name is registered twice.
+func RegisterModel(name string, run Runner) {
+	models.Lock()
+	defer models.Unlock()
+	if run == nil {
+		panic("paxos: model runner is nil")
+	}
+	if _, exist := models.runners[name]; exist {
+		panic(fmt.Sprintf("paxos: model %s is registered twice", name))
+	}
+	models.runners[name] = run
+}
+
+// Models returns sorted names of the registered models.
+func Models() []string {
+	models.Lock()
+	defer models.Unlock()
+	names := make([]string, 0, len(models.runners))
+	for name := range models.runners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupModel(name string) (Runner, error) {
+	models.Lock()
+	defer models.Unlock()
+	if len(name) == 0 && len(models.runners) == 1 {
+		for _, run := range models.runners {
+			return run, nil
+		}
+	}
+	run, exist := models.runners[name]
+	if !exist {
+		return nil, fmt.Errorf("model %q is not registered", name)
+	}
+	return run, nil
+}
+
+// Check executes test cases as Run does, but outside of go test, so that
+// long-lived jobs are not limited by the timeout of the test binary. Run stops
+// early only when ctx is done. Logs are written to w. Failed test cases are in
+// the returned Report, error is returned if the run couldn't be completed.
+func Check(ctx context.Context, w io.Writer, name string, run Runner, cfg RunConfig, opts ...GenOption) (*Report, error) {
+	var (
+		report *Report
+		t      = &checkT{name: name, ctx: ctx, w: w}
+		done   = make(chan struct{})
+	)
+	cfg.report = func(r *Report) {
+		report = r
+	}
+	cfg.replayHint = func(path string) string {
+		return fmt.Sprintf("Replay failed test cases with the same configuration and -replay=%s", path)
+	}
+	go func() {
+		defer close(done)
+		RunContext(ctx, t, run, cfg, opts...)
+	}()
+	<-done
+	t.cleanup()
+	if t.fatal != nil {
+		return nil, t.fatal
+	}
+	return report, nil
+}
+
+// CheckMain is the main function of a binary that checks registered models,
+// such as cmd/paxos-check. Model is selected with -model, and explored with
+// the generator configuration from the -config file, see LoadConfig. Run is
+// configured with the flags of RegisterFlags. The json Report is written to
+// stdout, and the process exits with CheckViolation if any test case failed.
+// Interrupted run stops gracefully and reports what was executed.
+func CheckMain() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := checkMain(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func checkMain(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		model    = fs.String("model", "", fmt.Sprintf("name of the model, one of %s. optional if a single model is registered", strings.Join(Models(), ", ")))
+		config   = fs.String("config", "", "json file with generator configuration")
+		runFlags = RegisterFlags(fs)
+	)
+	if err := fs.Parse(args); err != nil {
+		return CheckError
+	}
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, err)
+		return CheckError
+	}
+	run, err := lookupModel(*model)
+	if err != nil {
+		return fail(err)
+	}
+	if len(*config) == 0 {
+		return fail(errors.New("-config is required"))
+	}
+	conf, err := LoadConfig(*config)
+	if err != nil {
+		return fail(err)
+	}
+	opts, err := conf.Options()
+	if err != nil {
+		return fail(err)
+	}
+	name := *model
+	if len(name) == 0 {
+		name = Models()[0]
+	}
+	report, err := Check(ctx, stderr, name, run, runFlags(), opts...)
+	if err != nil {
+		return fail(err)
+	}
+	if err := json.NewEncoder(stdout).Encode(report); err != nil {
+		return fail(err)
+	}
+	if len(report.Failures) > 0 {
+		return CheckViolation
+	}
+	return CheckPassed
+}
+
+// checkT is the testing.TB of Check. Every method of testing.TB is implemented,
+// testing.TB is embedded only for its unexported methods and is nil.
+type checkT struct {
+	testing.TB
+	name string
+	ctx  context.Context
+
+	mu       sync.Mutex
+	w        io.Writer
+	failed   bool
+	skipped  bool
+	fatal    error
+	cleanups []func()
+}
+
+func (c *checkT) Name() string {
+	return c.name
+}
+
+func (c *checkT) Helper() {}
+
+func (c *checkT) Context() context.Context {
+	return c.ctx
+}
+
+func (c *checkT) Log(args ...any) {
+	c.log(fmt.Sprintln(args...))
+}
+
+func (c *checkT) Logf(format string, args ...any) {
+	c.log(fmt.Sprintf(format, args...))
+}
+
+func (c *checkT) log(msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	io.WriteString(c.w, msg)
+}
+
+// Output returns a writer for the logs, writes are not split into lines.
+func (c *checkT) Output() io.Writer {
+	return checkOutput{c}
+}
+
+type checkOutput struct {
+	c *checkT
+}
+
+func (o checkOutput) Write(p []byte) (int, error) {
+	o.c.mu.Lock()
+	defer o.c.mu.Unlock()
+	return o.c.w.Write(p)
+}
+
+func (c *checkT) Attr(key, value string) {
+	c.Logf("%s: %s", key, value)
+}
+
+func (c *checkT) Error(args ...any) {
+	c.Log(args...)
+	c.Fail()
+}
+
+func (c *checkT) Errorf(format string, args ...any) {
+	c.Logf(format, args...)
+	c.Fail()
+}
+
+func (c *checkT) Fatal(args ...any) {
+	c.fatalf(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (c *checkT) Fatalf(format string, args ...any) {
+	c.fatalf(fmt.Sprintf(format, args...))
+}
+
+func (c *checkT) fatalf(msg string) {
+	c.log(msg)
+	c.stop(errors.New(msg), false)
+}
+
+func (c *checkT) FailNow() {
+	c.stop(errors.New("run failed"), false)
+}
+
+func (c *checkT) Skip(args ...any) {
+	c.skipf(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (c *checkT) Skipf(format string, args ...any) {
+	c.skipf(fmt.Sprintf(format, args...))
+}
+
+func (c *checkT) SkipNow() {
+	c.skipf("")
+}
+
+func (c *checkT) skipf(msg string) {
+	if len(msg) > 0 {
+		c.log(msg)
+	}
+	c.stop(errors.New("run is skipped"), true)
+}
+
+// stop records the error of Check and stops the goroutine of the run,
+// as testing.T does.
+func (c *checkT) stop(err error, skip bool) {
+	c.mu.Lock()
+	if skip {
+		c.skipped = true
+	} else {
+		c.failed = true
+	}
+	c.fatal = err
+	c.mu.Unlock()
+	runtime.Goexit()
+}
+
+func (c *checkT) Fail() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failed = true
+}
+
+func (c *checkT) Failed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failed
+}
+
+func (c *checkT) Skipped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skipped
+}
+
+// Cleanup registers f to be called after the run, in the reverse order.
+func (c *checkT) Cleanup(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanups = append(c.cleanups, f)
+}
+
+func (c *checkT) cleanup() {
+	for i := len(c.cleanups) - 1; i >= 0; i-- {
+		c.cleanups[i]()
+	}
+	c.cleanups = nil
+}
+
+// TempDir creates a directory that is removed after the run.
+func (c *checkT) TempDir() string {
+	dir, err := os.MkdirTemp("", "paxos-check-")
+	if err != nil {
+		c.Fatalf("can't create a temporary directory: %v", err)
+	}
+	c.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// ArtifactDir returns a temporary directory, artifacts of the failures are
+// written to RunConfig.Dir.
+func (c *checkT) ArtifactDir() string {
+	return c.TempDir()
+}
+
+// Setenv sets the environment variable of the process until the run is finished.
+func (c *checkT) Setenv(key, value string) {
+	prev, exist := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		c.Fatalf("can't set %s: %v", key, err)
+	}
+	c.Cleanup(func() {
+		if exist {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// Chdir changes the working directory of the process until the run is finished.
+func (c *checkT) Chdir(dir string) {
+	prev, err := os.Getwd()
+	if err != nil {
+		c.Fatalf("can't get the working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		c.Fatalf("can't change the working directory: %v", err)
+	}
+	c.Cleanup(func() {
+		os.Chdir(prev)
+	})
+}
@@ -0,0 +1,102 @@
+package paxos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(2),
+	}
+	run := func(tc *TestCase) error {
+		if tc.states[1] == 3 {
+			return errors.New("violation")
+		}
+		return nil
+	}
+	var logs bytes.Buffer
+	report, err := Check(context.Background(), &logs, "model", run, RunConfig{Dir: t.TempDir(), MaxFailures: -1, NoShrink: true}, opts...)
+	require.NoError(t, err)
+	require.Equal(t, "model", report.Name)
+	require.Len(t, report.Failures, 10)
+	require.Equal(t, 90, report.Executed)
+	require.Contains(t, logs.String(), "-replay=")
+
+	_, err = Check(context.Background(), &logs, "model", run, RunConfig{}, WithSteps(2))
+	require.Error(t, err)
+}
+
+func TestCheckMain(t *testing.T) {
+	RegisterModel("check-main", Simulate(paxosFactory, WithValidation()))
+	require.Panics(t, func() {
+		RegisterModel("check-main", func(*TestCase) error { return nil })
+	})
+	require.Contains(t, Models(), "check-main")
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	conf, err := json.Marshal(Config{Replicas: []int{1, 2, 3}, AllPartitions: new(int), Leaders: []int{1}, Steps: 3})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, conf, 0o644))
+
+	var stdout, stderr bytes.Buffer
+	code := checkMain(context.Background(), []string{"-model=check-main", "-config=" + path, "-dir=" + t.TempDir()}, &stdout, &stderr)
+	require.Equal(t, CheckPassed, code, stderr.String())
+	var report Report
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Equal(t, "check-main", report.Name)
+	require.Empty(t, report.Failures)
+	require.Equal(t, report.Considered, report.Executed)
+
+	RegisterModel("check-main-broken", func(*TestCase) error {
+		return errors.New("violation")
+	})
+	stdout.Reset()
+	code = checkMain(context.Background(), []string{"-model=check-main-broken", "-config=" + path, "-dir=" + t.TempDir()}, &stdout, &stderr)
+	require.Equal(t, CheckViolation, code)
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Failures, 1)
+
+	stdout.Reset()
+	code = checkMain(context.Background(), []string{"-model=unknown", "-config=" + path}, &stdout, &stderr)
+	require.Equal(t, CheckError, code)
+	require.Empty(t, stdout.String())
+}
+
+func TestCheckT(t *testing.T) {
+	var (
+		logs bytes.Buffer
+		c    = &checkT{name: "model", ctx: context.Background(), w: &logs}
+		done = make(chan struct{})
+		dir  string
+	)
+	go func() {
+		defer close(done)
+		dir = c.TempDir()
+		c.Setenv("PAXOS_CHECK_T", "1")
+		c.Skip("no test cases")
+		dir = ""
+	}()
+	<-done
+	require.True(t, c.Skipped())
+	require.False(t, c.Failed())
+	require.Error(t, c.fatal)
+	require.DirExists(t, dir)
+	require.Equal(t, "1", os.Getenv("PAXOS_CHECK_T"))
+	require.Equal(t, "no test cases\n", logs.String())
+
+	c.cleanup()
+	require.NoDirExists(t, dir)
+	_, exist := os.LookupEnv("PAXOS_CHECK_T")
+	require.False(t, exist)
+}
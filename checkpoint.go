@@ -0,0 +1,164 @@
+package paxos
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Cloneable is implemented by nodes that can be copied with all their state.
+// Copy must not share mutable state with the original node.
+type Cloneable interface {
+	Node
+	CloneNode() Node
+}
+
+// Clone returns a deep copy of the cluster. Every node must implement Cloneable,
+// and its copy must be of the same type. For example, a node that embeds *Paxos
+// without its own CloneNode is rejected, instead of being replaced by Paxos.
+func (c *Cluster) Clone() (*Cluster, error) {
+	clone := *c
+	clone.nodes = make(map[int]Node, len(c.nodes))
+	for id, node := range c.nodes {
+		cloneable, ok := node.(Cloneable)
+		if !ok {
+			return nil, fmt.Errorf("replica %d of type %T doesn't implement Cloneable", id, node)
+		}
+		copied := cloneable.CloneNode()
+		if reflect.TypeOf(copied) != reflect.TypeOf(node) {
+			return nil, fmt.Errorf("replica %d of type %T is cloned as %T", id, node, copied)
+		}
+		clone.nodes[id] = copied
+	}
+	if c.crashed != nil {
+		clone.crashed = make(map[int][]byte, len(c.crashed))
+		for id, state := range c.crashed {
+			clone.crashed[id] = state
+		}
+	}
+	if c.detectors != nil {
+		clone.detectors = make(map[int]*Omega, len(c.detectors))
+		for id, detector := range c.detectors {
+			clone.detectors[id] = detector.Clone()
+		}
+		clone.terms = make(map[int]int, len(c.terms))
+		for id, term := range c.terms {
+			clone.terms[id] = term
+		}
+	}
+	if c.votes != nil {
+		clone.votes = make(map[vote]map[int]struct{}, len(c.votes))
+		for key, voters := range c.votes {
+			clone.votes[key] = copySet(voters)
+		}
+	}
+	clone.messages = append([]Message(nil), c.messages...)
+	clone.delayed = append([]Message(nil), c.delayed...)
+	clone.replies = nil
+	clone.inflight = append([]inflightMessage(nil), c.inflight...)
+	clone.traced = nil
+	clone.shuffle = nil
+	if c.chosen != nil {
+		clone.chosen = make(map[int]Value, len(c.chosen))
+		for slot, value := range c.chosen {
+			clone.chosen[slot] = value
+		}
+	}
+	if c.states != nil {
+		clone.states = make(map[int][]byte, len(c.states))
+		for slot, state := range c.states {
+			clone.states[slot] = state
+		}
+	}
+	if c.proposed != nil {
+		clone.proposed = make(map[string]struct{}, len(c.proposed))
+		for value := range c.proposed {
+			clone.proposed[value] = struct{}{}
+		}
+	}
+	if c.outcomes != nil {
+		clone.outcomes = make(map[proposal]Outcome, len(c.outcomes))
+		for key, outcome := range c.outcomes {
+			clone.outcomes[key] = outcome
+		}
+	}
+	return &clone, nil
+}
+
+// maxCheckpoints limits memory used by SimulateShared. Once reached all
+// checkpoints are discarded.
+const maxCheckpoints = 1 << 12
+
+// SimulateShared is the same as Simulate, but test cases that share a prefix
+// of steps with previously executed test cases start from a copy of the cluster
+// after that prefix. Product of the steps generates test cases that share
+// all but the last few steps, so most of the steps are not executed again.
+// Every node must implement Cloneable.
+func SimulateShared(factory NodeFactory, opts ...ClusterOption) Runner {
+	cache := &prefixCache{clusters: map[string]*Cluster{}}
+	return func(tc *TestCase) error {
+		cluster, step, err := cache.longest(tc)
+		if err != nil {
+			return err
+		}
+		if cluster == nil {
+			cluster, err = NewCluster(tc.Nodes(), factory, opts...)
+			if err != nil {
+				return err
+			}
+		}
+		for i := 0; i < step; i++ {
+			tc.Next()
+		}
+		tc.trace = tc.trace[:0]
+		for {
+			done, err := cluster.stepCase(tc)
+			if done || err != nil {
+				return err
+			}
+			if tc.step < len(tc.states) {
+				if err := cache.save(tc.states[:tc.step], cluster); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// prefixCache stores a copy of the cluster after a prefix of steps.
+type prefixCache struct {
+	mu       sync.Mutex
+	clusters map[string]*Cluster
+}
+
+func prefixKey(states []int) string {
+	return string(appendStates(nil, states))
+}
+
+// longest returns a copy of the cluster after the longest executed prefix
+// of the test case, and the length of the prefix.
+func (c *prefixCache) longest(tc *TestCase) (*Cluster, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for step := len(tc.states) - 1; step > 0; step-- {
+		if cluster, exist := c.clusters[prefixKey(tc.states[:step])]; exist {
+			clone, err := cluster.Clone()
+			return clone, step, err
+		}
+	}
+	return nil, 0, nil
+}
+
+func (c *prefixCache) save(prefix []int, cluster *Cluster) error {
+	clone, err := cluster.Clone()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.clusters) >= maxCheckpoints {
+		c.clusters = map[string]*Cluster{}
+	}
+	c.clusters[prefixKey(prefix)] = clone
+	return nil
+}
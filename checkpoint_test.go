@@ -0,0 +1,140 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterClone(t *testing.T) {
+	cluster, err := NewCluster([]int{1, 2, 3}, paxosFactory, WithOmega(2, 4))
+	require.NoError(t, err)
+	network := Partition{}
+	network.Add(1, 2)
+	network.Add(2, 3)
+	cluster.Step(network, Actions{1: ActionLead})
+
+	clone, err := cluster.Clone()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		cluster.Step(network, Actions{})
+	}
+	require.Equal(t, Value{1}, cluster.Node(1).Learned())
+	require.Nil(t, clone.Node(1).Learned())
+
+	for i := 0; i < 3; i++ {
+		clone.Step(network, Actions{})
+	}
+	require.Equal(t, Value{1}, clone.Node(1).Learned())
+
+	unsupported, err := NewCluster([]int{1}, func(id int, nodes []int) (Node, error) {
+		return constantNode{}, nil
+	})
+	require.NoError(t, err)
+	_, err = unsupported.Clone()
+	require.Error(t, err)
+}
+
+func TestClusterCloneLeased(t *testing.T) {
+	cluster, err := NewCluster([]int{1, 2, 3}, leasedFactory(4, 3))
+	require.NoError(t, err)
+	full := Partition{}
+	full.Add(1, 2)
+	full.Add(1, 3)
+	full.Add(2, 3)
+	cluster.Step(full, Actions{})
+	cluster.Step(full, Actions{1: ActionLead})
+
+	clone, err := cluster.Clone()
+	require.NoError(t, err)
+	for i := 0; i < 6; i++ {
+		cluster.Step(full, Actions{})
+		clone.Step(full, Actions{})
+		require.NoError(t, clone.Check())
+	}
+	for _, id := range []int{1, 2, 3} {
+		require.IsType(t, &Leased{}, clone.Node(id))
+	}
+	value, ok := clone.Node(3).(*Leased).Read()
+	require.True(t, ok)
+	require.Equal(t, Value{1}, value)
+	expected, _ := cluster.Node(3).(*Leased).Read()
+	require.Equal(t, expected, value)
+}
+
+// nopStorage doesn't persist anything.
+type nopStorage struct{}
+
+func (nopStorage) Save([]byte) error     { return nil }
+func (nopStorage) Load() ([]byte, error) { return nil, nil }
+
+func TestClusterCloneRejectsEmbedded(t *testing.T) {
+	// DurablePaxos embeds *Paxos, but its storage can't be cloned
+	cluster, err := NewCluster([]int{1, 2, 3}, func(id int, nodes []int) (Node, error) {
+		p, err := NewPaxos(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		return NewDurablePaxos(p, nopStorage{})
+	})
+	require.NoError(t, err)
+	_, err = cluster.Clone()
+	require.Error(t, err)
+}
+
+func TestLeasedShared(t *testing.T) {
+	Run(t, SimulateShared(leasedFactory(3, 2, 3)), runFlags(),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1, 2}, {3}},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(5),
+	)
+}
+
+func TestPaxosShared(t *testing.T) {
+	Run(t, SimulateShared(paxosFactory), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+				{4, 5},
+			},
+			[][]int{
+				{1, 2},
+				{3, 4, 5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(6),
+	)
+}
+
+func BenchmarkSimulate(b *testing.B) {
+	for _, bc := range []struct {
+		desc string
+		run  Runner
+		opts []GenOption
+	}{
+		{"full", Simulate(paxosFactory), nil},
+		{"shared", SimulateShared(paxosFactory), nil},
+		{"shared gray", SimulateShared(paxosFactory), []GenOption{WithGrayOrder()}},
+	} {
+		b.Run(bc.desc, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				gen, err := NewGen(append([]GenOption{
+					WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+					WithReplicas(1, 2, 3),
+					WithLeaders(1, 2),
+					WithSteps(5),
+				}, bc.opts...)...)
+				require.NoError(b, err)
+				for tc := gen.Next(); tc != nil; tc = gen.Next() {
+					require.NoError(b, bc.run(tc))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+// Command paxos-check explores schedules of the paxos model outside of go test,
+// so that long-lived jobs are not limited by the timeout of the test binary:
+//
+//	paxos-check -config=testdata/paxos.json -report=report.json -max-failures=-1
+//
+// The json report is written to stdout, and the command exits with a non-zero
+// code if any test case failed. Models of other packages are checked
+// by a binary that registers them with paxos.RegisterModel and calls
+// paxos.CheckMain.
+package main
+
+import (
+	paxos "github.com/dshulyak/testing-paxos"
+)
+
+func main() {
+	paxos.RegisterModel("paxos", paxos.Simulate(func(id int, nodes []int) (paxos.Node, error) {
+		return paxos.NewPaxos(id, nodes)
+	}, paxos.WithValidation()))
+	paxos.CheckMain()
+}
@@ -0,0 +1,206 @@
+package paxos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config is a declarative configuration of the generator. It can be loaded
+// from a json file with LoadConfig, for example:
+//
+//	{
+//	  "replicas": [1, 2, 3, 4, 5],
+//	  "all_partitions": 2,
+//	  "leaders": [1, 3],
+//	  "steps": 9,
+//	  "sample": {"percent": 10, "seed": 42}
+//	}
+//
+// Every field maps to the generator option with the same name.
+type Config struct {
+	Replicas []int `json:"replicas"`
+
+	// network states. at least one of the options must be set.
+	Partitions         [][][]int               `json:"partitions,omitempty"`
+	AllPartitions      *int                    `json:"all_partitions,omitempty"`
+	LinkFailures       int                     `json:"link_failures,omitempty"`
+	OneWayLinkFailures int                     `json:"one_way_link_failures,omitempty"`
+	RandomPartitions   *RandomPartitionsConfig `json:"random_partitions,omitempty"`
+	Topologies         []string                `json:"topologies,omitempty"`
+
+	Leaders []int `json:"leaders,omitempty"`
+	// max number of leaders in the same step. 1 if not set.
+	LeaderSets     int  `json:"leader_sets,omitempty"`
+	ElectedLeaders bool `json:"elected_leaders,omitempty"`
+	ProposedValues int  `json:"proposed_values,omitempty"`
+
+	Byzantine   []int `json:"byzantine,omitempty"`
+	Drops       []int `json:"drops,omitempty"`
+	Crashes     []int `json:"crashes,omitempty"`
+	CrashBudget int   `json:"crash_budget,omitempty"`
+
+	DeliveryOrders        int  `json:"delivery_orders,omitempty"`
+	SingleDelivery        int  `json:"single_delivery,omitempty"`
+	PartialOrderReduction bool `json:"partial_order_reduction,omitempty"`
+
+	Steps             int             `json:"steps,omitempty"`
+	Sample            *SampleConfig   `json:"sample,omitempty"`
+	MaxCases          *MaxCasesConfig `json:"max_cases,omitempty"`
+	SymmetryReduction bool            `json:"symmetry_reduction,omitempty"`
+	Smoke             int             `json:"smoke,omitempty"`
+	// seed of the shuffled order of the exhaustive product
+	Shuffle   *int64 `json:"shuffle,omitempty"`
+	GrayOrder bool   `json:"gray_order,omitempty"`
+
+	DefaultTags bool     `json:"default_tags,omitempty"`
+	TagFilter   []string `json:"tag_filter,omitempty"`
+
+	// duration, for example "10m"
+	TimeBudget string `json:"time_budget,omitempty"`
+	// replay files that are executed before generated test cases
+	Corpus []string `json:"corpus,omitempty"`
+	// number of executions of every test case
+	Repeat int `json:"repeat,omitempty"`
+}
+
+type RandomPartitionsConfig struct {
+	Count int   `json:"count"`
+	Seed  int64 `json:"seed"`
+}
+
+type SampleConfig struct {
+	Percent int   `json:"percent"`
+	Seed    int64 `json:"seed"`
+}
+
+type MaxCasesConfig struct {
+	Max  int   `json:"max"`
+	Seed int64 `json:"seed"`
+}
+
+// LoadConfig decodes configuration from the json file. Unknown fields are
+// rejected, so that typos don't silently change the explored state space.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var conf Config
+	if err := dec.Decode(&conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// Options returns generator options in the order they must be applied.
+func (c *Config) Options() ([]GenOption, error) {
+	if len(c.Replicas) == 0 {
+		return nil, errors.New("replicas are not configured")
+	}
+	if len(c.Leaders) == 0 && !c.ElectedLeaders {
+		return nil, errors.New("leaders are not configured")
+	}
+	if len(c.Leaders) > 0 && c.ElectedLeaders {
+		return nil, errors.New("leaders can't be configured together with elected leaders")
+	}
+	opts := []GenOption{WithReplicas(c.Replicas...)}
+	if len(c.Partitions) > 0 {
+		opts = append(opts, WithExplicitPartitions(c.Partitions...))
+	}
+	if c.AllPartitions != nil {
+		opts = append(opts, WithAllPartitions(*c.AllPartitions))
+	}
+	if c.LinkFailures > 0 {
+		opts = append(opts, WithLinkFailures(c.LinkFailures))
+	}
+	if c.OneWayLinkFailures > 0 {
+		opts = append(opts, WithOneWayLinkFailures(c.OneWayLinkFailures))
+	}
+	if c.RandomPartitions != nil {
+		opts = append(opts, WithRandomPartitions(c.RandomPartitions.Count, c.RandomPartitions.Seed))
+	}
+	if len(c.Topologies) > 0 {
+		opts = append(opts, WithTopologies(c.Topologies...))
+	}
+	if c.ElectedLeaders {
+		opts = append(opts, WithElectedLeaders())
+	}
+	if len(c.Leaders) > 0 {
+		size := c.LeaderSets
+		if size == 0 {
+			size = 1
+		}
+		opts = append(opts, WithLeaderSets(size, c.Leaders...))
+	}
+	if c.ProposedValues > 0 {
+		opts = append(opts, WithProposedValues(c.ProposedValues))
+	}
+	if len(c.Byzantine) > 0 {
+		opts = append(opts, WithByzantine(c.Byzantine...))
+	}
+	if len(c.Drops) > 0 {
+		opts = append(opts, WithDrops(c.Drops...))
+	}
+	if len(c.Crashes) > 0 {
+		opts = append(opts, WithCrashes(c.Crashes...))
+	}
+	if c.CrashBudget > 0 {
+		opts = append(opts, WithCrashBudget(c.CrashBudget))
+	}
+	if c.DeliveryOrders > 0 {
+		opts = append(opts, WithDeliveryOrders(c.DeliveryOrders))
+	}
+	if c.SingleDelivery > 0 {
+		opts = append(opts, WithSingleDelivery(c.SingleDelivery))
+	}
+	if c.PartialOrderReduction {
+		opts = append(opts, WithPartialOrderReduction())
+	}
+	if c.Steps > 0 {
+		opts = append(opts, WithSteps(c.Steps))
+	}
+	if c.Sample != nil {
+		opts = append(opts, WithRandomSample(c.Sample.Percent, c.Sample.Seed))
+	}
+	if c.MaxCases != nil {
+		opts = append(opts, WithMaxCases(c.MaxCases.Max, c.MaxCases.Seed))
+	}
+	if c.SymmetryReduction {
+		opts = append(opts, WithSymmetryReduction())
+	}
+	if c.Smoke > 0 {
+		opts = append(opts, WithSmoke(c.Smoke))
+	}
+	if c.Shuffle != nil {
+		opts = append(opts, WithShuffledOrder(*c.Shuffle))
+	}
+	if c.GrayOrder {
+		opts = append(opts, WithGrayOrder())
+	}
+	if c.DefaultTags {
+		opts = append(opts, WithDefaultTags())
+	}
+	if len(c.TagFilter) > 0 {
+		opts = append(opts, WithTagFilter(c.TagFilter...))
+	}
+	if len(c.TimeBudget) > 0 {
+		budget, err := time.ParseDuration(c.TimeBudget)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time budget: %w", err)
+		}
+		opts = append(opts, WithTimeBudget(budget))
+	}
+	if len(c.Corpus) > 0 {
+		opts = append(opts, WithCorpus(c.Corpus...))
+	}
+	if c.Repeat > 0 {
+		opts = append(opts, WithRepeat(c.Repeat))
+	}
+	return opts, nil
+}
@@ -0,0 +1,106 @@
+package paxos
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var config = flag.String("config", "", "json file with generator configuration for TestPaxosConfig")
+
+func TestLoadConfig(t *testing.T) {
+	conf, err := LoadConfig(filepath.Join("testdata", "paxos.json"))
+	require.NoError(t, err)
+	opts, err := conf.Options()
+	require.NoError(t, err)
+	requireSameGen(t, []GenOption{
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}, {4, 5}},
+			[][]int{{1, 2}, {3, 4, 5}},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(9),
+	}, opts)
+}
+
+func TestConfigOptions(t *testing.T) {
+	groups := 2
+	conf := Config{
+		Replicas:       []int{1, 2, 3},
+		AllPartitions:  &groups,
+		LinkFailures:   1,
+		Leaders:        []int{1, 2},
+		LeaderSets:     2,
+		ProposedValues: 2,
+		Crashes:        []int{3},
+		CrashBudget:    1,
+		DeliveryOrders: 2,
+		Steps:          3,
+		Sample:         &SampleConfig{Percent: 50, Seed: 7},
+	}
+	opts, err := conf.Options()
+	require.NoError(t, err)
+	requireSameGen(t, []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(2),
+		WithLinkFailures(1),
+		WithLeaderSets(2, 1, 2),
+		WithProposedValues(2),
+		WithCrashes(3),
+		WithCrashBudget(1),
+		WithDeliveryOrders(2),
+		WithSteps(3),
+		WithRandomSample(50, 7),
+	}, opts)
+
+	conf.TimeBudget = "10"
+	_, err = conf.Options()
+	require.Error(t, err)
+	conf.TimeBudget = ""
+
+	conf.ElectedLeaders = true
+	_, err = conf.Options()
+	require.Error(t, err)
+}
+
+func requireSameGen(t *testing.T, expected, actual []GenOption) {
+	t.Helper()
+	egen, err := NewGen(expected...)
+	require.NoError(t, err)
+	agen, err := NewGen(actual...)
+	require.NoError(t, err)
+	require.Equal(t, egen.nodes, agen.nodes)
+	require.Equal(t, egen.partitions, agen.partitions)
+	require.Equal(t, egen.actions, agen.actions)
+	require.Equal(t, egen.states, agen.states)
+	require.Equal(t, egen.Total(), agen.Total())
+	require.Equal(t, egen.crashBudget, agen.crashBudget)
+	epercent, eseed := egen.Sample()
+	apercent, aseed := agen.Sample()
+	require.Equal(t, epercent, apercent)
+	require.Equal(t, eseed, aseed)
+}
+
+func TestLoadConfigUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"replicas": [1, 2, 3], "leader": [1]}`), 0o644))
+	_, err := LoadConfig(path)
+	require.Error(t, err)
+}
+
+// TestPaxosConfig explores configuration from the -config file.
+// Example: go test -run=TestPaxosConfig -config=testdata/paxos.json
+func TestPaxosConfig(t *testing.T) {
+	if len(*config) == 0 {
+		t.Skip("configuration file is not provided")
+	}
+	conf, err := LoadConfig(*config)
+	require.NoError(t, err)
+	opts, err := conf.Options()
+	require.NoError(t, err)
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(), opts...)
+}
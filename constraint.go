@@ -0,0 +1,157 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Step is a state of the network and actions in a step of the schedule.
+// Actions are not adjusted for the crash budget and state of the replicas.
+type Step struct {
+	Network Partition
+	Actions Actions
+	// Order of the delivery, or index of the delivered message with single delivery.
+	Order int
+}
+
+// WithConstraint generates only schedules that satisfy constraint, for example
+// schedules where network heals at least once, or leader changes at most twice.
+// Constraint is evaluated for every schedule before shards, symmetry reduction
+// and sampling are applied, and it is not evaluated for replayed test cases.
+// Total doesn't account for constraints.
+func WithConstraint(constraint func(steps []Step) bool) GenOption {
+	return func(g *Generator) error {
+		if constraint == nil {
+			return errors.New("constraint is nil")
+		}
+		g.constraints = append(g.constraints, constraint)
+		return nil
+	}
+}
+
+// constraintIterator skips schedules that don't satisfy constraints.
+type constraintIterator struct {
+	gen   *Generator
+	iter  Iterator
+	steps []Step
+}
+
+func (c *constraintIterator) Next() bool {
+	for c.iter.Next() {
+		if c.satisfied(c.iter.Current()) {
+			return true
+		}
+		c.iter.Current().Release()
+	}
+	return false
+}
+
+func (c *constraintIterator) satisfied(tc *TestCase) bool {
+	c.steps = c.gen.appendSteps(c.steps[:0], tc.states)
+	return c.gen.satisfies(c.steps)
+}
+
+// satisfies is true if the schedule satisfies every constraint.
+func (g *Generator) satisfies(steps []Step) bool {
+	for _, constraint := range g.constraints {
+		if !constraint(steps) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendSteps appends steps of the schedule to steps.
+func (g *Generator) appendSteps(steps []Step, states []int) []Step {
+	for i, index := range states {
+		state := g.statesAt(i)[index]
+		steps = append(steps, Step{
+			Network: g.partitions[state.partition],
+			Actions: g.actions[state.actions],
+			Order:   state.order,
+		})
+	}
+	return steps
+}
+
+func (c *constraintIterator) Error() error {
+	return c.iter.Error()
+}
+
+func (c *constraintIterator) Current() *TestCase {
+	return c.iter.Current()
+}
+
+// WithPartitionChurn generates only schedules where at most links one way
+// links change reachability between consecutive steps, so that the network
+// evolves gradually instead of changing completely in every step.
+func WithPartitionChurn(links int) GenOption {
+	return func(g *Generator) error {
+		if links < 0 {
+			return fmt.Errorf("churn %d must not be negative", links)
+		}
+		g.constraints = append(g.constraints, func(steps []Step) bool {
+			for i := 1; i < len(steps); i++ {
+				if g.churn(steps[i-1].Network, steps[i].Network) > links {
+					return false
+				}
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+// churn returns number of one way links that are reachable only in one of the partitions.
+func (g *Generator) churn(a, b Partition) int {
+	changed := 0
+	for _, from := range g.nodes {
+		for _, to := range g.nodes {
+			if from != to && a.Reachable(from, to) != b.Reachable(from, to) {
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+// WithCollapsedRepeats skips schedules where the same step without leaders is
+// repeated more than max times in a row. Such steps only deliver messages
+// that are pending, and their repeats are often equivalent to the shorter
+// sequence. Reduction is not sound: a repeated step delivers replies to the
+// messages that were delivered by the previous step.
+func WithCollapsedRepeats(max int) GenOption {
+	return func(g *Generator) error {
+		if max <= 0 {
+			return fmt.Errorf("max repeats %d must be positive", max)
+		}
+		g.constraints = append(g.constraints, func(steps []Step) bool {
+			repeats := 1
+			for i := 1; i < len(steps); i++ {
+				if sameStep(steps[i-1], steps[i]) && !hasLeader(steps[i].Actions) {
+					repeats++
+				} else {
+					repeats = 1
+				}
+				if repeats > max {
+					return false
+				}
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+func sameStep(a, b Step) bool {
+	return a.Order == b.Order && equalPartitions(a.Network, b.Network) && equalActions(a.Actions, b.Actions)
+}
+
+func hasLeader(actions Actions) bool {
+	for id := range actions {
+		if actions.IsLeader(id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// leaderChanges returns number of times leader is different from the previous leader.
+func leaderChanges(steps []Step) int {
+	changes, last := 0, 0
+	for _, step := range steps {
+		for id := range step.Actions {
+			if step.Actions.IsLeader(id) {
+				if last != 0 && last != id {
+					changes++
+				}
+				last = id
+			}
+		}
+	}
+	return changes
+}
+
+func healed(steps []Step, nodes []int) bool {
+	for _, step := range steps {
+		connected := true
+		for _, from := range nodes {
+			for _, to := range nodes {
+				connected = connected && (from == to || step.Network.Reachable(from, to))
+			}
+		}
+		if connected {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConstraint(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	opts := []GenOption{
+		WithReplicas(nodes...),
+		WithAllPartitions(0),
+		WithLeaders(nodes...),
+		WithSteps(4),
+	}
+	constraint := func(steps []Step) bool {
+		return leaderChanges(steps) <= 1 && healed(steps, nodes)
+	}
+
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	var expected [][]byte
+	for tc := range gen.All() {
+		var steps []Step
+		for network, actions := range tc.Steps() {
+			steps = append(steps, Step{Network: network, Actions: actions})
+		}
+		if constraint(steps) {
+			buf, err := tc.Marshal()
+			require.NoError(t, err)
+			expected = append(expected, buf)
+		}
+	}
+	require.NotEmpty(t, expected)
+	constrained := collectCases(t, append(opts, WithConstraint(constraint))...)
+	require.Equal(t, expected, constrained)
+	require.Less(t, len(constrained), int(gen.Total().Int64()))
+
+	_, err = NewGen(append(opts, WithConstraint(nil))...)
+	require.Error(t, err)
+}
+
+func TestPaxosConstraint(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithReplicas(nodes...),
+		WithAllPartitions(0),
+		WithLeaders(nodes...),
+		WithSteps(4),
+		WithConstraint(func(steps []Step) bool {
+			return leaderChanges(steps) <= 1 && healed(steps, nodes)
+		}),
+	)
+}
+
+func TestPartitionChurn(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(3),
+	}
+	gen, err := NewGen(append(opts, WithPartitionChurn(2))...)
+	require.NoError(t, err)
+	count := 0
+	for tc := range gen.All() {
+		count++
+		var last Partition
+		for network := range tc.Steps() {
+			if last != nil {
+				require.LessOrEqual(t, gen.churn(last, network), 2, "%s", tc)
+			}
+			last = network
+		}
+	}
+	require.NoError(t, gen.Error())
+	require.NotZero(t, count)
+	require.Less(t, count, int(gen.Total().Int64()))
+
+	// network never changes
+	gen, err = NewGen(append(opts, WithPartitionChurn(0))...)
+	require.NoError(t, err)
+	count = 0
+	for range gen.All() {
+		count++
+	}
+	require.Equal(t, 5*2*2*2, count)
+
+	_, err = NewGen(append(opts, WithPartitionChurn(-1))...)
+	require.Error(t, err)
+}
+
+func TestCollapsedRepeats(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithLeaders(1),
+		WithSteps(3),
+	}
+	gen, err := NewGen(append(opts, WithCollapsedRepeats(1))...)
+	require.NoError(t, err)
+	var cases []string
+	for tc := range gen.All() {
+		var steps []Step
+		for network, actions := range tc.Steps() {
+			steps = append(steps, Step{Network: network, Actions: actions})
+		}
+		for i := 1; i < len(steps); i++ {
+			if sameStep(steps[i-1], steps[i]) {
+				require.True(t, steps[i].Actions.IsLeader(1), "%s", tc)
+			}
+		}
+		cases = append(cases, tc.String())
+	}
+	require.NoError(t, gen.Error())
+	// 4 states in every step, 2 of them without leaders: 64 - 2*4 - 2*4 + 2
+	require.Len(t, cases, 50)
+
+	gen, err = NewGen(append(opts, WithCollapsedRepeats(2))...)
+	require.NoError(t, err)
+	count := 0
+	for range gen.All() {
+		count++
+	}
+	require.Equal(t, 62, count)
+
+	_, err = NewGen(append(opts, WithCollapsedRepeats(0))...)
+	require.Error(t, err)
+}
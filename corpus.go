@@ -0,0 +1,91 @@
+package paxos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithCorpus executes test cases from the replay files before any generated
+// test case, e.g. failures that were checked into testdata as regression
+// tests. Corpus is executed regardless of sampling, sharding and filters.
+// Files must be recorded with the same generator configuration.
+func WithCorpus(paths ...string) GenOption {
+	return func(g *Generator) error {
+		if len(paths) == 0 {
+			return errors.New("provide at least one corpus file")
+		}
+		g.corpus = append(g.corpus, paths...)
+		return nil
+	}
+}
+
+// loadCorpus reads and validates every test case of the corpus files.
+func (g *Generator) loadCorpus() ([]*TestCase, error) {
+	var cases []*TestCase
+	for _, path := range g.corpus {
+		read, err := g.readCorpus(path)
+		if err != nil {
+			return nil, fmt.Errorf("corpus %s: %w", path, err)
+		}
+		cases = append(cases, read...)
+	}
+	return cases, nil
+}
+
+func (g *Generator) readCorpus(path string) ([]*TestCase, error) {
+	r, err := NewReplayReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if r.fingerprint != nil && !bytes.Equal(r.fingerprint, g.fingerprint()) {
+		return nil, errors.New("recorded with a different generator configuration")
+	}
+	var cases []*TestCase
+	for {
+		tc, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return cases, nil
+		} else if err != nil {
+			return nil, err
+		}
+		tc.gen = g
+		if err := tc.validate(); err != nil {
+			return nil, err
+		}
+		cases = append(cases, tc)
+	}
+}
+
+// corpusIterator returns test cases of the corpus, followed by the test cases
+// of the wrapped iterator. Corpus test cases are counted as considered.
+type corpusIterator struct {
+	gen  *Generator
+	iter Iterator
+
+	cases   []*TestCase
+	current *TestCase
+}
+
+func (c *corpusIterator) Next() bool {
+	if len(c.cases) > 0 {
+		c.current, c.cases = c.cases[0], c.cases[1:]
+		c.gen.considered++
+		return true
+	}
+	if !c.iter.Next() {
+		return false
+	}
+	c.current = c.iter.Current()
+	return true
+}
+
+func (c *corpusIterator) Current() *TestCase {
+	return c.current
+}
+
+func (c *corpusIterator) Error() error {
+	return c.iter.Error()
+}
@@ -0,0 +1,47 @@
+package paxos
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorpus(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(3),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "corpus.test")
+	replay, err := NewReplay(path)
+	require.NoError(t, err)
+	last := len(gen.states) - 1
+	corpus := [][]int{{last, last, last}, {1, 0, 1}}
+	for _, states := range corpus {
+		require.NoError(t, replay.Write(&TestCase{gen: gen, states: states}))
+	}
+	require.NoError(t, replay.Close())
+
+	gen, err = NewGen(append(opts, WithCorpus(path), WithRandomSample(1, 1))...)
+	require.NoError(t, err)
+	for _, states := range corpus {
+		tc := gen.Next()
+		require.NotNil(t, tc)
+		require.Equal(t, states, tc.states)
+	}
+	for tc := range gen.All() {
+		require.NotNil(t, tc)
+	}
+	require.NoError(t, gen.Error())
+	stats := gen.Stats()
+	require.GreaterOrEqual(t, stats.Skipped(), 0)
+
+	_, err = NewGen(append(opts[:3:3], WithSteps(2), WithCorpus(path))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithCorpus(filepath.Join(t.TempDir(), "missing.test")))...)
+	require.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+)
+
+// WithCoverageGuided replaces the exhaustive product with count test cases
+// that are generated by mutating test cases that reached new states, similar
+// to coverage guided fuzzing. State after every step is reported with
+// TestCase.Cover, Simulate reports statuses of the replicas that implement
+// Status() Status. Runner must report executed test cases with Generator.Feedback,
+// Run does it automatically. Without feedback test cases are random.
+func WithCoverageGuided(count int, seed int64) GenOption {
+	return func(g *Generator) error {
+		if count <= 0 {
+			return fmt.Errorf("count %d must be positive", count)
+		}
+		g.coverage = &coverageIterator{
+			gen:     g,
+			count:   count,
+			rng:     rand.New(rand.NewSource(seed)),
+			covered: map[uint64]struct{}{},
+		}
+		return nil
+	}
+}
+
+// Feedback reports that test case was executed. Test cases that covered new
+// states are used to generate next test cases. Executed test cases are
+// counted in Stats. Safe to use from multiple goroutines.
+func (g *Generator) Feedback(tc *TestCase) {
+	g.count(tc, false)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.executed++
+	if g.coverage != nil {
+		g.coverage.feedback(tc)
+	}
+}
+
+// Cover records a state that was reached by the test case.
+// Ignored if generator is not coverage guided.
+func (t *TestCase) Cover(state uint64) {
+	if t.gen.coverage == nil {
+		return
+	}
+	t.covered = append(t.covered, state)
+}
+
+// coverageIterator generates test cases by mutating the corpus of test cases
+// that reached new states.
+type coverageIterator struct {
+	gen   *Generator
+	count int
+	rng   *rand.Rand
+
+	generated int
+	covered   map[uint64]struct{}
+	corpus    [][]int
+	current   *TestCase
+}
+
+func (c *coverageIterator) Next() bool {
+	if c.generated == c.count {
+		return false
+	}
+	c.generated++
+	var states []int
+	// random test case is generated with a small probability even if corpus
+	// is not empty, otherwise exploration is stuck around the first test cases
+	if len(c.corpus) == 0 || c.rng.Intn(10) == 0 {
+		states = make([]int, c.gen.stepLimit)
+		for i := range states {
+			states[i] = c.rng.Intn(len(c.gen.statesAt(i)))
+		}
+	} else {
+		states = c.mutate(c.corpus[c.rng.Intn(len(c.corpus))])
+	}
+	c.current = &TestCase{gen: c.gen, states: states}
+	return true
+}
+
+// mutate changes either few random steps or every step after a random step.
+func (c *coverageIterator) mutate(parent []int) []int {
+	states := append([]int{}, parent...)
+	if c.rng.Intn(4) == 0 {
+		for i := c.rng.Intn(len(states)); i < len(states); i++ {
+			states[i] = c.rng.Intn(len(c.gen.statesAt(i)))
+		}
+		return states
+	}
+	for n := 1 + c.rng.Intn(3); n > 0; n-- {
+		i := c.rng.Intn(len(states))
+		states[i] = c.rng.Intn(len(c.gen.statesAt(i)))
+	}
+	return states
+}
+
+func (c *coverageIterator) feedback(tc *TestCase) {
+	fresh := false
+	for _, state := range tc.covered {
+		if _, exist := c.covered[state]; !exist {
+			c.covered[state] = struct{}{}
+			fresh = true
+		}
+	}
+	if fresh {
+		// test case may be released after the feedback
+		c.corpus = append(c.corpus, append([]int(nil), tc.states...))
+	}
+}
+
+func (c *coverageIterator) Current() *TestCase {
+	return c.current
+}
+
+func (c *coverageIterator) Error() error {
+	return nil
+}
+
+// Coverage returns number of distinct states that were covered by the test cases,
+// and the number of test cases in the corpus. Zero if generator is not coverage guided.
+func (g *Generator) Coverage() (states, corpus int) {
+	if g.coverage == nil {
+		return 0, 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.coverage.covered), len(g.coverage.corpus)
+}
+
+func (g *Generator) validateCoverage() error {
+	if g.coverage == nil {
+		return nil
+	}
+	if g.iter != nil {
+		return errors.New("coverage guided generation can't be used with replay")
+	}
+	if g.shards > 1 || g.symmetry {
+		return errors.New("coverage guided generation can't be used with shards or symmetry reduction")
+	}
+	return nil
+}
+
+// statuser is implemented by nodes that expose a snapshot of their state.
+type statuser interface {
+	Status() Status
+}
+
+// cover reports an abstract state of the cluster to the test case. Ballots
+// grow with every proposal, so the state keeps only the relations between them:
+// phase and votes of every replica, whether replica voted in its current ballot,
+// and which of the distinct voted and learned values replica has.
+// Replicas are not distinguished by id, otherwise most of the states are new.
+func (c *Cluster) cover(tc *TestCase) {
+	if tc.gen.coverage == nil {
+		return
+	}
+	ids := append([]int{}, c.ids...)
+	sort.Ints(ids)
+	var (
+		h      = fnv.New64a()
+		buf    []byte
+		values = map[string]int{}
+	)
+	index := func(value Value) int {
+		if value == nil {
+			return 0
+		}
+		if _, exist := values[string(value)]; !exist {
+			values[string(value)] = len(values) + 1
+		}
+		return values[string(value)]
+	}
+	var states []string
+	for _, id := range ids {
+		node, ok := c.nodes[id].(statuser)
+		if !ok {
+			continue
+		}
+		if c.isCrashed(id) {
+			states = append(states, "crashed")
+			continue
+		}
+		status := node.Status()
+		current := byte(0)
+		if status.VotedBallot == status.Ballot {
+			current = 1
+		}
+		states = append(states, string([]byte{
+			byte(status.Phase), byte(status.Promises), byte(status.Accepts), current,
+			byte(index(status.VotedValue)), byte(index(status.LearnedValue)),
+		}))
+	}
+	// replicas are interchangeable
+	sort.Strings(states)
+	for _, state := range states {
+		buf = append(append(buf, state...), 0)
+	}
+	h.Write(buf)
+	tc.Cover(h.Sum64())
+}
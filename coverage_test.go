@@ -0,0 +1,64 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverageGuided(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3, 4, 5),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3, 4, 5),
+		WithSteps(12),
+	}
+	run := Simulate(paxosFactory, WithValidation())
+	explore := func(feedback bool) int {
+		gen, err := NewGen(append(opts, WithCoverageGuided(3000, 1))...)
+		require.NoError(t, err)
+		covered := map[uint64]struct{}{}
+		for tc := range gen.All() {
+			require.NoError(t, run(tc))
+			for _, state := range tc.covered {
+				covered[state] = struct{}{}
+			}
+			if feedback {
+				gen.Feedback(tc)
+			}
+		}
+		require.NoError(t, gen.Error())
+		require.Equal(t, 3000, gen.Count())
+		if feedback {
+			states, corpus := gen.Coverage()
+			require.Equal(t, len(covered), states)
+			require.NotZero(t, corpus)
+		}
+		return len(covered)
+	}
+	random := explore(false)
+	guided := explore(true)
+	t.Logf("distinct states: random %d, guided %d", random, guided)
+	require.Greater(t, guided, random)
+}
+
+func TestCoverageGuidedOptions(t *testing.T) {
+	_, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3),
+		WithCoverageGuided(10, 1),
+		WithShard(0, 2),
+	)
+	require.Error(t, err)
+}
+
+func TestPaxosCoverageGuided(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3, 4, 5),
+		WithSteps(12),
+		WithCoverageGuided(2000, 1),
+	)
+}
@@ -0,0 +1,105 @@
+package paxos
+
+import (
+	"encoding"
+	"fmt"
+)
+
+func (c *Cluster) isCrashed(id int) bool {
+	_, exist := c.crashed[id]
+	return exist
+}
+
+// crash saves persisted state of the replica. Replica that doesn't implement
+// encoding.BinaryMarshaler keeps all state, as if it was paused.
+func (c *Cluster) crash(id int) {
+	if c.isCrashed(id) {
+		return
+	}
+	if c.crashed == nil {
+		c.crashed = map[int][]byte{}
+	}
+	var state []byte
+	if m, ok := c.nodes[id].(encoding.BinaryMarshaler); ok {
+		var err error
+		state, err = m.MarshalBinary()
+		if err != nil && c.err == nil {
+			c.err = fmt.Errorf("replica %d: %w", id, err)
+		}
+	}
+	c.crashed[id] = state
+}
+
+// recover replaces the replica with a fresh replica that restored
+// persisted state.
+func (c *Cluster) recover(id int) {
+	state, exist := c.crashed[id]
+	if !exist {
+		return
+	}
+	delete(c.crashed, id)
+	if _, ok := c.nodes[id].(encoding.BinaryMarshaler); !ok {
+		return
+	}
+	node, err := c.factory(id, c.ids)
+	if err == nil {
+		if u, ok := node.(encoding.BinaryUnmarshaler); ok {
+			err = u.UnmarshalBinary(state)
+		} else {
+			err = fmt.Errorf("can't restore state of %T", node)
+		}
+	}
+	if err != nil {
+		if c.err == nil {
+			c.err = fmt.Errorf("replica %d: %w", id, err)
+		}
+		return
+	}
+	c.nodes[id] = node
+	// proposals of the previous replica are lost
+	for key := range c.outcomes {
+		if key.replica == id {
+			delete(c.outcomes, key)
+		}
+	}
+}
+
+// alive returns a network without links to and from crashed replicas.
+func (c *Cluster) alive(network Partition) Partition {
+	if len(c.crashed) == 0 {
+		return network
+	}
+	rst := Partition{}
+	for from, routes := range network {
+		if c.isCrashed(from) {
+			continue
+		}
+		for to := range routes {
+			if !c.isCrashed(to) {
+				rst.AddOneWay(from, to)
+			}
+		}
+	}
+	return rst
+}
+
+// dropCrashed removes messages that are sent to or from crashed replicas.
+func (c *Cluster) dropCrashed() {
+	if len(c.crashed) == 0 {
+		return
+	}
+	messages := c.messages[:0]
+	for _, msg := range c.messages {
+		if !c.isCrashed(msg.From) && !c.isCrashed(msg.To) {
+			messages = append(messages, msg)
+		}
+	}
+	c.messages = messages
+	inflight := c.inflight[:0]
+	for _, m := range c.inflight {
+		if !c.isCrashed(m.msg.From) && !c.isCrashed(m.msg.To) {
+			inflight = append(inflight, m)
+		}
+	}
+	c.inflight = inflight
+}
@@ -0,0 +1,68 @@
+package paxos
+
+// NewOmega creates a failure detector for replica id.
+// Replica is suspected if it wasn't heard from for more than timeout ticks.
+func NewOmega(id int, nodes []int, timeout int) *Omega {
+	o := &Omega{
+		ID:       id,
+		Nodes:    nodes,
+		Timeout:  timeout,
+		lastSeen: make(map[int]int, len(nodes)),
+	}
+	for _, node := range nodes {
+		o.lastSeen[node] = 0
+	}
+	return o
+}
+
+// Omega is an eventual leader detector. Replica trusts a replica with the
+// lowest id among replicas that are not suspected.
+// Before the first timeout expires all replicas are trusted.
+type Omega struct {
+	ID      int
+	Nodes   []int
+	Timeout int
+
+	tick     int
+	lastSeen map[int]int
+}
+
+// Clone returns a copy of the detector.
+func (o *Omega) Clone() *Omega {
+	clone := *o
+	clone.lastSeen = make(map[int]int, len(o.lastSeen))
+	for id, tick := range o.lastSeen {
+		clone.lastSeen[id] = tick
+	}
+	return &clone
+}
+
+// Tick advances logical clock of the detector.
+func (o *Omega) Tick() {
+	o.tick++
+}
+
+// Heartbeat records that replica is alive at the current tick.
+func (o *Omega) Heartbeat(from int) {
+	o.lastSeen[from] = o.tick
+}
+
+// Alive returns true if replica is not suspected.
+func (o *Omega) Alive(id int) bool {
+	if id == o.ID {
+		return true
+	}
+	seen, exist := o.lastSeen[id]
+	return exist && o.tick-seen <= o.Timeout
+}
+
+// Leader returns a trusted replica.
+func (o *Omega) Leader() int {
+	leader := o.ID
+	for _, id := range o.Nodes {
+		if id < leader && o.Alive(id) {
+			leader = id
+		}
+	}
+	return leader
+}
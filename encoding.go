@@ -0,0 +1,72 @@
+package paxos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// messageHeader is a fixed width part of the encoded Message.
+type messageHeader struct {
+	From, To    int64
+	Type        MessageType
+	Ballot      int64
+	VotedBallot int64
+	Slot        int64
+	// -1 if value is nil
+	ValueLength int64
+}
+
+// Marshal encodes message as a fixed width little endian header followed by the value.
+func (m Message) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	header := messageHeader{
+		From:        int64(m.From),
+		To:          int64(m.To),
+		Type:        m.Type,
+		Ballot:      int64(m.Ballot),
+		VotedBallot: int64(m.VotedBallot),
+		Slot:        int64(m.Slot),
+		ValueLength: -1,
+	}
+	if m.Value != nil {
+		header.ValueLength = int64(len(m.Value))
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	buf.Write(m.Value)
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes message that was encoded with Marshal.
+func (m *Message) Unmarshal(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	var header messageHeader
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if header.Type < MessageEmpty || int(header.Type) >= len(messageTypeString) {
+		return fmt.Errorf("unknown message type %d", header.Type)
+	}
+	var value Value
+	if lth := header.ValueLength; lth >= 0 {
+		if lth != int64(buf.Len()) {
+			return fmt.Errorf("value length %d doesn't match remaining %d bytes", lth, buf.Len())
+		}
+		value = make(Value, lth)
+		copy(value, buf.Next(int(lth)))
+	} else if buf.Len() != 0 {
+		return fmt.Errorf("unexpected %d bytes after the header", buf.Len())
+	}
+	*m = Message{
+		From:        int(header.From),
+		To:          int(header.To),
+		Type:        header.Type,
+		Ballot:      int(header.Ballot),
+		VotedBallot: int(header.VotedBallot),
+		Slot:        int(header.Slot),
+		Value:       value,
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package paxos
+
+import (
+	"flag"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RegisterFlags registers flags of the runner, such as -workers and -replay,
+// in the flag set. Returned function builds RunConfig from the parsed flags.
+// Package doesn't register flags on its own, so that it doesn't collide
+// with flags of other packages:
+//
+//	var runFlags = paxos.RegisterFlags(flag.CommandLine)
+//
+//	func TestPaxos(t *testing.T) {
+//		paxos.Run(t, runner, runFlags(), opts...)
+//	}
+func RegisterFlags(fs *flag.FlagSet) func() RunConfig {
+	var (
+		workers = fs.Int("workers", runtime.NumCPU(), "number of workers that will run test cases")
+		replay  = fs.String("replay", "", "replay test cases from the file")
+		dir     = fs.String("dir", "", "directory for artifacts of the failures, such as replay files. current workdir by default")
+		percent = fs.Int("percent", 100, "percent of the test cases to execute. 0 executes all of them, same as 100")
+		seed    = fs.Int64("seed", time.Now().Unix(), "seed is used only if percent is less then 100 or max-cases is set. default is a current time in seconds.")
+
+		maxCases = fs.Int("max-cases", 0, "max number of test cases that are sampled uniformly from the product. disabled by default")
+
+		shard  = fs.Int("shard", 0, "index of the shard of test cases to execute, starting from 0")
+		shards = fs.Int("shards", 1, "total number of shards")
+
+		checkpoints        = fs.String("checkpoints", "", "directory for generator checkpoints. if set interrupted run continues from the checkpoint")
+		checkpointInterval = fs.Duration("checkpoint-interval", time.Minute, "how often generator checkpoint is persisted")
+
+		profile      = fs.Bool("profile", false, "execute the first failed test case again with cpu and heap profiles")
+		profileTrace = fs.Bool("profile-trace", false, "collect an execution trace together with profiles")
+		report       = fs.String("report", "", "append a json report of every run to the file")
+		maxFailures  = fs.Int("max-failures", 1, "number of failed test cases after which run stops. negative value continues until completion")
+		caseTimeout  = fs.Duration("case-timeout", 0, "fail a test case that doesn't finish in time and write it to the replay file. disabled by default")
+		progress     = fs.Duration("progress", 0, "how often progress of the run is logged. disabled by default")
+		status       = fs.Duration("status", 0, "how often a status line with progress, failures and ETA is refreshed on stderr. disabled by default")
+		shrink       = fs.Bool("shrink", true, "minimize a failed test case and write it to the replay file before the original test case")
+		tagFilter    = fs.String("tag", "", "comma separated tags. only test cases with at least one of the tags are executed")
+		neighborhood = fs.Bool("neighborhood", false, "execute mutations of a failed test case and write failed mutations to the replay file")
+	)
+	return func() RunConfig {
+		cfg := RunConfig{
+			Workers:            *workers,
+			ReplayPath:         *replay,
+			Dir:                *dir,
+			Percent:            *percent,
+			Seed:               *seed,
+			MaxCases:           *maxCases,
+			Shard:              *shard,
+			Shards:             *shards,
+			Checkpoints:        *checkpoints,
+			CheckpointInterval: *checkpointInterval,
+			Progress:           *progress,
+			Status:             *status,
+			Profile:            *profile,
+			ExecutionTrace:     *profileTrace,
+			ReportPath:         *report,
+			MaxFailures:        *maxFailures,
+			CaseTimeout:        *caseTimeout,
+			NoShrink:           !*shrink,
+			Neighborhood:       *neighborhood,
+		}
+		if len(*tagFilter) > 0 {
+			cfg.Tags = strings.Split(*tagFilter, ",")
+		}
+		return cfg
+	}
+}
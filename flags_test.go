@@ -0,0 +1,54 @@
+package paxos
+
+import (
+	"flag"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{
+		"-replay=failed.test", "-percent=10", "-seed=7",
+		"-shrink=false", "-tag=has-crash,never-heals", "-checkpoint-interval=1s",
+		"-status=2s",
+	}))
+	require.Equal(t, RunConfig{
+		Workers:            runtime.NumCPU(),
+		ReplayPath:         "failed.test",
+		Percent:            10,
+		Seed:               7,
+		Shards:             1,
+		Tags:               []string{TagHasCrash, TagNeverHeals},
+		CheckpointInterval: time.Second,
+		MaxFailures:        1,
+		NoShrink:           true,
+		Status:             2 * time.Second,
+	}, config())
+}
+
+func TestRegisterFlagsZeroPercent(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-percent=0"}))
+
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(2),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	var executed atomic.Int64
+	Run(t, func(tc *TestCase) error {
+		executed.Add(1)
+		return nil
+	}, config(), opts...)
+	require.Equal(t, gen.Total().Int64(), executed.Load())
+}
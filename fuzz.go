@@ -0,0 +1,115 @@
+package paxos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzAdapter maps inputs of go fuzzing to test cases of the generator,
+// so that go test -fuzz explores schedules with its coverage feedback:
+//
+//	func FuzzPaxos(f *testing.F) {
+//		adapter, err := paxos.NewFuzzAdapter(opts...)
+//		require.NoError(f, err)
+//		adapter.Fuzz(f, runner)
+//	}
+type FuzzAdapter struct {
+	gen *Generator
+	// number of bytes that encode the state of a step
+	width int
+}
+
+// NewFuzzAdapter creates an adapter for the generator with options.
+// Only the space of the generator is used: sampling, shards and symmetry
+// reduction don't apply to fuzz inputs, and constraints skip inputs.
+func NewFuzzAdapter(opts ...GenOption) (*FuzzAdapter, error) {
+	gen, err := NewGen(opts...)
+	if err != nil {
+		return nil, err
+	}
+	states := 0
+	for step := 0; step < gen.stepLimit; step++ {
+		states = max(states, len(gen.statesAt(step)))
+	}
+	width := 1
+	for limit := 256; limit < states; limit <<= 8 {
+		width++
+	}
+	return &FuzzAdapter{gen: gen, width: width}, nil
+}
+
+// TestCase maps input to a valid test case. Every width bytes of the input
+// are a little endian index of the state of the next step, modulo number of
+// states in the step. Missing steps are in the first state, unless shorter
+// schedules are enabled. Extra bytes are ignored.
+func (a *FuzzAdapter) TestCase(data []byte) *TestCase {
+	steps := len(data) / a.width
+	if steps > a.gen.stepLimit || !a.gen.prefixes {
+		steps = a.gen.stepLimit
+	}
+	if steps == 0 {
+		steps = 1
+	}
+	states := make([]int, steps)
+	for i := range states {
+		if len(data) < a.width {
+			break
+		}
+		var index uint64
+		for j := a.width - 1; j >= 0; j-- {
+			index = index<<8 | uint64(data[j])
+		}
+		data = data[a.width:]
+		states[i] = int(index % uint64(len(a.gen.statesAt(i))))
+	}
+	return &TestCase{gen: a.gen, states: states}
+}
+
+// Encode returns input that is mapped to the test case by TestCase.
+// Test case must be generated with the same options as the adapter.
+func (a *FuzzAdapter) Encode(tc *TestCase) []byte {
+	buf := make([]byte, 0, len(tc.states)*a.width)
+	for _, state := range tc.states {
+		for j := 0; j < a.width; j++ {
+			buf = append(buf, byte(state>>(8*j)))
+		}
+	}
+	return buf
+}
+
+// Fuzz seeds the corpus with the first test case of the product and runs
+// test cases mapped from the inputs. Inputs that don't satisfy constraints
+// are skipped. Go test writes failed inputs to testdata/fuzz.
+func (a *FuzzAdapter) Fuzz(f *testing.F, run Runner) {
+	f.Add(a.Encode(&TestCase{gen: a.gen, states: make([]int, a.gen.stepLimit)}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tc := a.TestCase(data)
+		if !a.gen.satisfies(tc.Schedule()) {
+			t.Skip("schedule doesn't satisfy constraints")
+		}
+		if err := run(tc); err != nil {
+			t.Fatalf("%v\n%s", err, tc)
+		}
+	})
+}
+
+// WriteCorpus writes test cases as entries of the fuzz corpus to the directory,
+// for example testdata/fuzz/FuzzPaxos, so that failed test cases from
+// the replay file are executed by the fuzz test and fuzzing starts from them.
+func (a *FuzzAdapter) WriteCorpus(dir string, tcs ...*TestCase) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, tc := range tcs {
+		entry := []byte(fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", a.Encode(tc)))
+		sum := sha256.Sum256(entry)
+		if err := os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:8])), entry, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
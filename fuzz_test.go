@@ -0,0 +1,64 @@
+package paxos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzAdapter(t *testing.T) {
+	replicas := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	adapter, err := NewFuzzAdapter(
+		WithReplicas(replicas...),
+		WithAllPartitions(0),
+		WithLeaders(replicas...),
+		WithSteps(2),
+	)
+	require.NoError(t, err)
+	// 37260 states in a step are encoded with 2 bytes
+	require.Equal(t, 2, adapter.width)
+
+	for _, states := range [][]int{{0, 0}, {255, 256}, {37259, 1000}} {
+		tc := &TestCase{gen: adapter.gen, states: states}
+		require.Equal(t, states, adapter.TestCase(adapter.Encode(tc)).states)
+	}
+	require.Equal(t, []int{0, 0}, adapter.TestCase(nil).states)
+	require.Equal(t, []int{65535 % 37260, 1}, adapter.TestCase([]byte{0xff, 0xff, 1, 0, 7}).states)
+
+	dir := filepath.Join(t.TempDir(), "FuzzPaxos")
+	require.NoError(t, adapter.WriteCorpus(dir, &TestCase{gen: adapter.gen, states: []int{1, 2}}))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	buf, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	require.Equal(t, "go test fuzz v1\n[]byte(\"\\x01\\x00\\x02\\x00\")\n", string(buf))
+}
+
+func TestFuzzAdapterShorterSchedules(t *testing.T) {
+	adapter, err := NewFuzzAdapter(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(4),
+		WithShorterSchedules(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []int{0}, adapter.TestCase(nil).states)
+	require.Equal(t, []int{1, 2}, adapter.TestCase([]byte{1, 2}).states)
+	require.Len(t, adapter.TestCase([]byte(strings.Repeat("a", 10))).states, 4)
+}
+
+func FuzzPaxos(f *testing.F) {
+	adapter, err := NewFuzzAdapter(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(6),
+	)
+	require.NoError(f, err)
+	adapter.Fuzz(f, Simulate(paxosFactory, WithValidation()))
+}
@@ -5,10 +5,15 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"iter"
 	"math"
+	"math/big"
 	"math/rand"
+	"sort"
 	"sync"
+	"time"
 )
 
 const (
@@ -30,15 +35,7 @@ type GenOption func(g *Generator) error
 func WithExplicitPartitions(networks ...[][]int) GenOption {
 	return func(g *Generator) error {
 		for _, network := range networks {
-			partition := Partition{}
-			for _, nodes := range network {
-				for i := 0; i < len(nodes)-1; i++ {
-					for _, to := range nodes[i+1:] {
-						partition.Add(nodes[i], to)
-					}
-				}
-			}
-			g.partitions = append(g.partitions, partition)
+			g.partitions = append(g.partitions, groupPartition(network))
 		}
 		return nil
 	}
@@ -64,22 +61,297 @@ func WithSteps(stepLimit int) GenOption {
 // Generator needs to provide all possible states of the cluster at a given step.
 // Manually limiting leader down to 2 (for example) will significantly reduce scope of the test.
 func WithLeaders(leaders ...int) GenOption {
+	return WithLeaderSets(1, leaders...)
+}
+
+// WithLeaderSets is the same as WithLeaders, but also generates actions where
+// several leaders propose in the same step. Generates every subset of leaders
+// with at most maxSize replicas, including an empty subset.
+func WithLeaderSets(maxSize int, leaders ...int) GenOption {
 	return func(g *Generator) error {
 		if g.nodes == nil {
 			return fmt.Errorf("replicas must be configured earlier than leaders")
 		}
-		g.actions = append(g.actions, Actions{})
-		for _, leader := range leaders {
-			g.actions = append(g.actions, Actions{leader: true})
+		if maxSize <= 0 {
+			return fmt.Errorf("max size %d must be positive", maxSize)
+		}
+		if maxSize > len(leaders) {
+			maxSize = len(leaders)
+		}
+		// subsets are generated in the order of their size
+		subsets := []Actions{{}}
+		last := []int{0}
+		for size := 1; size <= maxSize; size++ {
+			var next []int
+			for i, set := range subsets[len(subsets)-len(last):] {
+				for j := last[i]; j < len(leaders); j++ {
+					extended := Actions{leaders[j]: ActionLead}
+					for id, action := range set {
+						extended[id] = action
+					}
+					subsets = append(subsets, extended)
+					next = append(next, j+1)
+				}
+			}
+			last = next
+		}
+		g.actions = append(g.actions, subsets...)
+		return nil
+	}
+}
+
+// WithProposedValues assigns values that are proposed by the leaders. Every action
+// with leaders is replaced by actions with every assignment of values from 1 to n
+// to the leaders. For example, with n = 2 two leaders in the same step propose
+// both the same and different values. Assigned values never equal the default
+// values of the leaders. Must be configured after leaders.
+func WithProposedValues(n int) GenOption {
+	return func(g *Generator) error {
+		if g.actions == nil {
+			return fmt.Errorf("leaders must be configured earlier than values")
+		}
+		if n <= 0 || n > math.MaxUint8 {
+			return fmt.Errorf("number of values %d must be in range of [1, %d]", n, math.MaxUint8)
+		}
+		var rst []Actions
+		for _, a := range g.actions {
+			var leaders []int
+			for id := range a {
+				if a.IsLeader(id) {
+					leaders = append(leaders, id)
+				}
+			}
+			sort.Ints(leaders)
+			assigned := []Actions{a}
+			for _, leader := range leaders {
+				var next []Actions
+				for _, base := range assigned {
+					for value := 1; value <= n; value++ {
+						extended := make(Actions, len(base))
+						for id, action := range base {
+							extended[id] = action
+						}
+						extended[leader] = extended[leader].WithValue(value)
+						next = append(next, extended)
+					}
+				}
+				assigned = next
+			}
+			rst = append(rst, assigned...)
+		}
+		g.actions = rst
+		return nil
+	}
+}
+
+// WithByzantine extends every configured action with an action where one of the
+// replicas is byzantine. Must be configured after leaders.
+func WithByzantine(replicas ...int) GenOption {
+	return func(g *Generator) error {
+		if g.actions == nil {
+			return fmt.Errorf("leaders must be configured earlier than byzantine replicas")
 		}
+		g.extendActions(ActionByzantine, replicas)
 		return nil
 	}
 }
 
+// WithDrops extends every configured action with an action where messages
+// to one of the replicas are dropped if they can't be delivered in the step.
+// Otherwise such messages are delayed until the replica is reachable.
+// Must be configured after leaders.
+func WithDrops(replicas ...int) GenOption {
+	return func(g *Generator) error {
+		if g.actions == nil {
+			return fmt.Errorf("leaders must be configured earlier than drops")
+		}
+		g.extendActions(ActionDrop, replicas)
+		return nil
+	}
+}
+
+// WithCrashes extends every configured action with actions where one of
+// the replicas crashes or recovers. Must be configured after leaders.
+func WithCrashes(replicas ...int) GenOption {
+	return func(g *Generator) error {
+		if g.actions == nil {
+			return fmt.Errorf("leaders must be configured earlier than crashes")
+		}
+		actions := g.actions
+		g.extendActions(ActionCrash, replicas)
+		crashes := g.actions[len(actions):]
+		g.actions = actions
+		g.extendActions(ActionRecover, replicas)
+		g.actions = append(g.actions, crashes...)
+		return nil
+	}
+}
+
+// WithCrashBudget limits number of replicas that are crashed at the same time.
+// Crash is ignored by the test case if the budget is exhausted.
+func WithCrashBudget(budget int) GenOption {
+	return func(g *Generator) error {
+		if budget <= 0 {
+			return fmt.Errorf("crash budget %d must be positive", budget)
+		}
+		g.crashBudget = budget
+		return nil
+	}
+}
+
+// extendActions adds a copy of every existing action with action set on one of the replicas.
+func (g *Generator) extendActions(action Action, replicas []int) {
+	actions := g.actions
+	for _, replica := range replicas {
+		for _, a := range actions {
+			extended := Actions{replica: action}
+			for id, other := range a {
+				extended[id] |= other
+			}
+			g.actions = append(g.actions, extended)
+		}
+	}
+}
+
+// WithShorterSchedules generates schedules of every length from 1 to the step
+// limit, shortest first. Without it only schedules with exactly step limit steps
+// are generated, and invariants that are checked only at the end of the
+// test case are never checked after shorter schedules.
+func WithShorterSchedules() GenOption {
+	return func(g *Generator) error {
+		g.prefixes = true
+		return nil
+	}
+}
+
+// WithDeliveryOrders explores orders in which messages are delivered within
+// the step. Every step is repeated with orders from 0 to n-1:
+// 0 delivers messages in the order they were sent, 1 in the reverse order,
+// and every other order is a random shuffle seeded by the order itself.
+// Number of test cases grows as n^steps.
+func WithDeliveryOrders(n int) GenOption {
+	return func(g *Generator) error {
+		if n <= 0 || n > math.MaxInt32 {
+			return fmt.Errorf("number of orders %d must be in range of [1, %d]", n, math.MaxInt32)
+		}
+		g.orders = n
+		return nil
+	}
+}
+
+// WithSingleDelivery switches generator to the fine-grained mode where every
+// step delivers exactly one pending message instead of a whole network round.
+// Message is selected by an index among messages that can reach destination,
+// every step is repeated with indexes from 0 to maxPending-1.
+// Steps with an index that is higher than the number of pending messages
+// don't deliver anything.
+func WithSingleDelivery(maxPending int) GenOption {
+	return func(g *Generator) error {
+		if maxPending <= 0 || maxPending > math.MaxInt32 {
+			return fmt.Errorf("max pending %d must be in range of [1, %d]", maxPending, math.MaxInt32)
+		}
+		g.single = maxPending
+		return nil
+	}
+}
+
+// WithStepActions overwrites actions in the step, steps start from 1.
+// For example, leaders can be allowed to propose only in the first steps,
+// while later steps only deliver messages:
+//
+//	WithStepActions(4, Actions{}),
+//	WithStepActions(5, Actions{}),
+//
+// Actions configured with other options are used in all other steps.
+func WithStepActions(step int, actions ...Actions) GenOption {
+	return func(g *Generator) error {
+		if step <= 0 {
+			return fmt.Errorf("step %d must be positive", step)
+		}
+		if len(actions) == 0 {
+			return fmt.Errorf("step %d requires at least one action", step)
+		}
+		if g.stepActions == nil {
+			g.stepActions = map[int][]Actions{}
+		}
+		g.stepActions[step] = append(g.stepActions[step], actions...)
+		return nil
+	}
+}
+
+// WithShard generates only test cases that belong to the shard with index
+// out of total shards. Every test case belongs to exactly one shard, so that
+// a large exhaustive run can be split between independent processes.
+// Shard is selected by the hash of the test case steps.
+func WithShard(index, total int) GenOption {
+	return func(g *Generator) error {
+		if total <= 0 || index < 0 || index >= total {
+			return fmt.Errorf("shard %d must be in range of [0, %d)", index, total)
+		}
+		g.shard = index
+		g.shards = total
+		return nil
+	}
+}
+
+// WithTicks extends every configured action with an action where the clock of
+// one of the replicas advances ticks times in the step instead of once.
+// 0 ticks delay timeouts on the replica, and more than one tick fire them
+// prematurely. Can be configured multiple times with different number of ticks.
+// Must be configured after leaders.
+func WithTicks(ticks int, replicas ...int) GenOption {
+	return func(g *Generator) error {
+		if g.actions == nil {
+			return fmt.Errorf("leaders must be configured earlier than ticks")
+		}
+		if ticks < 0 || ticks >= actionFieldMask {
+			return fmt.Errorf("ticks %d must be in range of [0, %d]", ticks, actionFieldMask-1)
+		}
+		actions := g.actions
+		for _, replica := range replicas {
+			for _, a := range actions {
+				extended := make(Actions, len(a)+1)
+				for id, other := range a {
+					extended[id] = other
+				}
+				extended[replica] = extended[replica].WithTicks(ticks)
+				g.actions = append(g.actions, extended)
+			}
+		}
+		return nil
+	}
+}
+
+// WithElectedLeaders generates schedules without leaders. It is expected
+// that runner elects leaders itself, for example with WithOmega cluster option.
+func WithElectedLeaders() GenOption {
+	return func(g *Generator) error {
+		g.actions = append(g.actions, Actions{})
+		return nil
+	}
+}
+
+// WithRNG is the same as WithRandomSample, but 0 percent disables sampling.
+//
+// Deprecated: use WithRandomSample.
 func WithRNG(percent int, seed int64) GenOption {
 	return func(g *Generator) error {
-		if g.percent > 100 || g.percent < 0 {
-			return fmt.Errorf("percent %d must be in range of [0, 100]", g.percent)
+		if percent == 0 {
+			return nil
+		}
+		return WithRandomSample(percent, seed)(g)
+	}
+}
+
+// WithRandomSample executes approximately percent of the test cases
+// from the exhaustive product. Sampled test cases are selected by the
+// rng initialized with seed, and the same seed reproduces the same sample.
+// Only the last sample is used if option is repeated, see WithSampling
+// to sample test cases from a specific iterator.
+func WithRandomSample(percent int, seed int64) GenOption {
+	return func(g *Generator) error {
+		if percent > 100 || percent <= 0 {
+			return fmt.Errorf("percent %d must be in range of [1, 100]", percent)
 		}
 		g.percent = percent
 		g.seed = seed
@@ -87,6 +359,65 @@ func WithRNG(percent int, seed int64) GenOption {
 	}
 }
 
+// WithSampling applies opt and executes approximately percent of the test
+// cases generated by the iterator that opt configures, e.g. replay, coverage
+// guided or swarm. For example, WithSampling(WithReplay(r), 10, seed) replays
+// 10% of the recorded test cases. Test cases from other sources, such as the
+// corpus, are not sampled. Sampling is applied before WithRandomSample.
+// Returns an error if opt doesn't configure an iterator.
+func WithSampling(opt GenOption, percent int, seed int64) GenOption {
+	return func(g *Generator) error {
+		if percent > 100 || percent <= 0 {
+			return fmt.Errorf("percent %d must be in range of [1, 100]", percent)
+		}
+		before := g.source()
+		if err := opt(g); err != nil {
+			return err
+		}
+		if after := g.source(); after == sourceProduct || after == before {
+			return errors.New("sampled option doesn't configure an iterator")
+		}
+		g.samples = append(g.samples, sample{percent: percent, seed: seed})
+		return nil
+	}
+}
+
+// source of the generated test cases.
+type source int
+
+const (
+	sourceProduct source = iota
+	sourceReplay
+	sourceCapped
+	sourceSwarm
+	sourceCoverage
+	sourceCustom
+)
+
+// source returns the source of the test cases that is used with the options
+// that are applied so far, in the same order of precedence as NewGen uses.
+func (g *Generator) source() source {
+	switch {
+	case g.custom != nil:
+		return sourceCustom
+	case g.coverage != nil:
+		return sourceCoverage
+	case g.swarm != nil:
+		return sourceSwarm
+	case g.capped != nil:
+		return sourceCapped
+	case g.iter != nil:
+		return sourceReplay
+	}
+	return sourceProduct
+}
+
+// sample of the test cases from the configured iterator, see WithSampling.
+type sample struct {
+	percent int
+	seed    int64
+}
+
 func NewGen(opts ...GenOption) (*Generator, error) {
 	gen := &Generator{}
 	for _, opt := range opts {
@@ -97,55 +428,298 @@ func NewGen(opts ...GenOption) (*Generator, error) {
 	if gen.stepLimit == 0 {
 		gen.stepLimit = defaultStepLimit
 	}
+	if gen.single > 0 && gen.orders > 1 {
+		return nil, errors.New("delivery orders can't be explored with single delivery")
+	}
+	if gen.orders == 0 {
+		gen.orders = 1
+	}
+	choices := gen.orders
+	if gen.single > 0 {
+		choices = gen.single
+	}
 	if gen.actions == nil {
 		return nil, errors.New("provide an option to configure actions")
 	}
 	if gen.partitions == nil {
 		return nil, errors.New("provide an option to configure partitions")
 	}
+	if err := gen.validateReplicas(); err != nil {
+		return nil, err
+	}
 
-	for i := range gen.actions {
-		for j := range gen.partitions {
-			gen.states = append(gen.states, stepState{actions: i, partition: j})
+	actions := make([]int, len(gen.actions))
+	for i := range actions {
+		actions[i] = i
+	}
+	gen.states = gen.product(actions, choices)
+	if len(gen.states) > math.MaxInt32 {
+		return nil, fmt.Errorf("max number of possible states %d. reduce by removing actions or partitions", math.MaxInt32)
+	}
+	for step, overwrite := range gen.stepActions {
+		if step > gen.stepLimit {
+			return nil, fmt.Errorf("actions for step %d are configured, but step limit is %d", step, gen.stepLimit)
+		}
+		actions = actions[:0]
+		for _, a := range overwrite {
+			actions = append(actions, len(gen.actions))
+			gen.actions = append(gen.actions, a)
+		}
+		if gen.stepStates == nil {
+			gen.stepStates = map[int][]stepState{}
 		}
+		gen.stepStates[step-1] = gen.product(actions, choices)
+		if len(gen.stepStates[step-1]) > math.MaxInt32 {
+			return nil, fmt.Errorf("max number of possible states in step %d is %d", step, math.MaxInt32)
+		}
+	}
+	if err := gen.resolveNames(); err != nil {
+		return nil, err
+	}
+	if gen.healing > 0 {
+		if err := gen.heal(); err != nil {
+			return nil, err
+		}
+	}
+	if r, ok := gen.iter.(*replayIterator); ok {
+		if err := r.verify(); err != nil {
+			return nil, err
+		}
+	}
+	if err := gen.validateCoverage(); err != nil {
+		return nil, err
+	}
+	if err := gen.validateSwarm(); err != nil {
+		return nil, err
+	}
+	if err := gen.validateCapped(); err != nil {
+		return nil, err
+	}
+	if err := gen.validateReduction(); err != nil {
+		return nil, err
+	}
+	if err := gen.filterTags(); err != nil {
+		return nil, err
 	}
-	if len(gen.states) > math.MaxInt16 {
-		return nil, fmt.Errorf("max number of possible states %d. reduce by removing actions or partitions", math.MaxInt16)
+	_, replayed := gen.iter.(*replayIterator)
+	if gen.coverage != nil {
+		gen.iter = gen.coverage
+	} else if gen.swarm != nil {
+		gen.iter = gen.swarm
+	} else if gen.capped != nil {
+		gen.iter = gen.capped
+	}
+	if gen.custom != nil {
+		if gen.iter != nil {
+			return nil, errors.New("custom iterator can't be used with replay, coverage guided generation or swarm")
+		}
+		iter, err := gen.custom(gen)
+		if err != nil {
+			return nil, err
+		}
+		gen.iter = iter
 	}
 	if gen.iter == nil {
-		gen.iter = &productIterator{gen: gen, cnts: make([]int16, gen.stepLimit)}
+		length := gen.stepLimit
+		if gen.prefixes {
+			length = 1
+		}
+		gen.exhaustive = &productIterator{gen: gen, cnts: make([]int, length)}
+		gen.iter = gen.exhaustive
+		if gen.smoke > 0 {
+			gen.iter = newSmokeIterator(gen, gen.iter, gen.smoke)
+		}
+	} else if gen.smoke > 0 && !replayed {
+		return nil, errors.New("smoke test cases can be generated only before the exhaustive product")
+	} else if gen.order != nil && !replayed {
+		return nil, errors.New("order can be changed only for the exhaustive product")
 	}
-	if gen.percent > 0 && gen.percent <= 100 {
-		gen.iter = &randomIterator{
-			percent: gen.percent,
-			iter:    gen.iter,
-			rng:     rand.New(rand.NewSource(gen.seed)),
+	gen.iter = &countingIterator{Iterator: gen.iter, cnt: &gen.considered}
+	for _, sample := range gen.samples {
+		gen.iter = newRandomIterator(gen.iter, sample.percent, sample.seed)
+	}
+	if len(gen.constraints) > 0 && !replayed {
+		gen.iter = &constraintIterator{gen: gen, iter: gen.iter}
+	}
+	if gen.exhaustive != nil {
+		if gen.shards > 1 {
+			gen.iter = &shardIterator{iter: gen.iter, index: gen.shard, total: gen.shards}
+		}
+		if gen.symmetry {
+			gen.iter = &symmetryIterator{iter: gen.iter, symmetries: gen.symmetries()}
+		}
+	}
+	if gen.percent > 0 && gen.percent < 100 {
+		gen.iter = newRandomIterator(gen.iter, gen.percent, gen.seed)
+	}
+	if len(gen.corpus) > 0 {
+		cases, err := gen.loadCorpus()
+		if err != nil {
+			return nil, err
 		}
+		gen.iter = &corpusIterator{gen: gen, iter: gen.iter, cases: cases}
 	}
 	return gen, nil
 }
 
+// product returns every combination of actions, partitions and choices
+// of the delivery order.
+func (g *Generator) product(actions []int, choices int) []stepState {
+	var states []stepState
+	for _, i := range actions {
+		for j := range g.partitions {
+			for order := 0; order < choices; order++ {
+				states = append(states, stepState{actions: i, partition: j, order: order})
+			}
+		}
+	}
+	return states
+}
+
+// validateReplicas checks that partitions and actions reference only
+// configured replicas, otherwise such steps silently do nothing.
+func (g *Generator) validateReplicas() error {
+	if len(g.nodes) == 0 {
+		return errors.New("provide an option to configure replicas")
+	}
+	known := make(map[int]struct{}, len(g.nodes))
+	for _, id := range g.nodes {
+		if _, exist := known[id]; exist {
+			return fmt.Errorf("replica %d is configured more than once", id)
+		}
+		known[id] = struct{}{}
+	}
+	for _, network := range g.partitions {
+		for from, routes := range network {
+			if _, exist := known[from]; !exist {
+				return fmt.Errorf("partition %s references replica %d that is not configured", network, from)
+			}
+			for to := range routes {
+				if _, exist := known[to]; !exist {
+					return fmt.Errorf("partition %s references replica %d that is not configured", network, to)
+				}
+			}
+		}
+	}
+	validate := func(actions Actions) error {
+		for id := range actions {
+			if _, exist := known[id]; !exist {
+				return fmt.Errorf("actions %s reference replica %d that is not configured", actions, id)
+			}
+		}
+		return nil
+	}
+	for _, actions := range g.actions {
+		if err := validate(actions); err != nil {
+			return err
+		}
+	}
+	for step, overwrite := range g.stepActions {
+		for _, actions := range overwrite {
+			if err := validate(actions); err != nil {
+				return fmt.Errorf("step %d: %w", step, err)
+			}
+		}
+	}
+	return nil
+}
+
+// statesAt returns possible states of the step, starting from 0.
+func (g *Generator) statesAt(step int) []stepState {
+	if states, exist := g.stepStates[step]; exist {
+		return states
+	}
+	return g.states
+}
+
 type stepState struct {
 	actions, partition int
+	// delivery order, or index of the delivered message with a single delivery
+	order int
 }
 
 func (s stepState) String() string {
-	return fmt.Sprintf("(a=%d p=%d)", s.actions, s.partition)
+	return fmt.Sprintf("(a=%d p=%d o=%d)", s.actions, s.partition, s.order)
 }
 
 type Generator struct {
 	mu   sync.Mutex
-	iter tcIterator
+	iter Iterator
+	// nil if test cases are not generated as a product of the steps, e.g. replayed
+	exhaustive *productIterator
 
 	percent int
 	seed    int64
+	// predicates that must be true for every generated schedule
+	constraints []func([]Step) bool
+	// tags of the schedules, and tags of the generated test cases
+	tags      []*tag
+	tagFilter []string
+	// constructor of the custom iterator
+	custom func(*Generator) (Iterator, error)
+	// samples of the configured iterator, see WithSampling
+	samples []sample
+	// replay files that are executed before generated test cases
+	corpus []string
 
 	// total number of generated test cases
 	cnt int
+	// number of schedules produced by the exploration strategy
+	considered int
+	// number of test cases reported with Feedback
+	executed int
 
 	stepLimit int
-	// permutation of actions and partitions
+	// number of delivery orders explored in every step
+	orders int
+	// max number of pending messages in a single delivery mode. 0 if disabled.
+	single int
+
+	// generate schedules of every length up to the stepLimit
+	prefixes bool
+
+	// human readable names of partitions and actions, by index
+	names          []pendingName
+	partitionNames map[int]string
+	actionNames    map[int]string
+	// number of the last steps that heal the cluster
+	healing int
+	// number of diverse test cases generated before the exhaustive product
+	smoke int
+	// maps counters of the exhaustive product to states of the test case.
+	// nil if test cases are generated in the lexicographic order.
+	order func(cnts []int) []int
+	// skip schedules with commuting deliveries in the non-canonical order
+	reduction bool
+	// prefixes that are equivalent to explored prefixes
+	pruned map[string]struct{}
+
+	// max number of crashed replicas. 0 if not limited.
+	crashBudget int
+
+	// skip test cases that are symmetric to already generated test cases
+	symmetry bool
+
+	// generate only test cases that belong to the shard
+	shard, shards int
+
+	// test cases are generated by mutating test cases that reached new states
+	coverage *coverageIterator
+	// test cases are generated with random subsets of faults
+	swarm *swarmIterator
+	// test cases are sampled uniformly up to the max number
+	capped *cappedIterator
+
+	// Run stops after the budget
+	timeBudget time.Duration
+	// number of executions of every test case by Run, see WithRepeat
+	repeat int
+	// permutation of actions, partitions and orders
 	states []stepState
+	// states of the steps with own actions. keys start from 0.
+	stepStates map[int][]stepState
+	// actions of the steps, configured with WithStepActions. keys start from 1.
+	stepActions map[int][]Actions
 
 	nodes      []int
 	partitions []Partition
@@ -165,12 +739,119 @@ func (g *Generator) Next() *TestCase {
 	return g.iter.Current()
 }
 
+// NextBatch returns up to n test cases under a single lock acquisition,
+// or an empty batch once the generator is exhausted.
+func (g *Generator) NextBatch(n int) []*TestCase {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	batch := make([]*TestCase, 0, n)
+	for len(batch) < n && g.iter.Next() {
+		g.cnt++
+		batch = append(batch, g.iter.Current())
+	}
+	return batch
+}
+
+// All iterates over the test cases that are left in the generator.
+// Iteration stops early if generator failed, check Error after the loop.
+func (g *Generator) All() iter.Seq[*TestCase] {
+	return func(yield func(*TestCase) bool) {
+		for tc := g.Next(); tc != nil; tc = g.Next() {
+			if !yield(tc) {
+				return
+			}
+		}
+	}
+}
+
 func (g *Generator) Error() error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	return g.iter.Error()
 }
 
+// Total returns number of test cases in the exhaustive product of the steps,
+// including shorter schedules if enabled, before sampling, sharding or
+// symmetry reduction are applied.
+func (g *Generator) Total() *big.Int {
+	var (
+		total  = big.NewInt(0)
+		length = big.NewInt(1)
+	)
+	for step := 0; step < g.stepLimit; step++ {
+		length.Mul(length, big.NewInt(int64(len(g.statesAt(step)))))
+		if g.prefixes || step == g.stepLimit-1 {
+			total.Add(total, length)
+		}
+	}
+	return total
+}
+
+// Checkpoint encodes the position of the generator. Generator that is restored
+// from the checkpoint generates the same test cases that would be generated
+// after the checkpoint. With random sample a restored generator selects
+// a different sample.
+func (g *Generator) Checkpoint() ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exhaustive == nil {
+		return nil, errors.New("checkpoint is supported only for exhaustive generation")
+	}
+	ended := int64(0)
+	if g.exhaustive.ended {
+		ended = 1
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, [...]int64{int64(g.cnt), ended, int64(len(g.exhaustive.cnts))}); err != nil {
+		return nil, err
+	}
+	return appendStates(buf.Bytes(), g.exhaustive.cnts), nil
+}
+
+// Restore continues generation from the checkpoint. Generator must be
+// created with the same options as the generator that made the checkpoint.
+func (g *Generator) Restore(checkpoint []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exhaustive == nil {
+		return errors.New("checkpoint is supported only for exhaustive generation")
+	}
+	buf := bytes.NewReader(checkpoint)
+	var header [3]int64
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if header[2] != int64(g.stepLimit) && !(g.prefixes && header[2] > 0 && header[2] < int64(g.stepLimit)) {
+		return fmt.Errorf("checkpoint has %d steps, generator has %d", header[2], g.stepLimit)
+	}
+	cnts, err := readStates(buf, int(header[2]))
+	if err != nil {
+		return err
+	}
+	for i, cnt := range cnts {
+		if cnt >= len(g.statesAt(i)) {
+			return fmt.Errorf("state %d of step %d is out of range", cnt, i+1)
+		}
+	}
+	if buf.Len() != 0 {
+		return errCorrupted
+	}
+	g.cnt = int(header[0])
+	g.exhaustive.ended = header[1] == 1
+	g.exhaustive.cnts = cnts
+	return nil
+}
+
+// Sample returns percent of the sampled test cases and the seed.
+// Percent is 100 if all test cases are generated.
+func (g *Generator) Sample() (percent int, seed int64) {
+	if g.percent == 0 {
+		return 100, g.seed
+	}
+	return g.percent, g.seed
+}
+
 // Count returns total number of generated test cases.
 func (gen *Generator) Count() int {
 	gen.mu.Lock()
@@ -181,8 +862,41 @@ func (gen *Generator) Count() int {
 type TestCase struct {
 	gen *Generator
 
-	states []int16
+	states []int
 	step   int
+
+	// replicas that are crashed after the last step
+	crashed map[int]struct{}
+	// states reported with Cover
+	covered []uint64
+	// test case is returned to the pool by Release
+	pooled bool
+	// see Tracef
+	trace []string
+}
+
+var testCases = sync.Pool{New: func() any { return &TestCase{} }}
+
+// newTestCase returns a test case from the pool with a copy of states.
+func (g *Generator) newTestCase(states []int) *TestCase {
+	tc := testCases.Get().(*TestCase)
+	tc.gen = g
+	tc.states = append(tc.states[:0], states...)
+	tc.pooled = true
+	return tc
+}
+
+// Release returns the test case to the pool, so that its memory is reused
+// by the next test cases of the exhaustive product. Test case and its states
+// must not be used after the call. Run releases test cases that passed.
+// Test cases that are not released are collected as usual.
+func (t *TestCase) Release() {
+	if !t.pooled {
+		return
+	}
+	states := t.states[:0]
+	*t = TestCase{states: states, covered: t.covered[:0], trace: t.trace[:0]}
+	testCases.Put(t)
 }
 
 func (t *TestCase) Nodes() []int {
@@ -194,62 +908,286 @@ func (t *TestCase) Next() (Partition, Actions) {
 		return nil, nil
 	}
 
-	state := t.gen.states[t.states[t.step]]
+	state := t.gen.statesAt(t.step)[t.states[t.step]]
 	t.step++
 
-	return t.gen.partitions[state.partition], t.gen.actions[state.actions]
+	return t.gen.partitions[state.partition], t.updateCrashed(t.gen.actions[state.actions])
 }
 
+// Steps iterates over the remaining steps of the test case. Order and Delivery
+// can be used inside the loop for the current step. See Schedule to inspect
+// the steps without executing them.
+func (t *TestCase) Steps() iter.Seq2[Partition, Actions] {
+	return func(yield func(Partition, Actions) bool) {
+		for {
+			network, actions := t.Next()
+			if network == nil || actions == nil || !yield(network, actions) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns number of steps in the test case.
+func (t *TestCase) Len() int {
+	return len(t.states)
+}
+
+// Schedule returns every step of the test case, regardless of the current step.
+// Actions are not adjusted for the crash budget and state of the replicas.
+func (t *TestCase) Schedule() []Step {
+	return t.gen.appendSteps(nil, t.states)
+}
+
+// Reset rewinds the test case to the first step, so that it can be executed again.
+func (t *TestCase) Reset() {
+	t.step = 0
+	t.crashed = nil
+	t.covered = t.covered[:0]
+	t.trace = t.trace[:0]
+}
+
+// clone returns a copy of the test case that doesn't share memory with it.
+func (t *TestCase) clone() *TestCase {
+	clone := &TestCase{
+		gen:     t.gen,
+		states:  append([]int(nil), t.states...),
+		step:    t.step,
+		covered: append([]uint64(nil), t.covered...),
+		trace:   append([]string(nil), t.trace...),
+	}
+	if t.crashed != nil {
+		clone.crashed = make(map[int]struct{}, len(t.crashed))
+		for id := range t.crashed {
+			clone.crashed[id] = struct{}{}
+		}
+	}
+	return clone
+}
+
+// validate that every state exists in the generator.
+func (t *TestCase) validate() error {
+	// shorter test cases are generated with WithShorterSchedules and by Shrink
+	if len(t.states) == 0 || len(t.states) > t.gen.stepLimit {
+		return fmt.Errorf("test case has %d steps, generator has %d", len(t.states), t.gen.stepLimit)
+	}
+	for i, state := range t.states {
+		if state < 0 || state >= len(t.gen.statesAt(i)) {
+			return fmt.Errorf("state %d of step %d is out of range", state, i+1)
+		}
+	}
+	return nil
+}
+
+// updateCrashed tracks crashed replicas. Returns actions without crashes that
+// exceed the budget, and without recoveries of replicas that are not crashed.
+func (t *TestCase) updateCrashed(actions Actions) Actions {
+	var rst Actions
+	for id, action := range actions {
+		if action&(ActionCrash|ActionRecover) == 0 {
+			continue
+		}
+		if rst == nil {
+			rst = make(Actions, len(actions))
+			for id, action := range actions {
+				rst[id] = action
+			}
+		}
+		if t.crashed == nil {
+			t.crashed = map[int]struct{}{}
+		}
+		_, crashed := t.crashed[id]
+		switch {
+		case action&ActionCrash > 0 && !crashed &&
+			(t.gen.crashBudget == 0 || len(t.crashed) < t.gen.crashBudget):
+			t.crashed[id] = struct{}{}
+		case action&ActionRecover > 0 && crashed:
+			delete(t.crashed, id)
+		default:
+			rst[id] &^= ActionCrash | ActionRecover
+		}
+	}
+	if rst == nil {
+		return actions
+	}
+	return rst
+}
+
+// Crashed returns true if the replica is crashed after the step that
+// was returned by the last Next.
+func (t *TestCase) Crashed(replica int) bool {
+	_, exist := t.crashed[replica]
+	return exist
+}
+
+// Order returns delivery order of the step that was returned by the last Next.
+func (t *TestCase) Order() int {
+	if t.step == 0 || t.gen.single > 0 {
+		return 0
+	}
+	return t.gen.statesAt(t.step - 1)[t.states[t.step-1]].order
+}
+
+// Delivery returns an index of the message that is delivered in the step
+// that was returned by the last Next. False if the whole network round is delivered.
+func (t *TestCase) Delivery() (int, bool) {
+	if t.step == 0 || t.gen.single == 0 {
+		return 0, false
+	}
+	return t.gen.statesAt(t.step - 1)[t.states[t.step-1]].order, true
+}
+
+// Rand returns a source of randomness for the step that was returned by the
+// last Next, for example to select messages that are lost on lossy links.
+// It is seeded with the states of the executed steps, therefore replayed test
+// case and every test case with the same prefix of steps make the same choices.
+// See Seed for choices that depend on the whole test case.
+func (t *TestCase) Rand() *rand.Rand {
+	h := fnv.New64a()
+	h.Write(appendStates(nil, t.states[:t.step]))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// Seed is derived from every step of the test case, regardless of the current
+// step. Runner that needs randomness, for example to break ties, can seed
+// its source with it, so that replayed test case makes the same choices.
+func (t *TestCase) Seed() int64 {
+	h := fnv.New64a()
+	h.Write(appendStates(nil, t.states))
+	return int64(h.Sum64())
+}
+
+// String describes every step of the test case, regardless of the current step.
 func (t *TestCase) String() string {
 	var buf bytes.Buffer
-	for i := 0; i <= t.step && i < len(t.states); i++ {
-		state := t.gen.states[t.states[i]]
-		fmt.Fprintf(&buf, "step %d: %s %s\n", i+1,
-			t.gen.partitions[state.partition],
-			t.gen.actions[state.actions],
+	for i := range t.states {
+		state := t.gen.statesAt(i)[t.states[i]]
+		fmt.Fprintf(&buf, "step %d: %s %s", i+1,
+			t.gen.partitionString(state.partition),
+			t.gen.actionsString(state.actions),
 		)
+		if t.gen.single > 0 {
+			fmt.Fprintf(&buf, " Deliver(%d)", state.order)
+		} else if t.gen.orders > 1 {
+			fmt.Fprintf(&buf, " Order(%d)", state.order)
+		}
+		buf.WriteString("\n")
 	}
 	return buf.String()
 }
 
+// Marshal encodes number of steps and the state of every step as uvarints.
 func (t *TestCase) Marshal() ([]byte, error) {
-	var buf bytes.Buffer
-	if err := binary.Write(&buf, binary.LittleEndian, int64(len(t.states))); err != nil {
-		return nil, err
+	buf := binary.AppendUvarint(nil, uint64(len(t.states)))
+	return appendStates(buf, t.states), nil
+}
+
+// Unmarshal decodes test case that was encoded with Marshal.
+func (t *TestCase) Unmarshal(b []byte) error {
+	buf := bytes.NewReader(b)
+	lth, err := binary.ReadUvarint(buf)
+	if err != nil {
+		return err
 	}
-	if err := binary.Write(&buf, binary.LittleEndian, t.states); err != nil {
-		return nil, err
+	if lth > uint64(buf.Len()) {
+		return errCorrupted
 	}
-	return buf.Bytes(), nil
+	t.states, err = readStates(buf, int(lth))
+	if err != nil {
+		return err
+	}
+	if buf.Len() != 0 {
+		return errCorrupted
+	}
+	return nil
 }
 
-func (t *TestCase) Unmarshal(b []byte) error {
-	buf := bytes.NewBuffer(b)
+// unmarshalLegacy decodes test case that was encoded as int64 number of steps
+// followed by int16 states, before states were encoded as uvarints.
+func (t *TestCase) unmarshalLegacy(b []byte) error {
+	buf := bytes.NewReader(b)
 	var lth int64
 	if err := binary.Read(buf, binary.LittleEndian, &lth); err != nil {
 		return err
 	}
-	t.states = make([]int16, lth)
-	if err := binary.Read(buf, binary.LittleEndian, t.states); err != nil {
+	if lth < 0 || 2*lth != int64(buf.Len()) {
+		return errCorrupted
+	}
+	states := make([]int16, lth)
+	if err := binary.Read(buf, binary.LittleEndian, states); err != nil {
 		return err
 	}
+	t.states = make([]int, lth)
+	for i, state := range states {
+		if state < 0 {
+			return errCorrupted
+		}
+		t.states[i] = int(state)
+	}
 	return nil
 }
 
-type Partition map[int]map[int]struct{}
+func appendStates(buf []byte, states []int) []byte {
+	for _, state := range states {
+		buf = binary.AppendUvarint(buf, uint64(state))
+	}
+	return buf
+}
+
+func readStates(buf io.ByteReader, lth int) ([]int, error) {
+	states := make([]int, lth)
+	for i := range states {
+		state, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return nil, err
+		}
+		if state > math.MaxInt32 {
+			return nil, errCorrupted
+		}
+		states[i] = int(state)
+	}
+	return states, nil
+}
+
+// Partition is a state of the network. Replica can send messages only
+// to the replicas in its routes.
+type Partition map[int]map[int]Link
 
+// Link is a route between two replicas.
+type Link struct {
+	// Delay is a number of steps before messages are delivered. Messages on
+	// the link with 0 delay are delivered in the same step.
+	Delay int
+	// Loss is a probability in percents that a message is lost.
+	Loss int
+}
+
+// Add connects replicas in both directions.
 func (p Partition) Add(from, to int) {
-	p.add(from, to)
-	p.add(to, from)
+	p.AddOneWay(from, to)
+	p.AddOneWay(to, from)
+}
+
+// AddOneWay allows messages from one replica to the other,
+// but not in the opposite direction.
+func (p Partition) AddOneWay(from, to int) {
+	p.AddLink(from, to, Link{})
 }
 
-func (p Partition) add(from, to int) {
+// AddDelayed allows messages from one replica to the other that are
+// delivered after delay steps.
+func (p Partition) AddDelayed(from, to, delay int) {
+	p.AddLink(from, to, Link{Delay: delay})
+}
+
+// AddLink allows messages from one replica to the other over the link.
+func (p Partition) AddLink(from, to int, link Link) {
 	routes, ok := p[from]
 	if !ok {
-		routes = map[int]struct{}{}
+		routes = map[int]Link{}
 		p[from] = routes
 	}
-	routes[to] = struct{}{}
+	routes[to] = link
 }
 
 // Reachable returns true if route is not blocked.
@@ -262,37 +1200,199 @@ func (p Partition) Reachable(from, to int) bool {
 	return ok
 }
 
+// Delay returns number of steps before messages on the route are delivered.
+// 0 if route is blocked.
+func (p Partition) Delay(from, to int) int {
+	return p[from][to].Delay
+}
+
+// Loss returns probability in percents that a message on the route is lost.
+// 0 if route is blocked.
+func (p Partition) Loss(from, to int) int {
+	return p[from][to].Loss
+}
+
+func (p Partition) clone() Partition {
+	rst := make(Partition, len(p))
+	for from, routes := range p {
+		for to, link := range routes {
+			rst.AddLink(from, to, link)
+		}
+	}
+	return rst
+}
+
+// String lists links sorted by source and destination.
 func (p Partition) String() string {
 	var b bytes.Buffer
 	b.WriteString("Routes(")
-	for from, dest := range p {
-		for to := range dest {
-			fmt.Fprintf(&b, "%d=>%d,", from, to)
+	for _, from := range sortedKeys(p) {
+		dest := p[from]
+		for _, to := range sortedKeys(dest) {
+			link := dest[to]
+			fmt.Fprintf(&b, "%d=>%d", from, to)
+			if link.Delay > 0 {
+				fmt.Fprintf(&b, "+%d", link.Delay)
+			}
+			if link.Loss > 0 {
+				fmt.Fprintf(&b, "~%d%%", link.Loss)
+			}
+			b.WriteString(",")
 		}
 	}
 	b.WriteString(")")
 	return b.String()
 }
 
-type Actions map[int]bool
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// Action is a set of events that happen with a replica during the step.
+// Higher bits of the action store a value that is proposed by the leader,
+// and a number of ticks if it is not the default.
+type Action uint32
+
+const (
+	// actionValueShift is a position of the proposed value in the Action.
+	actionValueShift = 8
+	// actionTicksShift is a position of the number of ticks in the Action.
+	// Ticks are stored with an offset of 1, so that 0 is a default single tick.
+	actionTicksShift = 16
+	actionFieldMask  = 1<<8 - 1
+)
+
+// Value returns a value that is proposed by the leader. 0 if value is not assigned.
+func (a Action) Value() int {
+	return int(a >> actionValueShift & actionFieldMask)
+}
+
+// WithValue returns a copy of the action with proposed value.
+func (a Action) WithValue(value int) Action {
+	return a&^(actionFieldMask<<actionValueShift) | Action(value)<<actionValueShift
+}
+
+// Ticks returns number of times the clock of the replica advances in the step.
+// Clock advances once unless it is skewed with WithTicks.
+func (a Action) Ticks() int {
+	if ticks := int(a >> actionTicksShift & actionFieldMask); ticks > 0 {
+		return ticks - 1
+	}
+	return 1
+}
+
+// WithTicks returns a copy of the action where clock advances ticks times.
+func (a Action) WithTicks(ticks int) Action {
+	return a&^(actionFieldMask<<actionTicksShift) | Action(ticks+1)<<actionTicksShift
+}
+
+// isSkewed returns true if the number of ticks is not the default.
+func (a Action) isSkewed() bool {
+	return a>>actionTicksShift&actionFieldMask > 0
+}
+
+const (
+	// ActionLead replica proposes a value.
+	ActionLead Action = 1 << iota
+	// ActionByzantine replica doesn't follow the protocol.
+	ActionByzantine
+	// ActionDrop messages to the replica that can't be delivered are lost.
+	ActionDrop
+	// ActionCrash replica stops and loses everything except persisted state.
+	ActionCrash
+	// ActionRecover crashed replica restarts from persisted state.
+	ActionRecover
+)
+
+var actionString = [...]string{
+	"leader",
+	"byzantine",
+	"drop",
+	"crash",
+	"recover",
+}
+
+type Actions map[int]Action
 
 func (a Actions) IsLeader(replica int) bool {
-	return a[replica]
+	return a[replica]&ActionLead > 0
+}
+
+func (a Actions) IsByzantine(replica int) bool {
+	return a[replica]&ActionByzantine > 0
+}
+
+func (a Actions) IsDropped(replica int) bool {
+	return a[replica]&ActionDrop > 0
+}
+
+func (a Actions) IsCrashed(replica int) bool {
+	return a[replica]&ActionCrash > 0
+}
+
+func (a Actions) IsRecovered(replica int) bool {
+	return a[replica]&ActionRecover > 0
+}
+
+// Value returns a value that is proposed by the leader. 0 if value is not assigned.
+func (a Actions) Value(replica int) int {
+	return a[replica].Value()
+}
+
+// Ticks returns number of times the clock of the replica advances in the step.
+func (a Actions) Ticks(replica int) int {
+	return a[replica].Ticks()
 }
 
 func (a Actions) String() string {
+	ids := make([]int, 0, len(a))
+	for id := range a {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
 	var buf bytes.Buffer
 	buf.WriteString("Cluster(")
-	for id := range a {
-		fmt.Fprintf(&buf, "leader=%d", id)
+	first := true
+	for _, id := range ids {
+		for i, name := range actionString {
+			if a[id]&(1<<i) == 0 {
+				continue
+			}
+			if !first {
+				buf.WriteString(",")
+			}
+			first = false
+			fmt.Fprintf(&buf, "%s=%d", name, id)
+		}
+		if value := a[id].Value(); value > 0 {
+			fmt.Fprintf(&buf, ",value[%d]=%d", id, value)
+		}
+		if a[id].isSkewed() {
+			if !first {
+				buf.WriteString(",")
+			}
+			first = false
+			fmt.Fprintf(&buf, "ticks[%d]=%d", id, a[id].Ticks())
+		}
 	}
 	buf.WriteString(")")
 	return buf.String()
 }
 
-type tcIterator interface {
+// Iterator produces test cases for the Generator. Custom exploration
+// strategies can be plugged in with WithIterator.
+type Iterator interface {
+	// Next advances to the next test case. False if iterator is exhausted or failed.
 	Next() bool
+	// Error returns an error that stopped the iterator.
 	Error() error
+	// Current returns a test case after Next returned true.
 	Current() *TestCase
 }
 
@@ -301,31 +1401,49 @@ type productIterator struct {
 
 	ended bool
 	// permutation counters
-	cnts    []int16
+	cnts    []int
 	current *TestCase
 }
 
 func (pi *productIterator) Next() bool {
-	if pi.ended {
-		return false
+	for !pi.ended {
+		tc := pi.gen.newTestCase(pi.cnts)
+		pi.advance(len(pi.cnts) - 1)
+		if pruned := pi.gen.prunedPrefix(tc.states); pruned > 0 {
+			// every test case with the same prefix is skipped
+			if len(pi.cnts) == len(tc.states) && prefixKey(pi.cnts[:pruned]) == prefixKey(tc.states[:pruned]) {
+				pi.advance(pruned - 1)
+			}
+			tc.Release()
+			continue
+		}
+		if pi.gen.order != nil {
+			tc.states = pi.gen.order(tc.states)
+		}
+		pi.current = tc
+		return true
 	}
+	return false
+}
 
-	states := make([]int16, pi.gen.stepLimit)
-	copy(states, pi.cnts)
-
-	for i := len(pi.cnts) - 1; i >= 0; i-- {
+// advance increments the counter of the step, and resets counters of the later steps.
+func (pi *productIterator) advance(step int) {
+	for i := step + 1; i < len(pi.cnts); i++ {
+		pi.cnts[i] = 0
+	}
+	for i := step; i >= 0; i-- {
 		pi.cnts[i]++
-		if pi.cnts[i] < int16(len(pi.gen.states)) {
+		if pi.cnts[i] < len(pi.gen.statesAt(i)) {
 			break
 		}
 		pi.cnts[i] = 0
-		if i == 0 {
+		if i == 0 && len(pi.cnts) < pi.gen.stepLimit {
+			// continue with schedules that are one step longer
+			pi.cnts = make([]int, len(pi.cnts)+1)
+		} else if i == 0 {
 			pi.ended = true
 		}
 	}
-
-	pi.current = &TestCase{gen: pi.gen, states: states}
-	return true
 }
 
 func (pi *productIterator) Current() *TestCase {
@@ -351,6 +1469,16 @@ type replayIterator struct {
 	current *TestCase
 }
 
+// verify that the replay file was recorded with the same configuration.
+// Legacy files without a fingerprint are accepted, but every state is checked
+// to be in range.
+func (r *replayIterator) verify() error {
+	if r.r.fingerprint != nil && !bytes.Equal(r.r.fingerprint, r.gen.fingerprint()) {
+		return fmt.Errorf("replay file %s was recorded with a different generator configuration", r.r.Name())
+	}
+	return nil
+}
+
 func (r *replayIterator) Next() bool {
 	if r.err != nil {
 		return false
@@ -358,6 +1486,7 @@ func (r *replayIterator) Next() bool {
 	r.current, r.err = r.r.Read()
 	if r.current != nil {
 		r.current.gen = r.gen
+		r.err = r.current.validate()
 	}
 	return r.err == nil
 }
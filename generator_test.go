@@ -0,0 +1,856 @@
+package paxos
+
+import (
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func collectCases(t *testing.T, opts ...GenOption) [][]byte {
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	var rst [][]byte
+	for tc := gen.Next(); tc != nil; tc = gen.Next() {
+		buf, err := tc.Marshal()
+		require.NoError(t, err)
+		rst = append(rst, buf)
+	}
+	require.NoError(t, gen.Error())
+	return rst
+}
+
+func TestRandomSample(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	}
+	all := collectCases(t, opts...)
+	first := collectCases(t, append(opts, WithRandomSample(10, 7))...)
+	second := collectCases(t, append(opts, WithRandomSample(10, 7))...)
+	require.Equal(t, first, second)
+	require.NotEmpty(t, first)
+	require.Less(t, len(first), len(all))
+
+	require.Len(t, collectCases(t, append(opts, WithRandomSample(100, 7))...), len(all))
+
+	for _, percent := range []int{0, -1, 101} {
+		_, err := NewGen(append(opts, WithRandomSample(percent, 7))...)
+		require.Error(t, err)
+	}
+}
+
+func TestAllPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		replicas  []int
+		maxGroups int
+		expect    int
+	}{
+		{replicas: []int{1, 2, 3}, expect: 5},
+		{replicas: []int{1, 2, 3, 4, 5}, expect: 52},
+		{replicas: []int{1, 2, 3, 4, 5}, maxGroups: 1, expect: 1},
+		{replicas: []int{1, 2, 3, 4, 5}, maxGroups: 2, expect: 16},
+	} {
+		gen, err := NewGen(
+			WithReplicas(tc.replicas...),
+			WithAllPartitions(tc.maxGroups),
+			WithLeaders(1),
+			WithSteps(1),
+		)
+		require.NoError(t, err)
+		require.Len(t, gen.partitions, tc.expect)
+	}
+
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(2),
+		WithLeaders(1),
+	)
+	require.NoError(t, err)
+	// {1, 2, 3}, {1, 2} {3}, {1, 3} {2}, {1} {2, 3}
+	require.Len(t, gen.partitions, 4)
+	require.True(t, gen.partitions[0].Reachable(1, 3))
+	require.True(t, gen.partitions[1].Reachable(1, 2))
+	require.False(t, gen.partitions[1].Reachable(1, 3))
+	require.False(t, gen.partitions[3].Reachable(1, 2))
+	require.True(t, gen.partitions[3].Reachable(3, 2))
+
+	_, err = NewGen(WithAllPartitions(2), WithReplicas(1, 2))
+	require.Error(t, err)
+}
+
+func TestLinkFailures(t *testing.T) {
+	for _, tc := range []struct {
+		maxFailed int
+		expect    int
+	}{
+		{maxFailed: 0, expect: 1},
+		// 1 + 10
+		{maxFailed: 1, expect: 11},
+		// 1 + 10 + 45
+		{maxFailed: 2, expect: 56},
+	} {
+		gen, err := NewGen(
+			WithReplicas(1, 2, 3, 4, 5),
+			WithLinkFailures(tc.maxFailed),
+			WithLeaders(1),
+		)
+		require.NoError(t, err)
+		require.Len(t, gen.partitions, tc.expect)
+	}
+
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithLinkFailures(1),
+		WithLeaders(1),
+	)
+	require.NoError(t, err)
+	require.Len(t, gen.partitions, 4)
+	// 1 and 2 can reach each other only through 3
+	bridge := gen.partitions[1]
+	require.False(t, bridge.Reachable(1, 2))
+	require.False(t, bridge.Reachable(2, 1))
+	require.True(t, bridge.Reachable(1, 3))
+	require.True(t, bridge.Reachable(3, 2))
+
+	_, err = NewGen(WithReplicas(1, 2, 3), WithLinkFailures(4))
+	require.Error(t, err)
+}
+
+func TestSlowLinks(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2),
+		WithExplicitPartitions([][]int{{1, 2}}, [][]int{{1}, {2}}),
+		WithSlowLinks(2, 1, 3),
+		WithLeaders(1),
+	)
+	require.NoError(t, err)
+	// 2 base partitions, 4 with a single slow link, 4 with both links slow
+	require.Len(t, gen.partitions, 10)
+	both := gen.partitions[4]
+	require.True(t, both.Reachable(1, 2))
+	require.Equal(t, 1, both.Delay(1, 2))
+	require.Equal(t, 3, both.Delay(2, 1))
+	require.Zero(t, gen.partitions[0].Delay(1, 2))
+	require.Zero(t, gen.partitions[1].Delay(1, 2))
+
+	slow := Partition{}
+	slow.AddDelayed(1, 2, 3)
+	require.Equal(t, "Routes(1=>2+3,)", slow.String())
+
+	_, err = NewGen(WithReplicas(1, 2), WithSlowLinks(1, 1))
+	require.Error(t, err)
+	_, err = NewGen(WithReplicas(1, 2), WithAllPartitions(0), WithSlowLinks(1, 0))
+	require.Error(t, err)
+}
+
+func TestLossyLinks(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1, 2}, {3}}),
+		WithLossyLinks(10, 50),
+		WithLeaders(1),
+		WithSteps(2),
+	)
+	require.NoError(t, err)
+	require.Len(t, gen.partitions, 6)
+	require.Zero(t, gen.partitions[0].Loss(1, 2))
+	require.Equal(t, 50, gen.partitions[3].Loss(1, 2))
+	require.Equal(t, 10, gen.partitions[4].Loss(2, 1))
+	require.False(t, gen.partitions[4].Reachable(1, 3))
+
+	lossy := Partition{}
+	lossy.AddLink(1, 2, Link{Delay: 1, Loss: 10})
+	require.Equal(t, "Routes(1=>2+1~10%,)", lossy.String())
+
+	// the same prefix of steps makes the same random choices
+	rands := map[int]int{}
+	for tc := range gen.All() {
+		tc.Next()
+		first := tc.Rand().Int()
+		if expected, exist := rands[tc.states[0]]; exist {
+			require.Equal(t, expected, first)
+		}
+		rands[tc.states[0]] = first
+		tc.Next()
+		require.NotEqual(t, first, tc.Rand().Int())
+	}
+	require.Len(t, rands, 12)
+
+	_, err = NewGen(WithReplicas(1, 2), WithAllPartitions(0), WithLossyLinks(101))
+	require.Error(t, err)
+	_, err = NewGen(WithReplicas(1, 2), WithLossyLinks(10))
+	require.Error(t, err)
+}
+
+func TestPartitionAlgebra(t *testing.T) {
+	nodes := []int{1, 2, 3, 4, 5}
+	full := FullyConnected(nodes...)
+	require.True(t, equalPartitions(full, groupPartition([][]int{nodes})))
+
+	split := MajorityMinority(nodes...)
+	require.True(t, equalPartitions(split, groupPartition([][]int{{1, 2, 3}, {4, 5}})))
+	require.True(t, equalPartitions(split.Invert(nodes...).Invert(nodes...), split))
+	require.True(t, split.Invert(nodes...).Reachable(3, 4))
+	require.False(t, split.Invert(nodes...).Reachable(4, 5))
+	require.True(t, equalPartitions(split.Union(split.Invert(nodes...)), full))
+
+	isolated := full.IsolateNode(1)
+	require.True(t, equalPartitions(isolated, groupPartition([][]int{{1}, {2, 3, 4, 5}})))
+	require.True(t, full.Reachable(1, 2), "combinators don't modify the partition")
+
+	cut := full.Without(1, 2)
+	require.False(t, cut.Reachable(1, 2))
+	require.False(t, cut.Reachable(2, 1))
+	require.True(t, cut.Reachable(1, 3))
+	oneWay := full.WithoutOneWay(1, 2)
+	require.False(t, oneWay.Reachable(1, 2))
+	require.True(t, oneWay.Reachable(2, 1))
+
+	opts := []GenOption{WithReplicas(nodes...), WithLeaders(1), WithSteps(2)}
+	require.Equal(t,
+		collectCases(t, append(opts, WithExplicitPartitions([][]int{nodes}, [][]int{{1, 2, 3}, {4, 5}}, [][]int{{1}, {2, 3, 4, 5}}))...),
+		collectCases(t, append(opts, WithPartitions(full, split, isolated))...),
+	)
+}
+
+func TestRandomPartitions(t *testing.T) {
+	generate := func(seed int64) []Partition {
+		gen, err := NewGen(
+			WithReplicas(1, 2, 3, 4, 5),
+			WithRandomPartitions(20, seed),
+			WithLeaders(1),
+		)
+		require.NoError(t, err)
+		require.Len(t, gen.partitions, 20)
+		return gen.partitions
+	}
+	first := generate(11)
+	require.Equal(t, first, generate(11))
+	require.NotEqual(t, first, generate(12))
+	for _, partition := range first {
+		for from := 1; from <= 5; from++ {
+			for to := 1; to <= 5; to++ {
+				require.Equal(t, partition.Reachable(from, to), partition.Reachable(to, from))
+			}
+		}
+	}
+
+	_, err := NewGen(WithReplicas(1, 2, 3), WithRandomPartitions(0, 1))
+	require.Error(t, err)
+}
+
+func TestOneWayLinkFailures(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithOneWayLinkFailures(1),
+		WithLeaders(1),
+	)
+	require.NoError(t, err)
+	require.Len(t, gen.partitions, 7)
+	oneway := gen.partitions[1]
+	require.False(t, oneway.Reachable(1, 2))
+	require.True(t, oneway.Reachable(2, 1))
+
+	_, err = NewGen(WithReplicas(1, 2, 3), WithOneWayLinkFailures(7))
+	require.Error(t, err)
+}
+
+func TestDeliveryOrders(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2}}),
+		WithReplicas(1, 2),
+		WithLeaders(1),
+		WithDeliveryOrders(3),
+		WithSteps(2),
+	)
+	require.NoError(t, err)
+	orders := map[[2]int]struct{}{}
+	for tc := gen.Next(); tc != nil; tc = gen.Next() {
+		var order [2]int
+		for i := range order {
+			_, actions := tc.Next()
+			require.NotNil(t, actions)
+			order[i] = tc.Order()
+		}
+		orders[order] = struct{}{}
+	}
+	// 2 actions and 3 orders in each of 2 steps
+	require.Equal(t, 36, gen.Count())
+	require.Len(t, orders, 9)
+
+	_, err = NewGen(WithDeliveryOrders(0))
+	require.Error(t, err)
+}
+
+func TestCrashBudget(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1),
+		WithCrashes(1, 2),
+		WithCrashBudget(1),
+	)
+	require.NoError(t, err)
+	// no leader, leader 1, recover 1 and 2 for each, crash 1 and 2 for each
+	require.Len(t, gen.actions, 10)
+	tc := &TestCase{gen: gen}
+
+	actions := tc.updateCrashed(Actions{1: ActionLead | ActionCrash})
+	require.True(t, actions.IsCrashed(1))
+	require.True(t, tc.Crashed(1))
+
+	actions = tc.updateCrashed(Actions{2: ActionCrash})
+	require.False(t, actions.IsCrashed(2), "budget is exhausted")
+	require.False(t, tc.Crashed(2))
+
+	actions = tc.updateCrashed(Actions{2: ActionRecover})
+	require.False(t, actions.IsRecovered(2), "replica is not crashed")
+
+	actions = tc.updateCrashed(Actions{1: ActionRecover})
+	require.True(t, actions.IsRecovered(1))
+	require.False(t, tc.Crashed(1))
+}
+
+func TestStepActions(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2}}),
+		WithReplicas(1, 2),
+		WithLeaders(1, 2),
+		WithStepActions(2, Actions{}),
+		WithStepActions(3, Actions{}, Actions{2: ActionLead}),
+		WithSteps(3),
+	)
+	require.NoError(t, err)
+	for tc := gen.Next(); tc != nil; tc = gen.Next() {
+		tc.Next()
+		_, actions := tc.Next()
+		require.Empty(t, actions)
+		_, actions = tc.Next()
+		require.False(t, actions.IsLeader(1))
+	}
+	require.NoError(t, gen.Error())
+	require.Equal(t, 6, gen.Count())
+
+	_, err = NewGen(
+		WithExplicitPartitions([][]int{{1, 2}}),
+		WithReplicas(1, 2),
+		WithLeaders(1),
+		WithStepActions(3, Actions{}),
+		WithSteps(2),
+	)
+	require.Error(t, err)
+}
+
+func TestLeaderSets(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaderSets(2, 1, 2, 3),
+		WithSteps(1),
+	)
+	require.NoError(t, err)
+	var rst []string
+	for _, actions := range gen.actions {
+		rst = append(rst, actions.String())
+	}
+	require.Equal(t, []string{
+		"Cluster()",
+		"Cluster(leader=1)",
+		"Cluster(leader=2)",
+		"Cluster(leader=3)",
+		"Cluster(leader=1,leader=2)",
+		"Cluster(leader=1,leader=3)",
+		"Cluster(leader=2,leader=3)",
+	}, rst)
+
+	gen, err = NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaderSets(5, 1, 2, 3),
+	)
+	require.NoError(t, err)
+	require.Len(t, gen.actions, 8)
+}
+
+func TestProposedValues(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2}}),
+		WithReplicas(1, 2),
+		WithLeaderSets(2, 1, 2),
+		WithProposedValues(2),
+		WithSteps(1),
+	)
+	require.NoError(t, err)
+	var rst []string
+	for _, actions := range gen.actions {
+		rst = append(rst, actions.String())
+	}
+	require.Equal(t, []string{
+		"Cluster()",
+		"Cluster(leader=1,value[1]=1)",
+		"Cluster(leader=1,value[1]=2)",
+		"Cluster(leader=2,value[2]=1)",
+		"Cluster(leader=2,value[2]=2)",
+		"Cluster(leader=1,value[1]=1,leader=2,value[2]=1)",
+		"Cluster(leader=1,value[1]=1,leader=2,value[2]=2)",
+		"Cluster(leader=1,value[1]=2,leader=2,value[2]=1)",
+		"Cluster(leader=1,value[1]=2,leader=2,value[2]=2)",
+	}, rst)
+	require.Equal(t, 2, gen.actions[2].Value(1))
+	require.True(t, gen.actions[2].IsLeader(1))
+}
+
+func TestGeneratorCheckpoint(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	all := collectCases(t, opts...)
+
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NotNil(t, gen.Next())
+	}
+	checkpoint, err := gen.Checkpoint()
+	require.NoError(t, err)
+
+	restored, err := NewGen(opts...)
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(checkpoint))
+	var rest [][]byte
+	for tc := restored.Next(); tc != nil; tc = restored.Next() {
+		buf, err := tc.Marshal()
+		require.NoError(t, err)
+		rest = append(rest, buf)
+	}
+	require.Equal(t, all[100:], rest)
+	require.Equal(t, len(all), restored.Count())
+
+	other, err := NewGen(append(opts, WithSteps(4))...)
+	require.NoError(t, err)
+	require.Error(t, other.Restore(checkpoint))
+}
+
+func TestShards(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	all := collectCases(t, opts...)
+	seen := map[string]int{}
+	for shard := 0; shard < 3; shard++ {
+		cases := collectCases(t, append(opts, WithShard(shard, 3))...)
+		require.NotEmpty(t, cases)
+		require.Less(t, len(cases), len(all))
+		for _, tc := range cases {
+			seen[string(tc)]++
+		}
+	}
+	require.Len(t, seen, len(all))
+	for _, cnt := range seen {
+		require.Equal(t, 1, cnt)
+	}
+
+	_, err := NewGen(append(opts, WithShard(3, 3))...)
+	require.Error(t, err)
+}
+
+func TestTotal(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}, {4, 5}},
+			[][]int{{1, 2}, {3, 4, 5}},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(9),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "10077696", gen.Total().String())
+
+	gen, err = NewGen(
+		WithReplicas(1, 2, 3, 4, 5),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3, 4, 5),
+		WithStepActions(2, Actions{}),
+		WithSteps(30),
+	)
+	require.NoError(t, err)
+	// 52 partitions and 6 actions in every step, except the second
+	expect := new(big.Int).Exp(big.NewInt(52*6), big.NewInt(29), nil)
+	require.Equal(t, expect.Mul(expect, big.NewInt(52)), gen.Total())
+}
+
+func TestRangeOverCases(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	expected := collectCases(t, opts...)
+
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	var cases [][]byte
+	for tc := range gen.All() {
+		steps := 0
+		for network, actions := range tc.Steps() {
+			require.NotNil(t, network)
+			require.NotNil(t, actions)
+			steps++
+		}
+		require.Equal(t, 3, steps)
+		buf, err := tc.Marshal()
+		require.NoError(t, err)
+		cases = append(cases, buf)
+	}
+	require.NoError(t, gen.Error())
+	require.Equal(t, expected, cases)
+
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	for range gen.All() {
+		break
+	}
+	require.Equal(t, 1, gen.Count())
+}
+
+func TestSampling(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sampling.test")
+	replay, err := NewReplay(path)
+	require.NoError(t, err)
+	for tc := range gen.All() {
+		require.NoError(t, replay.Write(tc))
+	}
+	require.NoError(t, replay.Close())
+
+	replayed := func(opt func(GenOption) GenOption) [][]byte {
+		replay, err := NewReplayReader(path)
+		require.NoError(t, err)
+		defer replay.Close()
+		return collectCases(t, append(opts, opt(WithReplay(replay)))...)
+	}
+	all := replayed(func(opt GenOption) GenOption { return opt })
+	require.Len(t, all, 1296)
+	sampled := replayed(func(opt GenOption) GenOption { return WithSampling(opt, 10, 1) })
+	require.InDelta(t, 130, len(sampled), 40)
+	require.Subset(t, all, sampled)
+	require.Equal(t, sampled, replayed(func(opt GenOption) GenOption { return WithSampling(opt, 10, 1) }))
+
+	// nested samples are combined
+	cases := collectCases(t, append(opts, WithSampling(WithSwarm(1000, 1), 50, 1), WithRandomSample(50, 2))...)
+	require.InDelta(t, 250, len(cases), 60)
+
+	_, err = NewGen(append(opts, WithSampling(WithSwarm(1000, 1), 0, 1))...)
+	require.Error(t, err)
+
+	// only the iterator of the option is sampled
+	corpus := replayed(func(opt GenOption) GenOption {
+		return func(g *Generator) error {
+			if err := WithCorpus(path)(g); err != nil {
+				return err
+			}
+			return WithSampling(opt, 10, 1)(g)
+		}
+	})
+	require.Equal(t, append(all, sampled...), corpus)
+	_, err = NewGen(append(opts, WithSampling(WithSteps(3), 10, 1))...)
+	require.Error(t, err)
+}
+
+func TestShorterSchedules(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+		WithShorterSchedules(),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	require.Equal(t, int64(6+36+216), gen.Total().Int64())
+	lengths := map[int]int{}
+	last := 0
+	for tc := range gen.All() {
+		require.GreaterOrEqual(t, len(tc.states), last)
+		last = len(tc.states)
+		lengths[len(tc.states)]++
+	}
+	require.Equal(t, map[int]int{1: 6, 2: 36, 3: 216}, lengths)
+
+	// checkpoint taken in the middle of the shorter schedules
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		gen.Next()
+	}
+	checkpoint, err := gen.Checkpoint()
+	require.NoError(t, err)
+	var expected [][]byte
+	for tc := range gen.All() {
+		buf, err := tc.Marshal()
+		require.NoError(t, err)
+		expected = append(expected, buf)
+	}
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	require.NoError(t, gen.Restore(checkpoint))
+	var restored [][]byte
+	for tc := range gen.All() {
+		buf, err := tc.Marshal()
+		require.NoError(t, err)
+		restored = append(restored, buf)
+	}
+	require.Equal(t, expected, restored)
+
+	_, err = gen.NewTestCase([]int{0, 1})
+	require.NoError(t, err)
+}
+
+func TestTicks(t *testing.T) {
+	action := ActionLead.WithValue(3).WithTicks(0)
+	require.Equal(t, 3, action.Value())
+	require.Equal(t, 0, action.Ticks())
+	require.Equal(t, 1, ActionLead.Ticks())
+	action = action.WithTicks(5).WithValue(1)
+	require.Equal(t, 1, action.Value())
+	require.Equal(t, 5, action.Ticks())
+	require.Equal(t, "Cluster(leader=1,value[1]=1,ticks[1]=5)", Actions{1: action}.String())
+
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithLeaders(1),
+		WithTicks(0, 2, 3),
+		WithTicks(2, 3),
+		WithSteps(1),
+	)
+	require.NoError(t, err)
+	var ticks [][]int
+	for tc := range gen.All() {
+		for _, actions := range tc.Steps() {
+			ticks = append(ticks, []int{actions.Ticks(1), actions.Ticks(2), actions.Ticks(3)})
+		}
+	}
+	require.Equal(t, [][]int{
+		{1, 1, 1}, {1, 1, 1},
+		{1, 0, 1}, {1, 0, 1},
+		{1, 1, 0}, {1, 1, 0},
+		{1, 1, 2}, {1, 1, 2}, {1, 0, 2}, {1, 0, 2}, {1, 1, 2}, {1, 1, 2},
+	}, ticks)
+
+	_, err = NewGen(WithReplicas(1), WithTicks(0, 1))
+	require.Error(t, err)
+	_, err = NewGen(WithReplicas(1), WithLeaders(1), WithTicks(-1, 1))
+	require.Error(t, err)
+}
+
+func TestValidateReplicas(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opts []GenOption
+	}{
+		{
+			desc: "no replicas",
+			opts: []GenOption{WithExplicitPartitions([][]int{{1, 2}}), WithLeaders(1)},
+		},
+		{
+			desc: "duplicate replica",
+			opts: []GenOption{WithReplicas(1, 2, 2), WithAllPartitions(0), WithLeaders(1)},
+		},
+		{
+			desc: "unknown leader",
+			opts: []GenOption{WithReplicas(1, 2, 3), WithAllPartitions(0), WithLeaders(7)},
+		},
+		{
+			desc: "unknown replica in partition",
+			opts: []GenOption{WithReplicas(1, 2, 3), WithExplicitPartitions([][]int{{1, 4}}), WithLeaders(1)},
+		},
+		{
+			desc: "unknown crashed replica",
+			opts: []GenOption{WithReplicas(1, 2, 3), WithAllPartitions(0), WithLeaders(1), WithCrashes(5)},
+		},
+		{
+			desc: "unknown replica in step actions",
+			opts: []GenOption{WithReplicas(1, 2, 3), WithAllPartitions(0), WithLeaders(1),
+				WithStepActions(1, Actions{6: ActionLead})},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewGen(tc.opts...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithStepActions(2, Actions{1: ActionLead}),
+		WithSteps(2),
+		WithRandomSample(50, 1),
+	)
+	require.NoError(t, err)
+	executed := 0
+	for tc := range gen.All() {
+		if executed%2 == 0 {
+			gen.Feedback(tc)
+		}
+		executed++
+	}
+	stats := gen.Stats()
+	require.Equal(t, 3, stats.Replicas)
+	require.Equal(t, 5, stats.Partitions)
+	require.Equal(t, 4, stats.Actions)
+	require.Equal(t, []int{15, 5}, stats.States)
+	require.Equal(t, int64(75), stats.Total.Int64())
+	require.Equal(t, 75, stats.Considered)
+	require.Equal(t, executed, stats.Generated)
+	require.Equal(t, (executed+1)/2, stats.Executed)
+	require.Equal(t, 75-executed, stats.Skipped())
+	require.Contains(t, stats.String(), "states per step 15 5")
+}
+
+func TestTestCaseReset(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithLeaders(1, 2),
+		WithCrashes(3),
+		WithCrashBudget(1),
+		WithSteps(3),
+	)
+	require.NoError(t, err)
+	tc, err := gen.NewTestCase([]int{3, 5, 8})
+	require.NoError(t, err)
+	description := tc.String()
+	require.Equal(t, 3, strings.Count(description, "step "))
+	schedule := tc.Schedule()
+	require.Len(t, schedule, tc.Len())
+
+	var steps []Step
+	for network, actions := range tc.Steps() {
+		steps = append(steps, Step{Network: network, Actions: actions})
+		// schedule and description don't depend on the current step
+		require.Equal(t, description, tc.String())
+		require.Equal(t, schedule, tc.Schedule())
+	}
+	require.Len(t, steps, 3)
+	network, actions := tc.Next()
+	require.Nil(t, network)
+	require.Nil(t, actions)
+
+	tc.Reset()
+	var replayed []Step
+	for network, actions := range tc.Steps() {
+		replayed = append(replayed, Step{Network: network, Actions: actions})
+	}
+	require.Equal(t, steps, replayed)
+}
+
+func TestTestCaseRelease(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	var expected [][]int
+	for tc := range gen.All() {
+		expected = append(expected, append([]int(nil), tc.states...))
+	}
+
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	var released [][]int
+	for tc := range gen.All() {
+		released = append(released, append([]int(nil), tc.states...))
+		tc.Release()
+	}
+	require.NoError(t, gen.Error())
+	require.Equal(t, expected, released)
+
+	// released test cases are reused by the product
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(100, func() {
+		if tc := gen.Next(); tc != nil {
+			tc.Release()
+		}
+	})
+	require.Less(t, allocs, 1.0)
+}
+
+func TestNextBatch(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	var expected []string
+	for tc := range gen.All() {
+		expected = append(expected, prefixKey(tc.states))
+	}
+
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	var batched []string
+	for batch := gen.NextBatch(7); len(batch) > 0; batch = gen.NextBatch(7) {
+		require.LessOrEqual(t, len(batch), 7)
+		for _, tc := range batch {
+			batched = append(batched, prefixKey(tc.states))
+		}
+	}
+	require.Equal(t, expected, batched)
+	require.Equal(t, len(expected), gen.Count())
+}
+
+func TestTestCaseSeed(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(3),
+		WithShorterSchedules(),
+	)
+	require.NoError(t, err)
+	// seed depends on every step, and doesn't depend on the current step
+	seeds := map[int64]struct{}{}
+	for tc := range gen.All() {
+		seed := tc.Seed()
+		for range tc.Steps() {
+			require.Equal(t, seed, tc.Seed())
+		}
+		replayed, err := gen.NewTestCase(append([]int(nil), tc.states...))
+		require.NoError(t, err)
+		require.Equal(t, seed, replayed.Seed())
+		seeds[seed] = struct{}{}
+	}
+	require.Len(t, seeds, int(gen.Total().Int64()))
+}
@@ -0,0 +1,46 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WithIterator replaces the exhaustive product with a custom iterator.
+// Constructor is called once all other options are applied, iterator must
+// create test cases with Generator.NewTestCase. Sampling and progress
+// reporting work the same way as with the exhaustive product, while
+// shards, symmetry reduction and checkpoints are not supported.
+func WithIterator(constructor func(*Generator) (Iterator, error)) GenOption {
+	return func(g *Generator) error {
+		if constructor == nil {
+			return errors.New("iterator constructor is nil")
+		}
+		g.custom = constructor
+		return nil
+	}
+}
+
+// Steps returns max number of steps in the test case. Test cases are shorter
+// only with WithShorterSchedules.
+func (g *Generator) Steps() int {
+	return g.stepLimit
+}
+
+// States returns number of possible states of the step with index from 0 to Steps()-1.
+func (g *Generator) States(step int) int {
+	return len(g.statesAt(step))
+}
+
+// NewTestCase creates a test case where step with index i is in the state states[i].
+// Test case must have Steps() steps, and state of every step must be in range
+// [0, States(i)). Test case owns states, they must not be modified after the call.
+func (g *Generator) NewTestCase(states []int) (*TestCase, error) {
+	if len(states) != g.stepLimit && !g.prefixes {
+		return nil, fmt.Errorf("test case has %d steps, generator has %d", len(states), g.stepLimit)
+	}
+	tc := &TestCase{gen: g, states: states}
+	if err := tc.validate(); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
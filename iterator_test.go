@@ -0,0 +1,91 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// repeatIterator repeats the same state in every step.
+type repeatIterator struct {
+	gen     *Generator
+	state   int
+	current *TestCase
+	err     error
+}
+
+func (r *repeatIterator) Next() bool {
+	if r.err != nil || r.state == r.gen.States(0) {
+		return false
+	}
+	states := make([]int, r.gen.Steps())
+	for i := range states {
+		states[i] = r.state
+	}
+	r.state++
+	r.current, r.err = r.gen.NewTestCase(states)
+	return r.err == nil
+}
+
+func (r *repeatIterator) Current() *TestCase {
+	return r.current
+}
+
+func (r *repeatIterator) Error() error {
+	return r.err
+}
+
+func TestCustomIterator(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(4),
+		WithIterator(func(gen *Generator) (Iterator, error) {
+			return &repeatIterator{gen: gen}, nil
+		}),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	count := 0
+	for tc := range gen.All() {
+		var first Actions
+		for _, actions := range tc.Steps() {
+			if first == nil {
+				first = actions
+			}
+			require.Equal(t, first, actions)
+		}
+		count++
+	}
+	require.NoError(t, gen.Error())
+	// 2 partitions and 3 actions
+	require.Equal(t, 6, count)
+
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(), opts...)
+
+	_, err = NewGen(append(opts, WithSwarm(10, 1))...)
+	require.Error(t, err)
+}
+
+func TestNewTestCase(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1),
+		WithStepActions(2, Actions{}),
+		WithSteps(2),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, gen.States(0))
+	require.Equal(t, 1, gen.States(1))
+
+	_, err = gen.NewTestCase([]int{1, 0})
+	require.NoError(t, err)
+	_, err = gen.NewTestCase([]int{1, 1})
+	require.Error(t, err)
+	_, err = gen.NewTestCase([]int{-1, 0})
+	require.Error(t, err)
+	_, err = gen.NewTestCase([]int{0})
+	require.Error(t, err)
+}
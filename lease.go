@@ -0,0 +1,420 @@
+package paxos
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// NewLeased creates a replica that grants read leases to the holders.
+// Lease is valid for duration ticks.
+func NewLeased(p *Paxos, duration int, holders ...int) (*Leased, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("lease duration %d must be positive", duration)
+	}
+	return &Leased{
+		Paxos:    p,
+		Duration: duration,
+		Holders:  holders,
+		grants:   map[int]int{},
+		granted:  map[int]int{},
+	}, nil
+}
+
+// Leased implements quorum leases on top of Paxos.
+//
+// Holder requests a lease from every replica, and it can serve reads locally
+// while leases from the round 1 majority are valid. Round 1 majority intersects
+// with every round 2 majority, therefore at least one replica that voted for
+// a chosen value has granted a lease to the holder.
+//
+// Replica that granted a lease doesn't vote for a value until the holder
+// acknowledged that value (LeaseUpdate and LeaseAck), or until the lease expired.
+// Proposer votes for its own value right away, therefore it doesn't send Accept
+// until holders acknowledged that vote. Holder doesn't serve reads while it knows
+// about a value that may be chosen but wasn't learned yet.
+//
+// Granted leases aren't persisted. Recovered replica assumes that every holder
+// has a lease for the next duration ticks.
+//
+// Lease expiry is computed from the tick when the holder sent a request, so
+// the holder lease always expires before the lease on the grantor.
+type Leased struct {
+	*Paxos
+
+	Duration int
+	Holders  []int
+
+	now int
+
+	// holder. expiry of the lease from every grantor.
+	grants map[int]int
+	// holder. values that were voted by grantors, but weren't learned.
+	pending []Value
+
+	// grantor. expiry of the lease for every holder.
+	granted map[int]int
+	// grantor. Accept that wait for acknowledgement from holders.
+	held []heldMessage
+	// proposer. ballot which self vote was not acknowledged by holders.
+	selfBallot  int
+	selfWaiting map[int]struct{}
+	// proposer. Accept that are sent after self vote is acknowledged.
+	deferred []Message
+
+	out []Message
+}
+
+type heldMessage struct {
+	msg     Message
+	waiting map[int]struct{}
+}
+
+var _ Node = (*Leased)(nil)
+
+// CloneNode returns a deep copy of the replica, including leases.
+func (l *Leased) CloneNode() Node {
+	clone := *l
+	clone.Paxos = l.Paxos.Clone()
+	clone.grants = maps.Clone(l.grants)
+	clone.pending = slices.Clone(l.pending)
+	clone.granted = maps.Clone(l.granted)
+	clone.held = make([]heldMessage, len(l.held))
+	for i, h := range l.held {
+		clone.held[i] = heldMessage{msg: h.msg, waiting: copySet(h.waiting)}
+	}
+	clone.selfWaiting = copySet(l.selfWaiting)
+	clone.deferred = slices.Clone(l.deferred)
+	clone.out = slices.Clone(l.out)
+	return &clone
+}
+
+// IsHolder returns true if replica may hold a lease.
+func (l *Leased) IsHolder(id int) bool {
+	for _, holder := range l.Holders {
+		if holder == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Tick advances a clock, expires leases and renews the lease of the holder.
+func (l *Leased) Tick() {
+	l.now++
+	for holder, expiry := range l.granted {
+		if expiry <= l.now {
+			delete(l.granted, holder)
+		}
+	}
+	l.release()
+	if !l.IsHolder(l.ID) {
+		return
+	}
+	renew := l.Duration / 2
+	if renew == 0 {
+		renew = 1
+	}
+	if (l.now-1)%renew != 0 {
+		return
+	}
+	l.grants[l.ID] = l.now + l.Duration
+	for _, id := range l.Nodes {
+		if id == l.ID || l.IsWitness(id) {
+			continue
+		}
+		l.out = append(l.out, Message{
+			From:   l.ID,
+			To:     id,
+			Type:   MessageLeaseRequest,
+			Ballot: l.now,
+		})
+	}
+}
+
+// active returns holders with valid leases, except the replica itself.
+func (l *Leased) active() map[int]struct{} {
+	rst := map[int]struct{}{}
+	for holder, expiry := range l.granted {
+		if holder != l.ID && expiry > l.now {
+			rst[holder] = struct{}{}
+		}
+	}
+	return rst
+}
+
+// Read returns a learned value and true if the replica holds a valid lease
+// and doesn't know about values that may be chosen.
+func (l *Leased) Read() (Value, bool) {
+	if !l.IsHolder(l.ID) {
+		return nil, false
+	}
+	weight := 0
+	for grantor, expiry := range l.grants {
+		if expiry > l.now {
+			weight += l.Weight(grantor)
+		}
+	}
+	if weight < l.R1Majority {
+		return nil, false
+	}
+	if l.LearnedValue != nil {
+		return l.LearnedValue, true
+	}
+	if l.votedValue != nil || len(l.pending) > 0 {
+		return nil, false
+	}
+	return nil, true
+}
+
+func (l *Leased) Propose(value Value) {
+	l.Paxos.Propose(value)
+	l.collect()
+}
+
+func (l *Leased) Step(m Message) []Message {
+	switch m.Type {
+	case MessageEmpty:
+	case MessageLeaseRequest:
+		if !l.IsHolder(m.From) {
+			break
+		}
+		l.granted[m.From] = l.now + l.Duration
+		for i := range l.held {
+			if _, exist := l.held[i].waiting[m.From]; !exist {
+				l.held[i].waiting[m.From] = struct{}{}
+				l.update(map[int]struct{}{m.From: {}}, l.held[i].msg.Ballot, l.held[i].msg.Value)
+			}
+		}
+		// holder needs to know about a value that was voted before the lease was granted
+		l.out = append(l.out, Message{
+			From:        l.ID,
+			To:          m.From,
+			Type:        MessageLeaseGrant,
+			Ballot:      m.Ballot,
+			VotedBallot: l.votedBallot,
+			Value:       l.votedValue,
+		})
+	case MessageLeaseGrant:
+		if m.Ballot+l.Duration > l.now {
+			l.grants[m.From] = m.Ballot + l.Duration
+		}
+		l.notify(m.Value)
+	case MessageLeaseUpdate:
+		l.notify(m.Value)
+		l.out = append(l.out, Message{
+			From:   l.ID,
+			To:     m.From,
+			Type:   MessageLeaseAck,
+			Ballot: m.Ballot,
+		})
+	case MessageLeaseAck:
+		for i := range l.held {
+			if l.held[i].msg.Ballot == m.Ballot {
+				delete(l.held[i].waiting, m.From)
+			}
+		}
+		if l.selfBallot == m.Ballot {
+			delete(l.selfWaiting, m.From)
+		}
+		l.release()
+	case MessageAccept:
+		waiting := l.active()
+		if len(waiting) == 0 {
+			l.Paxos.Next(m)
+			break
+		}
+		l.held = append(l.held, heldMessage{msg: m, waiting: waiting})
+		l.update(waiting, m.Ballot, m.Value)
+	default:
+		l.Paxos.Next(m)
+	}
+	l.collect()
+	messages := l.out
+	l.out = l.out[:0]
+	return messages
+}
+
+// notify records a value that may be chosen.
+func (l *Leased) notify(value Value) {
+	if value == nil {
+		return
+	}
+	for _, v := range l.pending {
+		if bytes.Equal(v, value) {
+			return
+		}
+	}
+	l.pending = append(l.pending, value)
+}
+
+// collect intercepts Accept of the proposer and holds them until holders
+// with valid leases acknowledge the self vote.
+func (l *Leased) collect() {
+	if l.phase == PhaseAccept && l.selfBallot != l.ballot {
+		// proposer voted for its own value
+		l.selfBallot = l.ballot
+		l.selfWaiting = l.active()
+		l.update(l.selfWaiting, l.ballot, l.votedValue)
+	}
+	for _, m := range l.Paxos.Step(Message{}) {
+		if m.Type == MessageAccept && m.Ballot == l.selfBallot && len(l.selfWaiting) > 0 {
+			l.deferred = append(l.deferred, m)
+			continue
+		}
+		l.out = append(l.out, m)
+	}
+	l.release()
+}
+
+func (l *Leased) update(holders map[int]struct{}, ballot int, value Value) {
+	for holder := range holders {
+		l.out = append(l.out, Message{
+			From:   l.ID,
+			To:     holder,
+			Type:   MessageLeaseUpdate,
+			Ballot: ballot,
+			Value:  value,
+		})
+	}
+}
+
+// release votes for held values and sends deferred messages once every
+// holder acknowledged the value or its lease expired.
+func (l *Leased) release() {
+	for i := range l.held {
+		for holder := range l.held[i].waiting {
+			if _, exist := l.granted[holder]; !exist {
+				delete(l.held[i].waiting, holder)
+			}
+		}
+	}
+	kept := l.held[:0]
+	for _, h := range l.held {
+		if len(h.waiting) == 0 {
+			l.Paxos.Next(h.msg)
+			l.out = append(l.out, l.Paxos.Step(Message{})...)
+		} else {
+			kept = append(kept, h)
+		}
+	}
+	l.held = kept
+
+	for holder := range l.selfWaiting {
+		if _, exist := l.granted[holder]; !exist {
+			delete(l.selfWaiting, holder)
+		}
+	}
+	if len(l.selfWaiting) == 0 && len(l.deferred) > 0 {
+		l.out = append(l.out, l.deferred...)
+		l.deferred = nil
+	}
+}
+
+// UnmarshalBinary restores persisted state of the replica and holds votes
+// until leases that could be granted before the crash expire.
+func (l *Leased) UnmarshalBinary(data []byte) error {
+	if err := l.Paxos.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	for _, holder := range l.Holders {
+		if holder != l.ID {
+			l.granted[holder] = l.now + l.Duration
+		}
+	}
+	return nil
+}
+
+// reader is implemented by replicas that serve local reads.
+type reader interface {
+	Read() (Value, bool)
+}
+
+// voter is implemented by acceptors that report their vote.
+type voter interface {
+	Status() Status
+	Weight(id int) int
+	acceptQuorum() int
+}
+
+type vote struct {
+	ballot int
+	value  string
+}
+
+// checkReads verifies that local reads never return a value that is different
+// from the chosen value. Value is chosen once the round 2 majority voted for it,
+// even if no replica learned it yet.
+func (c *Cluster) checkReads(learned Value) error {
+	readers := false
+	for _, id := range c.ids {
+		if _, ok := c.nodes[id].(reader); ok {
+			readers = true
+			break
+		}
+	}
+	if !readers {
+		return nil
+	}
+	chosen := c.chosenValue()
+	if chosen != nil && learned != nil && !bytes.Equal(chosen, learned) {
+		return fmt.Errorf("%v was chosen, but %v was learned", chosen, learned)
+	}
+	for _, id := range c.ids {
+		node, ok := c.nodes[id].(reader)
+		if !ok || c.isCrashed(id) {
+			continue
+		}
+		value, ok := node.Read()
+		if ok && !bytes.Equal(value, chosen) {
+			return fmt.Errorf("replica %d read %v, but %v was chosen", id, value, chosen)
+		}
+	}
+	return nil
+}
+
+// chosenValue returns a value that was voted by the round 2 majority in the
+// same ballot, or nil. Votes are accumulated across steps, since acceptors
+// replace them with votes in the higher ballots.
+func (c *Cluster) chosenValue() Value {
+	var quorum voter
+	for _, id := range c.ids {
+		node, ok := c.nodes[id].(voter)
+		if !ok {
+			return nil
+		}
+		if quorum == nil {
+			quorum = node
+		}
+		if c.isCrashed(id) {
+			continue
+		}
+		status := node.Status()
+		if status.VotedValue == nil {
+			continue
+		}
+		if c.votes == nil {
+			c.votes = map[vote]map[int]struct{}{}
+		}
+		key := vote{ballot: status.VotedBallot, value: string(status.VotedValue)}
+		if c.votes[key] == nil {
+			c.votes[key] = map[int]struct{}{}
+		}
+		c.votes[key][id] = struct{}{}
+	}
+	var (
+		chosen Value
+		ballot int
+	)
+	for key, voters := range c.votes {
+		weight := 0
+		for id := range voters {
+			weight += quorum.Weight(id)
+		}
+		if weight >= quorum.acceptQuorum() && (chosen == nil || key.ballot < ballot) {
+			chosen, ballot = Value(key.value), key.ballot
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,123 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func leasedFactory(duration int, holders ...int) NodeFactory {
+	return func(id int, nodes []int) (Node, error) {
+		p, err := NewPaxos(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		return NewLeased(p, duration, holders...)
+	}
+}
+
+func TestLeasedRead(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	cluster, err := NewCluster(nodes, leasedFactory(4, 3))
+	require.NoError(t, err)
+	full := Partition{}
+	full.Add(1, 2)
+	full.Add(1, 3)
+	full.Add(2, 3)
+
+	cluster.Step(full, Actions{})
+	cluster.Step(full, Actions{})
+	value, ok := cluster.Node(3).(*Leased).Read()
+	require.True(t, ok)
+	require.Nil(t, value)
+
+	cluster.Step(full, Actions{1: ActionLead})
+	for i := 0; i < 6; i++ {
+		cluster.Step(full, Actions{})
+		require.NoError(t, cluster.Check())
+	}
+	value, ok = cluster.Node(3).(*Leased).Read()
+	require.True(t, ok)
+	require.Equal(t, Value{1}, value)
+
+	// lease expires when holder is isolated
+	isolated := Partition{}
+	isolated.Add(1, 2)
+	for i := 0; i < 5; i++ {
+		cluster.Step(isolated, Actions{})
+	}
+	_, ok = cluster.Node(3).(*Leased).Read()
+	require.False(t, ok)
+}
+
+func TestLeasedReadsNeverStale(t *testing.T) {
+	Run(t, Simulate(leasedFactory(3, 2, 5)), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3, 4, 5},
+			},
+			[][]int{
+				{1, 2, 3},
+				{4, 5},
+			},
+			[][]int{
+				{1, 4},
+				{2, 3, 5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 4),
+		WithSteps(6),
+	)
+}
+
+// Recovered grantor doesn't remember leases, but it holds votes until
+// leases that it could grant before the crash expire.
+func TestLeasedRecoveredGrantor(t *testing.T) {
+	cluster, err := NewCluster([]int{1, 2, 3}, leasedFactory(10, 3))
+	require.NoError(t, err)
+	// holder has leases from itself and 2, but not from 1
+	network := Partition{}
+	network.Add(1, 2)
+	network.Add(2, 3)
+	for i := 0; i < 7; i++ {
+		cluster.Step(network, Actions{})
+	}
+	_, ok := cluster.Node(3).(*Leased).Read()
+	require.True(t, ok)
+
+	isolated := Partition{}
+	isolated.Add(1, 2)
+	cluster.Step(isolated, Actions{2: ActionCrash})
+	cluster.Step(isolated, Actions{2: ActionRecover, 1: ActionLead})
+	for i := 0; i < 12; i++ {
+		cluster.Step(isolated, Actions{})
+		require.NoError(t, cluster.Check())
+	}
+	require.Equal(t, Value{1}, cluster.Node(1).(*Leased).LearnedValue)
+}
+
+func TestLeasedCrashRecovery(t *testing.T) {
+	Run(t, Simulate(leasedFactory(3, 3)), runFlags(),
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithCrashes(2),
+		WithSteps(5),
+	)
+}
+
+// Leases are safe only if clocks advance at the same rate, holder with
+// a stalled clock serves reads after grantors released the lease.
+func TestLeasedClockSkew(t *testing.T) {
+	RunExpectFailure(t, Simulate(leasedFactory(2, 3)),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1, 2}, {3}},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1),
+		WithTicks(0, 3),
+		WithSteps(7),
+	)
+}
@@ -0,0 +1,114 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WithHealing makes the last steps of every schedule heal the cluster:
+// every replica can reach every other replica, and none of the replicas
+// crash, drop messages or become byzantine. Replicas that crashed earlier may
+// recover. At least one replica is a leader in the first healing step, and none
+// in the following steps, otherwise every new proposal preempts the previous one.
+// Combined with WithLiveness it checks that the cluster makes progress once
+// the network is healthy. Paxos needs at least 4 healing steps to learn a value.
+func WithHealing(steps int) GenOption {
+	return func(g *Generator) error {
+		if steps <= 0 {
+			return fmt.Errorf("healing steps %d must be positive", steps)
+		}
+		g.healing = steps
+		return nil
+	}
+}
+
+// heal replaces states of the last healing steps.
+func (g *Generator) heal() error {
+	if g.healing > g.stepLimit {
+		return fmt.Errorf("healing steps %d exceed step limit %d", g.healing, g.stepLimit)
+	}
+	connected := -1
+	full := groupPartition([][]int{g.nodes})
+	for i, network := range g.partitions {
+		if samePartition(network, full, g.nodes) {
+			connected = i
+			break
+		}
+	}
+	if connected < 0 {
+		connected = len(g.partitions)
+		g.partitions = append(g.partitions, full)
+	}
+	if g.stepStates == nil {
+		g.stepStates = map[int][]stepState{}
+	}
+	for step := g.stepLimit - g.healing; step < g.stepLimit; step++ {
+		var (
+			states []stepState
+			seen   = map[stepState]struct{}{}
+		)
+		first := step == g.stepLimit-g.healing
+		for _, state := range g.statesAt(step) {
+			state.partition = connected
+			if _, exist := seen[state]; exist || !g.healthy(g.actions[state.actions], first) {
+				continue
+			}
+			seen[state] = struct{}{}
+			states = append(states, state)
+		}
+		if len(states) == 0 && first {
+			return fmt.Errorf("step %d can't heal the cluster: none of the actions have a leader", step+1)
+		} else if len(states) == 0 {
+			return fmt.Errorf("step %d can't heal the cluster: all actions have a leader", step+1)
+		}
+		g.stepStates[step] = states
+	}
+	return nil
+}
+
+// healthy returns true if actions don't have faults, and have a leader
+// only if the leader is expected.
+func (g *Generator) healthy(actions Actions, leader bool) bool {
+	leaders := false
+	for id, action := range actions {
+		if action&(ActionCrash|ActionByzantine|ActionDrop) > 0 {
+			return false
+		}
+		leaders = leaders || actions.IsLeader(id)
+	}
+	return leaders == leader
+}
+
+func samePartition(a, b Partition, nodes []int) bool {
+	for _, from := range nodes {
+		for _, to := range nodes {
+			if from != to && a.Reachable(from, to) != b.Reachable(from, to) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// WithLiveness checks that at least one replica learned a value once all
+// steps of the test case were executed. See WithHealing.
+func WithLiveness() ClusterOption {
+	return func(c *Cluster) error {
+		c.liveness = true
+		return nil
+	}
+}
+
+var errNotLearned = errors.New("none of the replicas learned a value")
+
+func (c *Cluster) checkLiveness() error {
+	if !c.liveness {
+		return nil
+	}
+	for _, id := range c.ids {
+		if !c.isCrashed(id) && c.nodes[id].Learned() != nil {
+			return nil
+		}
+	}
+	return errNotLearned
+}
@@ -0,0 +1,56 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealing(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	opts := []GenOption{
+		WithReplicas(nodes...),
+		WithAllPartitions(0),
+		WithLeaders(nodes...),
+		WithCrashes(3),
+		WithSteps(4),
+	}
+	gen, err := NewGen(append(opts, WithHealing(2))...)
+	require.NoError(t, err)
+	count := 0
+	for tc := range gen.All() {
+		count++
+		step := 0
+		for network, actions := range tc.Steps() {
+			step++
+			if step <= 2 {
+				continue
+			}
+			require.True(t, healed([]Step{{Network: network}}, nodes), "step %d\n%s", step, tc)
+			require.True(t, gen.healthy(actions, step == 3), "step %d\n%s", step, tc)
+		}
+	}
+	require.NoError(t, gen.Error())
+	require.Equal(t, int64(count), gen.Total().Int64())
+
+	unhealed, err := NewGen(opts...)
+	require.NoError(t, err)
+	require.Less(t, count, int(unhealed.Total().Int64()))
+
+	_, err = NewGen(append(opts, WithHealing(5))...)
+	require.Error(t, err)
+	_, err = NewGen(WithReplicas(nodes...), WithAllPartitions(0), WithCrashes(3), WithSteps(4), WithHealing(1))
+	require.Error(t, err)
+}
+
+func TestPaxosLiveness(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	opts := []GenOption{
+		WithReplicas(nodes...),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(6),
+	}
+	Run(t, Simulate(paxosFactory, WithValidation(), WithLiveness()), runFlags(), append(opts, WithHealing(4))...)
+	RunExpectFailure(t, Simulate(paxosFactory, WithLiveness()), opts...)
+}
@@ -0,0 +1,215 @@
+package paxos
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Noop is a value that a new leader proposes to fill gaps in the log.
+// Noop is empty but not nil, therefore replicas must not propose empty values.
+var Noop = Value{}
+
+// IsNoop returns true if value is Noop.
+func IsNoop(v Value) bool {
+	return v != nil && len(v) == 0
+}
+
+// NewLog creates a multi-instance replica. Options are applied to every instance.
+func NewLog(id int, nodes []int, opts ...PaxosOption) (*Log, error) {
+	// validate options once, every other instance is created with the same options
+	first, err := NewPaxos(id, nodes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{
+		ID:       id,
+		Nodes:    nodes,
+		opts:     opts,
+		slots:    map[int]*Paxos{0: first},
+		last:     -1,
+		proposed: map[int]Value{},
+	}, nil
+}
+
+// Log is a sequence of independent single-decree Paxos instances (slots).
+// Every message is routed to the instance by the Message.Slot.
+type Log struct {
+	ID    int
+	Nodes []int
+
+	opts  []PaxosOption
+	slots map[int]*Paxos
+	// highest slot that is known to this replica. -1 if log is empty.
+	last int
+	// slots before compacted were removed from the log
+	compacted int
+
+	// values proposed by this replica
+	proposed map[int]Value
+
+	messages []Message
+}
+
+var _ Node = (*Log)(nil)
+
+func (l *Log) instance(slot int) *Paxos {
+	p, exist := l.slots[slot]
+	if !exist {
+		// options were validated in NewLog
+		p, _ = NewPaxos(l.ID, l.Nodes, l.opts...)
+		l.slots[slot] = p
+	}
+	if slot > l.last {
+		l.last = slot
+	}
+	return p
+}
+
+// Propose appends value to the next slot after the highest known slot.
+// Every lower slot that is not learned yet is proposed again, with a value
+// that was proposed by this replica before or with Noop, so that the log
+// doesn't have gaps.
+func (l *Log) Propose(value Value) {
+	next := l.last + 1
+	for slot := l.compacted; slot < next; slot++ {
+		p := l.instance(slot)
+		if p.Learned() != nil {
+			continue
+		}
+		fill, exist := l.proposed[slot]
+		if !exist {
+			fill = Noop
+			l.proposed[slot] = fill
+		}
+		p.Propose(fill)
+		l.collect(slot, p)
+	}
+	l.proposed[next] = value
+	p := l.instance(next)
+	p.Propose(value)
+	l.collect(next, p)
+}
+
+func (l *Log) collect(slot int, p *Paxos) {
+	for _, m := range p.Step(Message{}) {
+		m.Slot = slot
+		l.messages = append(l.messages, m)
+	}
+}
+
+// Step delivers a message to the instance and returns accumulated outbox.
+func (l *Log) Step(m Message) []Message {
+	if m.Type != MessageEmpty && m.Slot >= l.compacted {
+		p := l.instance(m.Slot)
+		p.Next(m)
+		l.collect(m.Slot, p)
+	}
+	messages := l.messages
+	l.messages = l.messages[:0]
+	return messages
+}
+
+// truncate removes all slots before slot.
+func (l *Log) truncate(slot int) {
+	for s := l.compacted; s < slot; s++ {
+		delete(l.slots, s)
+		delete(l.proposed, s)
+	}
+	if slot > l.compacted {
+		l.compacted = slot
+	}
+	if slot-1 > l.last {
+		l.last = slot - 1
+	}
+}
+
+// Compacted returns the first slot that wasn't removed from the log.
+func (l *Log) Compacted() int {
+	return l.compacted
+}
+
+// ReadMessages appends outbox to buf and clears the outbox.
+func (l *Log) ReadMessages(buf []Message) []Message {
+	buf = append(buf, l.messages...)
+	l.messages = l.messages[:0]
+	return buf
+}
+
+// Learned returns a value chosen in the first slot.
+func (l *Log) Learned() Value {
+	return l.Chosen(0)
+}
+
+// Len returns number of known slots.
+func (l *Log) Len() int {
+	return l.last + 1
+}
+
+// Chosen returns value learned in the slot or nil.
+func (l *Log) Chosen(slot int) Value {
+	p, exist := l.slots[slot]
+	if !exist {
+		return nil
+	}
+	return p.Learned()
+}
+
+// Err returns the first error reported by any instance.
+func (l *Log) Err() error {
+	for slot := l.compacted; slot <= l.last; slot++ {
+		if p, exist := l.slots[slot]; exist && p.Err() != nil {
+			return fmt.Errorf("slot %d: %w", slot, p.Err())
+		}
+	}
+	return nil
+}
+
+// Validate checks internal invariants of every instance that wasn't compacted.
+func (l *Log) Validate() error {
+	for slot := l.compacted; slot <= l.last; slot++ {
+		if p, exist := l.slots[slot]; exist {
+			if err := p.Validate(); err != nil {
+				return fmt.Errorf("slot %d: %w", slot, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LogNode is implemented by replicas that decide a sequence of values.
+type LogNode interface {
+	Len() int
+	Chosen(slot int) Value
+}
+
+// checkLog verifies that values chosen in the same slot are equal on all replicas,
+// and that a value chosen in the slot is never replaced, for example by Noop.
+func (c *Cluster) checkLog() error {
+	if c.chosen == nil {
+		c.chosen = map[int]Value{}
+	}
+	for _, id := range c.ids {
+		node, ok := c.nodes[id].(LogNode)
+		if !ok {
+			continue
+		}
+		for slot := 0; slot < node.Len(); slot++ {
+			value := node.Chosen(slot)
+			if value == nil {
+				continue
+			}
+			chosen, exist := c.chosen[slot]
+			if !exist {
+				c.chosen[slot] = value
+				continue
+			}
+			if !bytes.Equal(chosen, value) {
+				if IsNoop(value) {
+					return fmt.Errorf("slot %d: noop overwrote chosen value %v on replica %d", slot, chosen, id)
+				}
+				return fmt.Errorf("slot %d: %v != %v on replica %d", slot, chosen, value, id)
+			}
+		}
+	}
+	return nil
+}
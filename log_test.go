@@ -0,0 +1,188 @@
+package paxos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogGapFilling(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	cluster, err := NewCluster(nodes, func(id int, nodes []int) (Node, error) {
+		return NewLog(id, nodes)
+	})
+	require.NoError(t, err)
+
+	full := Partition{}
+	full.Add(1, 2)
+	full.Add(1, 3)
+	full.Add(2, 3)
+	isolated := Partition{}
+	isolated.Add(2, 3)
+
+	// replica 1 chooses a value in slot 0 and starts slot 1, but only
+	// prepares for slot 1 are delivered before it gets isolated.
+	cluster.Step(full, Actions{1: ActionLead})
+	for i := 0; i < 3; i++ {
+		cluster.Step(full, Actions{})
+	}
+	cluster.Step(full, Actions{1: ActionLead})
+	for i := 0; i < 8; i++ {
+		cluster.Step(isolated, Actions{})
+	}
+	// replica 2 appends slot 2 and fills gap in slot 1 with noop
+	cluster.Step(isolated, Actions{2: ActionLead})
+	for i := 0; i < 4; i++ {
+		cluster.Step(isolated, Actions{})
+		require.NoError(t, cluster.Check())
+	}
+	log := cluster.Node(2).(*Log)
+	require.Equal(t, 3, log.Len())
+	require.Equal(t, Value{1}, log.Chosen(0))
+	require.True(t, IsNoop(log.Chosen(1)))
+	require.Equal(t, Value{2}, log.Chosen(2))
+
+	// delayed messages from replica 1 can't change chosen values
+	for i := 0; i < 8; i++ {
+		cluster.Step(full, Actions{})
+		require.NoError(t, cluster.Check())
+	}
+}
+
+func TestMultiPaxos(t *testing.T) {
+	Run(t, Simulate(func(id int, nodes []int) (Node, error) {
+		return NewLog(id, nodes)
+	}, WithValidation()), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+			},
+			[][]int{
+				{1},
+				{2, 3},
+			},
+			[][]int{
+				{1, 2},
+				{3},
+			},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(5),
+	)
+}
+
+// counter adds first byte of every value.
+type counter struct {
+	sum uint64
+}
+
+func (c *counter) Apply(v Value) error {
+	c.sum += uint64(v[0])
+	return nil
+}
+
+func (c *counter) State() []byte {
+	return []byte(fmt.Sprint(c.sum))
+}
+
+func TestReplicatedCounter(t *testing.T) {
+	Run(t, Simulate(func(id int, nodes []int) (Node, error) {
+		log, err := NewLog(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		return NewReplicated(log, &counter{}), nil
+	}), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+			},
+			[][]int{
+				{1},
+				{2, 3},
+			},
+			[][]int{
+				{1, 2},
+				{3},
+			},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(5),
+	)
+}
+
+func (c *counter) Restore(state []byte) error {
+	_, err := fmt.Sscan(string(state), &c.sum)
+	return err
+}
+
+func TestReplicatedCompaction(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	cluster, err := NewCluster(nodes, func(id int, nodes []int) (Node, error) {
+		log, err := NewLog(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		replicated := NewReplicated(log, &counter{})
+		replicated.CompactAfter = 1
+		return replicated, nil
+	})
+	require.NoError(t, err)
+
+	full := Partition{}
+	full.Add(1, 2)
+	full.Add(1, 3)
+	full.Add(2, 3)
+	isolated := Partition{}
+	isolated.Add(1, 2)
+
+	// replica 3 misses two slots, that are compacted by replicas 1 and 2
+	for i := 0; i < 2; i++ {
+		cluster.Step(isolated, Actions{1: ActionLead})
+		for j := 0; j < 3; j++ {
+			cluster.Step(isolated, Actions{})
+		}
+	}
+	require.Equal(t, 2, cluster.Node(1).(*Replicated).Compacted())
+	require.Equal(t, 0, cluster.Node(3).(*Replicated).Applied())
+
+	// replica 3 catches up from the snapshot once it sends a message for a removed slot
+	cluster.Step(full, Actions{3: ActionLead})
+	for j := 0; j < 3; j++ {
+		cluster.Step(full, Actions{})
+		require.NoError(t, cluster.Check())
+	}
+	lagging := cluster.Node(3).(*Replicated)
+	leader := cluster.Node(1).(*Replicated)
+	require.GreaterOrEqual(t, lagging.Compacted(), 2)
+	require.Equal(t, leader.Applied(), lagging.Applied())
+	require.Equal(t, leader.State(), lagging.State())
+}
+
+func TestReplicatedCompactionSchedules(t *testing.T) {
+	Run(t, Simulate(func(id int, nodes []int) (Node, error) {
+		log, err := NewLog(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		replicated := NewReplicated(log, &counter{})
+		replicated.CompactAfter = 1
+		return replicated, nil
+	}), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+			},
+			[][]int{
+				{1, 2},
+				{3},
+			},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(7),
+	)
+}
@@ -0,0 +1,157 @@
+package paxos
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// StateMachine is replicated by applying values chosen in the log, in the
+// order of the slots. Noop values are not applied.
+type StateMachine interface {
+	Apply(Value) error
+	// State returns canonical encoding of the state. Replicas that applied
+	// the same number of slots must return equal states.
+	State() []byte
+}
+
+// Snapshotter is implemented by machines that can be restored from the State.
+// Only such machines support log compaction.
+type Snapshotter interface {
+	Restore([]byte) error
+}
+
+// NewReplicated creates a replica that applies values chosen in the log to the machine.
+func NewReplicated(log *Log, machine StateMachine) *Replicated {
+	return &Replicated{Log: log, machine: machine}
+}
+
+// Replicated applies chosen values to the state machine after every step.
+//
+// If CompactAfter is positive and machine implements Snapshotter, log is compacted
+// once CompactAfter slots were applied since the last compaction. Replica that
+// receives a message for a removed slot replies with the snapshot of the state,
+// so that replicas that fall behind can catch up without removed slots.
+type Replicated struct {
+	*Log
+	machine StateMachine
+
+	CompactAfter int
+
+	// number of applied slots
+	applied int
+	err     error
+
+	// state of the machine after snapshotSlot slots were applied
+	snapshot     []byte
+	snapshotSlot int
+}
+
+var _ Node = (*Replicated)(nil)
+
+func (r *Replicated) Step(m Message) []Message {
+	switch {
+	case m.Type == MessageSnapshot:
+		r.install(m)
+		m = Message{}
+	case m.Type != MessageEmpty && m.Slot < r.Compacted() && m.Type != MessageLearned:
+		r.Log.messages = append(r.Log.messages, Message{
+			From:  r.ID,
+			To:    m.From,
+			Type:  MessageSnapshot,
+			Slot:  r.snapshotSlot,
+			Value: r.snapshot,
+		})
+	}
+	messages := r.Log.Step(m)
+	r.apply()
+	r.compact()
+	return messages
+}
+
+func (r *Replicated) compact() {
+	_, ok := r.machine.(Snapshotter)
+	if r.err != nil || !ok || r.CompactAfter <= 0 || r.applied-r.Compacted() < r.CompactAfter {
+		return
+	}
+	r.snapshot = r.machine.State()
+	r.snapshotSlot = r.applied
+	r.Log.truncate(r.applied)
+}
+
+func (r *Replicated) install(m Message) {
+	snapshotter, ok := r.machine.(Snapshotter)
+	if r.err != nil || !ok || m.Slot <= r.applied {
+		return
+	}
+	if err := snapshotter.Restore(m.Value); err != nil {
+		r.err = fmt.Errorf("restore snapshot at slot %d: %w", m.Slot, err)
+		return
+	}
+	r.applied = m.Slot
+	r.snapshot = m.Value
+	r.snapshotSlot = m.Slot
+	r.Log.truncate(m.Slot)
+}
+
+func (r *Replicated) apply() {
+	for ; r.err == nil && r.applied < r.Len(); r.applied++ {
+		value := r.Chosen(r.applied)
+		if value == nil {
+			return
+		}
+		if IsNoop(value) {
+			continue
+		}
+		if err := r.machine.Apply(value); err != nil {
+			r.err = fmt.Errorf("apply slot %d: %w", r.applied, err)
+		}
+	}
+}
+
+// Applied returns the number of applied slots.
+func (r *Replicated) Applied() int {
+	return r.applied
+}
+
+// State returns state of the machine.
+func (r *Replicated) State() []byte {
+	return r.machine.State()
+}
+
+func (r *Replicated) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Log.Err()
+}
+
+// machineNode is implemented by replicas with a replicated state machine.
+type machineNode interface {
+	Applied() int
+	State() []byte
+}
+
+// checkMachines verifies that replicas that applied the same number of slots
+// have equal states.
+func (c *Cluster) checkMachines() error {
+	for _, id := range c.ids {
+		node, ok := c.nodes[id].(machineNode)
+		if !ok {
+			continue
+		}
+		if c.states == nil {
+			c.states = map[int][]byte{}
+		}
+		applied, state := node.Applied(), node.State()
+		expected, exist := c.states[applied]
+		if !exist {
+			c.states[applied] = state
+			continue
+		}
+		if !bytes.Equal(expected, state) {
+			return fmt.Errorf("replica %d state after %d slots is 0x%x, expected 0x%x",
+				id, applied, state, expected)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+)
+
+// WithMaxCases replaces the exhaustive product with exactly max test cases
+// that are sampled uniformly without replacement, or with every test case
+// if the product is smaller. Positions of the test cases in the product are
+// selected without enumerating it, and test cases are generated in the order
+// of the product, so that SimulateShared can reuse shared prefixes.
+// The same seed selects the same test cases.
+func WithMaxCases(max int, seed int64) GenOption {
+	return func(g *Generator) error {
+		if max <= 0 {
+			return fmt.Errorf("max number of test cases %d must be positive", max)
+		}
+		g.capped = &cappedIterator{gen: g, max: max, seed: seed}
+		return nil
+	}
+}
+
+func (g *Generator) validateCapped() error {
+	if g.capped == nil {
+		return nil
+	}
+	if g.iter != nil || g.coverage != nil || g.swarm != nil || g.custom != nil {
+		return errors.New("max number of test cases can't be used with replay, coverage guided generation, swarm or custom iterator")
+	}
+	if g.shards > 1 || g.symmetry {
+		return errors.New("max number of test cases can't be used with shards or symmetry reduction")
+	}
+	return nil
+}
+
+// cappedIterator generates test cases at the sampled positions of the product.
+type cappedIterator struct {
+	gen  *Generator
+	max  int
+	seed int64
+
+	// sorted positions, selected on the first call to Next
+	positions []*big.Int
+	sampled   bool
+	current   *TestCase
+}
+
+func (c *cappedIterator) Next() bool {
+	if !c.sampled {
+		c.positions = c.sample()
+		c.sampled = true
+	}
+	if len(c.positions) == 0 {
+		return false
+	}
+	c.current = &TestCase{gen: c.gen, states: c.gen.decode(c.positions[0])}
+	c.positions = c.positions[1:]
+	return true
+}
+
+// sample selects positions with Floyd's algorithm, that makes max random
+// choices regardless of the size of the product.
+func (c *cappedIterator) sample() []*big.Int {
+	total := c.gen.Total()
+	if total.Cmp(big.NewInt(int64(c.max))) <= 0 {
+		positions := make([]*big.Int, total.Int64())
+		for i := range positions {
+			positions[i] = big.NewInt(int64(i))
+		}
+		return positions
+	}
+	var (
+		rng       = rand.New(rand.NewSource(c.seed))
+		selected  = make(map[string]struct{}, c.max)
+		positions = make([]*big.Int, 0, c.max)
+		one       = big.NewInt(1)
+	)
+	for j := new(big.Int).Sub(total, big.NewInt(int64(c.max))); j.Cmp(total) < 0; j.Add(j, one) {
+		position := new(big.Int).Rand(rng, new(big.Int).Add(j, one))
+		if _, exist := selected[string(position.Bytes())]; exist {
+			position.Set(j)
+		}
+		selected[string(position.Bytes())] = struct{}{}
+		positions = append(positions, position)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Cmp(positions[j]) < 0
+	})
+	return positions
+}
+
+func (c *cappedIterator) Error() error {
+	return nil
+}
+
+func (c *cappedIterator) Current() *TestCase {
+	return c.current
+}
+
+// decode returns states of the test case at the position in the product.
+// Shorter schedules precede longer schedules, see Total.
+func (g *Generator) decode(position *big.Int) []int {
+	position = new(big.Int).Set(position)
+	length := g.stepLimit
+	if g.prefixes {
+		size := big.NewInt(1)
+		for length = 1; length < g.stepLimit; length++ {
+			size.Mul(size, big.NewInt(int64(len(g.statesAt(length-1)))))
+			if position.Cmp(size) < 0 {
+				break
+			}
+			position.Sub(position, size)
+		}
+	}
+	states := make([]int, length)
+	radix, state := new(big.Int), new(big.Int)
+	for i := length - 1; i >= 0; i-- {
+		radix.SetInt64(int64(len(g.statesAt(i))))
+		position.QuoRem(position, radix, state)
+		states[i] = int(state.Int64())
+	}
+	return states
+}
@@ -0,0 +1,69 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxCases(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	all := collectCases(t, opts...)
+
+	sampled := collectCases(t, append(opts, WithMaxCases(20, 1))...)
+	require.Len(t, sampled, 20)
+	require.Subset(t, all, sampled)
+	// distinct and in the order of the product
+	index := map[string]int{}
+	for i, buf := range all {
+		index[string(buf)] = i
+	}
+	for i := 1; i < len(sampled); i++ {
+		require.Less(t, index[string(sampled[i-1])], index[string(sampled[i])])
+	}
+	require.Equal(t, sampled, collectCases(t, append(opts, WithMaxCases(20, 1))...))
+	require.NotEqual(t, sampled, collectCases(t, append(opts, WithMaxCases(20, 2))...))
+
+	require.Equal(t, all, collectCases(t, append(opts, WithMaxCases(len(all)+1, 1))...))
+	require.Equal(t,
+		collectCases(t, append(opts, WithShorterSchedules())...),
+		collectCases(t, append(opts, WithShorterSchedules(), WithMaxCases(1000, 1))...),
+	)
+
+	_, err := NewGen(append(opts, WithMaxCases(0, 1))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithMaxCases(10, 1), WithSwarm(10, 1))...)
+	require.Error(t, err)
+}
+
+func TestMaxCasesUniform(t *testing.T) {
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(20),
+		WithMaxCases(6000, 1),
+	)
+	require.NoError(t, err)
+	// every state of every step is selected approximately equally often
+	counts := make([][]int, gen.Steps())
+	for i := range counts {
+		counts[i] = make([]int, gen.States(i))
+	}
+	for tc := range gen.All() {
+		for i, state := range tc.states {
+			counts[i][state]++
+		}
+	}
+	require.Equal(t, 6000, gen.Count())
+	for _, step := range counts {
+		for _, cnt := range step {
+			require.InDelta(t, 6000/len(step), cnt, 200)
+		}
+	}
+}
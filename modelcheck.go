@@ -0,0 +1,207 @@
+package paxos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Fingerprinter is implemented by nodes that can be explored with ModelCheck.
+// Replicas with equal fingerprints must make the same decisions on any
+// sequence of messages and proposals.
+type Fingerprinter interface {
+	Cloneable
+	Fingerprint() []byte
+}
+
+// ModelCheckResult is a summary of the explored state graph.
+type ModelCheckResult struct {
+	// States is a number of distinct states of the cluster that were explored.
+	States int
+	// Transitions is a number of executed steps.
+	Transitions int
+	// Counterexample is the shortest test case that violates invariants.
+	// Nil if none was found.
+	Counterexample *TestCase
+	// Err is an error reported by the counterexample.
+	Err error
+}
+
+// ModelCheck explores the graph of the cluster states instead of the schedules:
+// every state of the step is executed on a copy of the cluster, and copies
+// that reached a state that was already reached after the same number of steps
+// are discarded. State of the cluster consists of the fingerprints of
+// the replicas, and of the pending and in-flight messages. Graph is explored
+// breadth first up to Steps() of the generator, so that the counterexample is
+// the shortest. Constraints, sampling and shards of the generator are ignored.
+// Every node must implement Fingerprinter.
+func ModelCheck(gen *Generator, factory NodeFactory, opts ...ClusterOption) (*ModelCheckResult, error) {
+	type vertex struct {
+		cluster *Cluster
+		path    []int
+	}
+	cluster, err := NewCluster(gen.nodes, factory, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cluster.fingerprint(); err != nil {
+		return nil, err
+	}
+	var (
+		rst      = &ModelCheckResult{}
+		frontier = []vertex{{cluster: cluster}}
+	)
+	for depth := 0; depth < gen.stepLimit && len(frontier) > 0; depth++ {
+		var (
+			next []vertex
+			seen = map[string]struct{}{}
+		)
+		for _, v := range frontier {
+			for state := range gen.statesAt(depth) {
+				cluster, err := v.cluster.Clone()
+				if err != nil {
+					return nil, err
+				}
+				path := append(append([]int(nil), v.path...), state)
+				tc := &TestCase{gen: gen, states: path}
+				for i := 0; i < depth; i++ {
+					tc.Next()
+				}
+				rst.Transitions++
+				pruned, err := cluster.stepCase(tc)
+				if err != nil {
+					rst.Counterexample, rst.Err = &TestCase{gen: gen, states: path}, err
+					return rst, nil
+				}
+				if pruned {
+					// equivalent to another path, see WithPartialOrderReduction
+					continue
+				}
+				fingerprint, err := cluster.fingerprint()
+				if err != nil {
+					return nil, err
+				}
+				if _, exist := seen[string(fingerprint)]; exist {
+					continue
+				}
+				seen[string(fingerprint)] = struct{}{}
+				rst.States++
+				next = append(next, vertex{cluster: cluster, path: path})
+			}
+		}
+		frontier = next
+	}
+	for _, v := range frontier {
+		if err := v.cluster.checkLiveness(); err != nil {
+			rst.Counterexample, rst.Err = &TestCase{gen: gen, states: v.path}, err
+			return rst, nil
+		}
+	}
+	return rst, nil
+}
+
+// fingerprint encodes the state of the cluster that affects the next steps
+// and the invariants.
+func (c *Cluster) fingerprint() ([]byte, error) {
+	var buf []byte
+	for _, id := range c.ids {
+		node, ok := c.nodes[id].(Fingerprinter)
+		if !ok {
+			return nil, fmt.Errorf("replica %d of type %T doesn't implement Fingerprinter", id, c.nodes[id])
+		}
+		state, crashed := c.crashed[id]
+		buf = appendBool(buf, crashed)
+		buf = appendBytes(buf, state)
+		buf = appendBytes(buf, node.Fingerprint())
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(c.messages)))
+	for _, msg := range c.messages {
+		buf = appendMessage(buf, msg)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(c.inflight)))
+	for _, m := range c.inflight {
+		buf = binary.AppendUvarint(buf, uint64(m.steps))
+		buf = appendMessage(buf, m.msg)
+	}
+	for _, id := range c.ids {
+		if detector, exist := c.detectors[id]; exist {
+			buf = binary.AppendVarint(buf, int64(detector.tick))
+			for _, other := range c.ids {
+				buf = binary.AppendVarint(buf, int64(detector.lastSeen[other]))
+			}
+			buf = binary.AppendVarint(buf, int64(c.terms[id]))
+		}
+	}
+	// history that is used by the invariants
+	slots := make([]int, 0, len(c.chosen))
+	for slot := range c.chosen {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	for _, slot := range slots {
+		buf = binary.AppendVarint(buf, int64(slot))
+		buf = appendBytes(buf, c.chosen[slot])
+		buf = appendBytes(buf, c.states[slot])
+	}
+	proposed := make([]string, 0, len(c.proposed))
+	for value := range c.proposed {
+		proposed = append(proposed, value)
+	}
+	sort.Strings(proposed)
+	for _, value := range proposed {
+		buf = appendBytes(buf, []byte(value))
+	}
+	outcomes := make([]proposal, 0, len(c.outcomes))
+	for key := range c.outcomes {
+		outcomes = append(outcomes, key)
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		if outcomes[i].replica != outcomes[j].replica {
+			return outcomes[i].replica < outcomes[j].replica
+		}
+		return outcomes[i].id < outcomes[j].id
+	})
+	for _, key := range outcomes {
+		buf = binary.AppendVarint(buf, int64(key.replica))
+		buf = binary.AppendVarint(buf, int64(key.id))
+		buf = append(buf, byte(c.outcomes[key]))
+	}
+	return buf, nil
+}
+
+func appendBool(buf []byte, v bool) []byte {
+	if v {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+// appendBytes encodes length + 1 before the value, so that nil
+// and empty values are different.
+func appendBytes(buf, value []byte) []byte {
+	if value == nil {
+		return binary.AppendUvarint(buf, 0)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(value))+1)
+	return append(buf, value...)
+}
+
+func appendMessage(buf []byte, msg Message) []byte {
+	for _, v := range [...]int{msg.From, msg.To, int(msg.Type), msg.Ballot, msg.VotedBallot, msg.Slot} {
+		buf = binary.AppendVarint(buf, int64(v))
+	}
+	return appendBytes(buf, msg.Value)
+}
+
+func appendSet(buf []byte, set map[int]struct{}) []byte {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	buf = binary.AppendUvarint(buf, uint64(len(ids)))
+	for _, id := range ids {
+		buf = binary.AppendVarint(buf, int64(id))
+	}
+	return buf
+}
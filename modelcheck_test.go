@@ -0,0 +1,60 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelCheck(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}, {4, 5}},
+			[][]int{{1, 2}, {3, 4, 5}},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(6),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	rst, err := ModelCheck(gen, paxosFactory, WithValidation())
+	require.NoError(t, err)
+	require.NoError(t, rst.Err)
+	t.Logf("%d states, %d transitions, %d test cases", rst.States, rst.Transitions, gen.Total())
+	require.Nil(t, rst.Counterexample)
+	require.Less(t, rst.States, rst.Transitions)
+	require.Less(t, int64(rst.Transitions), gen.Total().Int64())
+
+	_, err = ModelCheck(gen, func(id int, nodes []int) (Node, error) {
+		return NewLog(id, nodes)
+	})
+	require.Error(t, err)
+}
+
+func TestModelCheckCounterexample(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3, 4, 5),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3, 4, 5}},
+			[][]int{{1, 2}, {3, 4, 5}},
+		),
+		WithLeaders(1, 3),
+		WithSteps(8),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	rst, err := ModelCheck(gen, brokenQuorumFactory)
+	require.NoError(t, err)
+	require.Error(t, rst.Err)
+	require.NotNil(t, rst.Counterexample)
+	require.NoError(t, rst.Counterexample.validate())
+	require.Error(t, Simulate(brokenQuorumFactory)(rst.Counterexample), "%s", rst.Counterexample)
+
+	// counterexample is the shortest
+	shorter, err := NewGen(append(opts, WithSteps(len(rst.Counterexample.states)-1), WithShorterSchedules())...)
+	require.NoError(t, err)
+	for tc := range shorter.All() {
+		require.NoError(t, Simulate(brokenQuorumFactory)(tc), "%s", tc)
+	}
+}
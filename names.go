@@ -0,0 +1,115 @@
+package paxos
+
+import "fmt"
+
+// WithPartitionName names every configured partition of the replicas into
+// groups, for example "leader isolated". Names replace routes in the
+// description of the test case. Partition must be configured with
+// other options, such as WithExplicitPartitions or WithAllPartitions.
+func WithPartitionName(name string, network [][]int) GenOption {
+	return func(g *Generator) error {
+		if len(name) == 0 {
+			return fmt.Errorf("name of the partition %v is empty", network)
+		}
+		g.names = append(g.names, pendingName{name: name, network: groupPartition(network)})
+		return nil
+	}
+}
+
+// WithActionsName names every configured set of actions that is equal to actions,
+// for example "1 and 2 propose". Names replace actions in the description
+// of the test case.
+func WithActionsName(name string, actions Actions) GenOption {
+	return func(g *Generator) error {
+		if len(name) == 0 {
+			return fmt.Errorf("name of the actions %s is empty", actions)
+		}
+		g.names = append(g.names, pendingName{name: name, actions: actions})
+		return nil
+	}
+}
+
+// pendingName is resolved once all partitions and actions are configured.
+type pendingName struct {
+	name    string
+	network Partition
+	actions Actions
+}
+
+// resolveNames finds partitions and actions that were named.
+func (g *Generator) resolveNames() error {
+	for _, pending := range g.names {
+		found := false
+		if pending.network != nil {
+			for i, network := range g.partitions {
+				if equalPartitions(network, pending.network) {
+					if g.partitionNames == nil {
+						g.partitionNames = map[int]string{}
+					}
+					g.partitionNames[i] = pending.name
+					found = true
+				}
+			}
+		} else {
+			for i, actions := range g.actions {
+				if equalActions(actions, pending.actions) {
+					if g.actionNames == nil {
+						g.actionNames = map[int]string{}
+					}
+					g.actionNames[i] = pending.name
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q doesn't match any of the configured partitions or actions", pending.name)
+		}
+	}
+	return nil
+}
+
+func equalPartitions(a, b Partition) bool {
+	links := 0
+	for from, routes := range a {
+		for to, link := range routes {
+			other, exist := b[from][to]
+			if !exist || other != link {
+				return false
+			}
+			links++
+		}
+	}
+	for _, routes := range b {
+		links -= len(routes)
+	}
+	return links == 0
+}
+
+// equalActions compares actions, replicas without actions are ignored.
+func equalActions(a, b Actions) bool {
+	for id, action := range a {
+		if b[id] != action {
+			return false
+		}
+	}
+	for id, action := range b {
+		if a[id] != action {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Generator) partitionString(i int) string {
+	if name, exist := g.partitionNames[i]; exist {
+		return fmt.Sprintf("Routes(%s)", name)
+	}
+	return g.partitions[i].String()
+}
+
+func (g *Generator) actionsString(i int) string {
+	if name, exist := g.actionNames[i]; exist {
+		return fmt.Sprintf("Cluster(%s)", name)
+	}
+	return g.actions[i].String()
+}
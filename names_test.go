@@ -0,0 +1,41 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNames(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	}
+	gen, err := NewGen(append(opts,
+		WithPartitionName("leader isolated", [][]int{{1}, {2, 3}}),
+		WithActionsName("1 proposes", Actions{1: ActionLead}),
+	)...)
+	require.NoError(t, err)
+	var descriptions []string
+	for tc := range gen.All() {
+		tc.Next()
+		tc.Next()
+		descriptions = append(descriptions, tc.String())
+	}
+	require.Contains(t, descriptions,
+		"step 1: Routes(leader isolated) Cluster(1 proposes)\nstep 2: Routes(leader isolated) Cluster(leader=2)\n")
+	for _, description := range descriptions {
+		require.NotContains(t, description, "Cluster(leader=1)")
+		// routes of the isolated leader are replaced by the name
+		require.NotRegexp(t, `Routes\([23]=>[23],[23]=>[23],\)`, description)
+	}
+
+	_, err = NewGen(append(opts, WithPartitionName("split", [][]int{{1, 2}, {3}}))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithActionsName("3 proposes", Actions{3: ActionLead}))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithActionsName("", Actions{1: ActionLead}))...)
+	require.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package paxos
+
+// Neighborhood executes mutations of the failed test case: adjacent steps are
+// swapped, partition of a step is replaced with another partition, and a
+// replica starts or stops being a leader in a step. Failed and passed mutations
+// map out the boundary of the bug, e.g. passed mutations show which steps
+// are necessary for the failure.
+func Neighborhood(run Runner, tc *TestCase) (failed, passed []*TestCase) {
+	for _, states := range tc.gen.mutations(tc.states) {
+		mutation := &TestCase{gen: tc.gen, states: states}
+		if run(mutation) != nil {
+			failed = append(failed, mutation)
+		} else {
+			passed = append(passed, mutation)
+		}
+	}
+	return failed, passed
+}
+
+// mutations returns distinct schedules that differ from states in one step,
+// or in the order of two adjacent steps.
+func (g *Generator) mutations(states []int) [][]int {
+	var (
+		rst  [][]int
+		seen = map[string]struct{}{prefixKey(states): {}}
+		add  = func(candidate []int) {
+			key := prefixKey(candidate)
+			if _, exist := seen[key]; !exist {
+				seen[key] = struct{}{}
+				rst = append(rst, candidate)
+			}
+		}
+		replace = func(i int, state stepState) {
+			if index, ok := g.findState(i, state); ok {
+				candidate := append([]int(nil), states...)
+				candidate[i] = index
+				add(candidate)
+			}
+		}
+	)
+	for i := 1; i < len(states); i++ {
+		first, ok := g.findState(i, g.statesAt(i - 1)[states[i-1]])
+		if !ok {
+			continue
+		}
+		second, ok := g.findState(i-1, g.statesAt(i)[states[i]])
+		if !ok {
+			continue
+		}
+		candidate := append([]int(nil), states...)
+		candidate[i-1], candidate[i] = second, first
+		add(candidate)
+	}
+	for i := range states {
+		state := g.statesAt(i)[states[i]]
+		for p := range g.partitions {
+			if p != state.partition {
+				replace(i, stepState{actions: state.actions, partition: p, order: state.order})
+			}
+		}
+		current := g.actions[state.actions]
+		for _, id := range g.nodes {
+			toggled := Actions{}
+			for other, action := range current {
+				toggled[other] = action
+			}
+			if current.IsLeader(id) {
+				toggled[id] = toggled[id].WithValue(0) &^ ActionLead
+			} else {
+				toggled[id] |= ActionLead
+			}
+			for a, actions := range g.actions {
+				if equalActions(actions, toggled) {
+					replace(i, stepState{actions: a, partition: state.partition, order: state.order})
+				}
+			}
+		}
+	}
+	return rst
+}
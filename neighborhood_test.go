@@ -0,0 +1,69 @@
+package paxos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeighborhood(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1}, {2, 3}},
+		),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	)
+	require.NoError(t, err)
+	// fails if 1 proposes while isolated, and 2 proposes in the next step
+	run := func(tc *TestCase) error {
+		isolated := false
+		for network, actions := range tc.Steps() {
+			if isolated && actions.IsLeader(2) {
+				return errors.New("failed")
+			}
+			isolated = actions.IsLeader(1) && !network.Reachable(1, 2)
+		}
+		return nil
+	}
+	var failed *TestCase
+	for tc := range gen.All() {
+		if run(&TestCase{gen: gen, states: tc.states}) != nil {
+			failed = &TestCase{gen: gen, states: tc.states}
+			break
+		}
+	}
+	require.NotNil(t, failed)
+
+	fails, passes := Neighborhood(run, failed)
+	require.NotEmpty(t, passes)
+	seen := map[string]struct{}{prefixKey(failed.states): {}}
+	for _, tc := range append(fails, passes...) {
+		require.Len(t, tc.states, len(failed.states))
+		require.NoError(t, tc.validate())
+		key := prefixKey(tc.states)
+		require.NotContains(t, seen, key, "mutations must be distinct")
+		seen[key] = struct{}{}
+
+		// every mutation differs from the failed test case in one step,
+		// or swaps two steps
+		diff := 0
+		for i := range tc.states {
+			if tc.states[i] != failed.states[i] {
+				diff++
+			}
+		}
+		require.NotZero(t, diff)
+	}
+	for _, tc := range fails {
+		require.Error(t, run(&TestCase{gen: gen, states: tc.states}))
+	}
+	for _, tc := range passes {
+		require.NoError(t, run(&TestCase{gen: gen, states: tc.states}))
+	}
+	// swapped steps, partition without isolation and removed leaders pass
+	require.GreaterOrEqual(t, len(passes), 4, "%v", passes)
+}
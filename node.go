@@ -0,0 +1,18 @@
+package paxos
+
+// Node is a consensus replica that can be driven by the simulator.
+// Any implementation that satisfies it can be tested with the same generator
+// and runner that are used for Paxos.
+type Node interface {
+	// Propose starts a new proposal with a given value.
+	Propose(Value)
+	// Step delivers a message to the replica and returns every message
+	// produced since the last call, including messages produced by Propose.
+	// Message with MessageEmpty type is not delivered, it only flushes the outbox.
+	// Returned slice is valid until the next call to Step or Propose.
+	Step(Message) []Message
+	// Learned returns a value selected by the majority or nil.
+	Learned() Value
+}
+
+var _ Node = (*Paxos)(nil)
@@ -0,0 +1,111 @@
+package paxos
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+)
+
+// WithShuffledOrder iterates the exhaustive product in a pseudo-random order,
+// so that early steps vary as often as the last steps. Every test case is still
+// generated exactly once, and the order is the same for the same seed.
+// Checkpoints, shards and symmetry reduction are supported.
+func WithShuffledOrder(seed int64) GenOption {
+	return func(g *Generator) error {
+		if g.order != nil {
+			return errors.New("order of the exhaustive product is already configured")
+		}
+		shuffle := &shuffle{gen: g, seed: seed, perms: map[int]*affine{}}
+		g.order = shuffle.apply
+		return nil
+	}
+}
+
+// WithGrayOrder iterates the exhaustive product in the reflected Gray code order,
+// where consecutive test cases of the same length differ in exactly one step.
+// Paired with SimulateShared a test case usually starts from a copy of
+// the cluster after the steps that it shares with the previous test case.
+func WithGrayOrder() GenOption {
+	return func(g *Generator) error {
+		if g.order != nil {
+			return errors.New("order of the exhaustive product is already configured")
+		}
+		g.order = g.grayCode
+		return nil
+	}
+}
+
+// grayCode maps counters to the reflected mixed radix Gray code: the state
+// of the step is reflected if the number formed by the counters of the previous
+// steps is odd.
+func (g *Generator) grayCode(cnts []int) []int {
+	states := make([]int, len(cnts))
+	odd := false
+	for i, cnt := range cnts {
+		states[i] = cnt
+		if odd {
+			states[i] = len(g.statesAt(i)) - 1 - cnt
+		}
+		odd = (odd && len(g.statesAt(i))%2 == 1) != (cnt%2 == 1)
+	}
+	return states
+}
+
+// shuffle maps the position of the test case in the lexicographic order to
+// another test case with the permutation (a*position + b) mod total,
+// where a is coprime with the total number of test cases of the same length.
+type shuffle struct {
+	gen   *Generator
+	seed  int64
+	perms map[int]*affine
+}
+
+type affine struct {
+	a, b, total *big.Int
+}
+
+func (s *shuffle) apply(cnts []int) []int {
+	perm := s.perms[len(cnts)]
+	if perm == nil {
+		perm = s.permutation(len(cnts))
+		s.perms[len(cnts)] = perm
+	}
+	position := big.NewInt(0)
+	for i, cnt := range cnts {
+		position.Mul(position, big.NewInt(int64(len(s.gen.statesAt(i)))))
+		position.Add(position, big.NewInt(int64(cnt)))
+	}
+	position.Mul(position, perm.a)
+	position.Add(position, perm.b)
+	position.Mod(position, perm.total)
+
+	states := make([]int, len(cnts))
+	radix, state := new(big.Int), new(big.Int)
+	for i := len(states) - 1; i >= 0; i-- {
+		radix.SetInt64(int64(len(s.gen.statesAt(i))))
+		position.QuoRem(position, radix, state)
+		states[i] = int(state.Int64())
+	}
+	return states
+}
+
+func (s *shuffle) permutation(length int) *affine {
+	total := big.NewInt(1)
+	for i := 0; i < length; i++ {
+		total.Mul(total, big.NewInt(int64(len(s.gen.statesAt(i)))))
+	}
+	// every length has its own permutation, otherwise shorter schedules
+	// are explored in the same order as prefixes of the longer ones
+	rng := rand.New(rand.NewSource(s.seed + int64(length)))
+	perm := &affine{a: big.NewInt(1), b: new(big.Int).Rand(rng, total), total: total}
+	if total.Cmp(big.NewInt(2)) <= 0 {
+		return perm
+	}
+	one, gcd := big.NewInt(1), new(big.Int)
+	for {
+		perm.a.Rand(rng, total)
+		if perm.a.Sign() > 0 && gcd.GCD(nil, nil, perm.a, total).Cmp(one) == 0 {
+			return perm
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShuffledOrder(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	all := collectCases(t, opts...)
+	shuffled := collectCases(t, append(opts, WithShuffledOrder(7))...)
+	require.ElementsMatch(t, all, shuffled)
+	require.NotEqual(t, all, shuffled)
+	require.Equal(t, shuffled, collectCases(t, append(opts, WithShuffledOrder(7))...))
+	require.NotEqual(t, shuffled, collectCases(t, append(opts, WithShuffledOrder(8))...))
+
+	// first step varies early
+	gen, err := NewGen(append(opts, WithShuffledOrder(7))...)
+	require.NoError(t, err)
+	first := map[int]struct{}{}
+	for i := 0; i < gen.States(0); i++ {
+		first[gen.Next().states[0]] = struct{}{}
+	}
+	require.Greater(t, len(first), 1)
+
+	require.ElementsMatch(t,
+		collectCases(t, append(opts, WithShorterSchedules())...),
+		collectCases(t, append(opts, WithShorterSchedules(), WithShuffledOrder(7))...),
+	)
+
+	_, err = NewGen(append(opts, WithShuffledOrder(7), WithShuffledOrder(8))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithShuffledOrder(7), WithSwarm(10, 1))...)
+	require.Error(t, err)
+}
+
+func TestShuffledOrderCheckpoint(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+		WithShuffledOrder(7),
+	}
+	all := collectCases(t, opts...)
+
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NotNil(t, gen.Next())
+	}
+	checkpoint, err := gen.Checkpoint()
+	require.NoError(t, err)
+
+	restored, err := NewGen(opts...)
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(checkpoint))
+	var rest [][]byte
+	for tc := restored.Next(); tc != nil; tc = restored.Next() {
+		buf, err := tc.Marshal()
+		require.NoError(t, err)
+		rest = append(rest, buf)
+	}
+	require.Equal(t, all[100:], rest)
+}
+
+func TestGrayOrder(t *testing.T) {
+	// odd number of states in every step
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}, [][]int{{1, 2}, {3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	require.ElementsMatch(t,
+		collectCases(t, append(opts, WithShorterSchedules())...),
+		collectCases(t, append(opts, WithShorterSchedules(), WithGrayOrder())...),
+	)
+
+	gen, err := NewGen(append(opts, WithGrayOrder())...)
+	require.NoError(t, err)
+	require.Equal(t, 1, gen.States(0)%2)
+	var previous []int
+	for tc := gen.Next(); tc != nil; tc = gen.Next() {
+		if previous != nil {
+			diff := 0
+			for i := range previous {
+				if previous[i] != tc.states[i] {
+					diff++
+				}
+			}
+			require.Equal(t, 1, diff, "%v %v", previous, tc.states)
+		}
+		previous = tc.states
+	}
+
+	_, err = NewGen(append(opts, WithGrayOrder(), WithShuffledOrder(1))...)
+	require.Error(t, err)
+}
@@ -0,0 +1,336 @@
+package paxos
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// WithAllPartitions generates every partition of the replicas into at most
+// maxGroups groups. Replicas in the same group can reach each other,
+// replicas in different groups can't. If maxGroups is 0 number of groups is
+// not limited. Must be configured after replicas.
+//
+// Number of partitions grows fast (Bell numbers): 5 for 3 replicas,
+// 52 for 5 replicas and 877 for 7 replicas.
+func WithAllPartitions(maxGroups int) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil {
+			return fmt.Errorf("replicas must be configured earlier than partitions")
+		}
+		if maxGroups < 0 {
+			return fmt.Errorf("max groups %d must not be negative", maxGroups)
+		}
+		if maxGroups == 0 {
+			maxGroups = len(g.nodes)
+		}
+		// groups[i] is a group of the i-th replica. enumerated as restricted
+		// growth strings: replica may join any existing group or start a new one.
+		groups := make([]int, len(g.nodes))
+		var enumerate func(i, used int)
+		enumerate = func(i, used int) {
+			if i == len(groups) {
+				network := make([][]int, used)
+				for replica, group := range groups {
+					network[group] = append(network[group], g.nodes[replica])
+				}
+				g.partitions = append(g.partitions, groupPartition(network))
+				return
+			}
+			for group := 0; group < used; group++ {
+				groups[i] = group
+				enumerate(i+1, used)
+			}
+			if used < maxGroups {
+				groups[i] = used
+				enumerate(i+1, used+1)
+			}
+		}
+		enumerate(0, 0)
+		return nil
+	}
+}
+
+// WithLinkFailures generates every network state where up to maxFailedLinks
+// links between replicas are failed, and all other links are healthy.
+// Unlike partitions into groups it covers partial connectivity,
+// for example when two replicas can reach each other only through a third one.
+// Must be configured after replicas.
+func WithLinkFailures(maxFailedLinks int) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil {
+			return fmt.Errorf("replicas must be configured earlier than partitions")
+		}
+		var links [][2]int
+		for i, from := range g.nodes {
+			for _, to := range g.nodes[i+1:] {
+				links = append(links, [2]int{from, to})
+			}
+		}
+		return failLinks(g, links, maxFailedLinks, Partition.Add)
+	}
+}
+
+// WithOneWayLinkFailures is the same as WithLinkFailures, but every direction
+// of the link fails independently. For example replica 1 may be able to
+// send messages to replica 2, but not receive replies from it.
+func WithOneWayLinkFailures(maxFailedLinks int) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil {
+			return fmt.Errorf("replicas must be configured earlier than partitions")
+		}
+		var links [][2]int
+		for _, from := range g.nodes {
+			for _, to := range g.nodes {
+				if from != to {
+					links = append(links, [2]int{from, to})
+				}
+			}
+		}
+		return failLinks(g, links, maxFailedLinks, Partition.AddOneWay)
+	}
+}
+
+// failLinks adds a partition for every combination of up to maxFailed links
+// that are not connected.
+func failLinks(g *Generator, links [][2]int, maxFailed int, connect func(Partition, int, int)) error {
+	if maxFailed < 0 || maxFailed > len(links) {
+		return fmt.Errorf("max failed links %d must be in range of [0, %d]", maxFailed, len(links))
+	}
+	failed := make([]bool, len(links))
+	// failed links are selected in increasing order of the index,
+	// so that every combination is generated once
+	var enumerate func(start, budget int)
+	enumerate = func(start, budget int) {
+		partition := Partition{}
+		for i, link := range links {
+			if !failed[i] {
+				connect(partition, link[0], link[1])
+			}
+		}
+		g.partitions = append(g.partitions, partition)
+		if budget == 0 {
+			return
+		}
+		for i := start; i < len(links); i++ {
+			failed[i] = true
+			enumerate(i+1, budget-1)
+			failed[i] = false
+		}
+	}
+	enumerate(0, maxFailed)
+	return nil
+}
+
+// WithRandomPartitions generates count network states where every link
+// between replicas is healthy with probability 1/2. The same seed generates
+// the same network states. Must be configured after replicas.
+func WithRandomPartitions(count int, seed int64) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil {
+			return fmt.Errorf("replicas must be configured earlier than partitions")
+		}
+		if count <= 0 {
+			return fmt.Errorf("count %d must be positive", count)
+		}
+		rng := rand.New(rand.NewSource(seed))
+		for i := 0; i < count; i++ {
+			partition := Partition{}
+			for j, from := range g.nodes {
+				for _, to := range g.nodes[j+1:] {
+					if rng.Intn(2) == 0 {
+						partition.Add(from, to)
+					}
+				}
+			}
+			g.partitions = append(g.partitions, partition)
+		}
+		return nil
+	}
+}
+
+// groupPartition connects every pair of replicas within the same group.
+func groupPartition(network [][]int) Partition {
+	partition := Partition{}
+	for _, nodes := range network {
+		for i := 0; i < len(nodes)-1; i++ {
+			for _, to := range nodes[i+1:] {
+				partition.Add(nodes[i], to)
+			}
+		}
+	}
+	return partition
+}
+
+// WithSlowLinks extends every configured partition with partitions where up to
+// maxSlowLinks reachable one way links deliver messages later, after one of
+// the delays (in steps). Messages that are sent over fast and slow links are
+// reordered across steps. Must be configured after partitions.
+func WithSlowLinks(maxSlowLinks int, delays ...int) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil || g.partitions == nil {
+			return fmt.Errorf("replicas and partitions must be configured earlier than slow links")
+		}
+		if maxSlowLinks <= 0 {
+			return fmt.Errorf("max slow links %d must be positive", maxSlowLinks)
+		}
+		if len(delays) == 0 {
+			return fmt.Errorf("delays are not configured")
+		}
+		for _, delay := range delays {
+			if delay <= 0 {
+				return fmt.Errorf("delay %d must be positive", delay)
+			}
+		}
+		partitions := g.partitions
+		for _, network := range partitions {
+			var links [][2]int
+			for _, from := range g.nodes {
+				for _, to := range g.nodes {
+					if from != to && network.Reachable(from, to) {
+						links = append(links, [2]int{from, to})
+					}
+				}
+			}
+			// slow links are selected in increasing order of the index,
+			// so that every combination is generated once
+			var enumerate func(start, budget int, slow Partition)
+			enumerate = func(start, budget int, slow Partition) {
+				if budget == 0 {
+					return
+				}
+				for i := start; i < len(links); i++ {
+					for _, delay := range delays {
+						extended := slow.clone()
+						extended.AddDelayed(links[i][0], links[i][1], delay)
+						g.partitions = append(g.partitions, extended)
+						enumerate(i+1, budget-1, extended)
+					}
+				}
+			}
+			enumerate(0, maxSlowLinks, network)
+		}
+		return nil
+	}
+}
+
+// WithLossyLinks extends every configured partition with a partition for
+// every loss probability (in percents), where every reachable link loses
+// messages with that probability. Lost messages are selected with
+// TestCase.Rand, so they are reproduced when test case is replayed.
+// Intended for sampled runs, since every schedule explores a single
+// combination of lost messages. Must be configured after partitions.
+func WithLossyLinks(losses ...int) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil || g.partitions == nil {
+			return fmt.Errorf("replicas and partitions must be configured earlier than lossy links")
+		}
+		if len(losses) == 0 {
+			return fmt.Errorf("losses are not configured")
+		}
+		for _, loss := range losses {
+			if loss <= 0 || loss > 100 {
+				return fmt.Errorf("loss %d must be in range of [1, 100]", loss)
+			}
+		}
+		partitions := g.partitions
+		for _, network := range partitions {
+			for _, loss := range losses {
+				lossy := Partition{}
+				for from, routes := range network {
+					for to, link := range routes {
+						link.Loss = loss
+						lossy.AddLink(from, to, link)
+					}
+				}
+				g.partitions = append(g.partitions, lossy)
+			}
+		}
+		return nil
+	}
+}
+
+// WithPartitions adds network states that were built with partition
+// combinators, for example:
+//
+//	WithPartitions(
+//		FullyConnected(1, 2, 3, 4, 5),
+//		MajorityMinority(1, 2, 3, 4, 5),
+//		FullyConnected(1, 2, 3, 4, 5).IsolateNode(1),
+//	)
+func WithPartitions(networks ...Partition) GenOption {
+	return func(g *Generator) error {
+		for _, network := range networks {
+			g.partitions = append(g.partitions, network.clone())
+		}
+		return nil
+	}
+}
+
+// FullyConnected returns a partition where every replica can reach every other replica.
+func FullyConnected(nodes ...int) Partition {
+	return groupPartition([][]int{nodes})
+}
+
+// MajorityMinority returns a partition where the first majority of the replicas
+// can reach each other, and the rest of the replicas can reach each other.
+func MajorityMinority(nodes ...int) Partition {
+	majority := len(nodes)/2 + 1
+	return groupPartition([][]int{nodes[:majority], nodes[majority:]})
+}
+
+// IsolateNode returns a copy of the partition where replica can't reach
+// other replicas, and other replicas can't reach it.
+func (p Partition) IsolateNode(id int) Partition {
+	rst := Partition{}
+	for from, routes := range p {
+		for to, link := range routes {
+			if from != id && to != id {
+				rst.AddLink(from, to, link)
+			}
+		}
+	}
+	return rst
+}
+
+// Union returns a partition with routes from both partitions.
+// Links of the partition take precedence over links of the other partition.
+func (p Partition) Union(other Partition) Partition {
+	rst := other.clone()
+	for from, routes := range p {
+		for to, link := range routes {
+			rst.AddLink(from, to, link)
+		}
+	}
+	return rst
+}
+
+// Invert returns a partition where replicas can reach each other
+// only if they can't reach each other in the partition.
+func (p Partition) Invert(nodes ...int) Partition {
+	rst := Partition{}
+	for _, from := range nodes {
+		for _, to := range nodes {
+			if from != to && !p.Reachable(from, to) {
+				rst.AddOneWay(from, to)
+			}
+		}
+	}
+	return rst
+}
+
+// Without returns a copy of the partition where replicas can't reach each other
+// in both directions.
+func (p Partition) Without(from, to int) Partition {
+	return p.WithoutOneWay(from, to).WithoutOneWay(to, from)
+}
+
+// WithoutOneWay returns a copy of the partition where messages from one
+// replica can't reach the other.
+func (p Partition) WithoutOneWay(from, to int) Partition {
+	rst := p.clone()
+	delete(rst[from], to)
+	if len(rst[from]) == 0 {
+		delete(rst, from)
+	}
+	return rst
+}
@@ -1,6 +1,13 @@
 package paxos
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+)
 
 type MessageType int8
 
@@ -10,6 +17,13 @@ const (
 	MessagePromise
 	MessageAccept
 	MessageAccepted
+	MessageReject
+	MessageLearned
+	MessageSnapshot
+	MessageLeaseRequest
+	MessageLeaseGrant
+	MessageLeaseUpdate
+	MessageLeaseAck
 )
 
 var messageTypeString = [...]string{
@@ -18,6 +32,13 @@ var messageTypeString = [...]string{
 	"Promise",
 	"Accept",
 	"Accepted",
+	"Reject",
+	"Learned",
+	"Snapshot",
+	"LeaseRequest",
+	"LeaseGrant",
+	"LeaseUpdate",
+	"LeaseAck",
 }
 
 func (m MessageType) String() string {
@@ -40,17 +61,23 @@ type Message struct {
 
 	Ballot int
 	// non-null if:
-	// Promise - previously selected value
-	// Accept  - value to select in current ballot
+	// Promise  - previously selected value
+	// Accept   - value to select in current ballot
+	// Accepted - value that was selected by the acceptor
+	// Learned  - value that was selected by the majority
+	// Snapshot - state of the machine before the Slot
 	Value Value
 
 	// non-null only if the Type is Promise
 	VotedBallot int
+
+	// instance of the multi-instance log. always 0 for a single instance.
+	Slot int
 }
 
 func (m Message) String() string {
-	return fmt.Sprintf("Msg[From=%d To=%d Ballot=%d Type=%s VBallot=%d Value=%s]",
-		m.From, m.To, m.Ballot, m.Type, m.VotedBallot, m.Value,
+	return fmt.Sprintf("Msg[From=%d To=%d Slot=%d Ballot=%d Type=%s VBallot=%d Value=%s]",
+		m.From, m.To, m.Slot, m.Ballot, m.Type, m.VotedBallot, m.Value,
 	)
 }
 
@@ -62,13 +89,30 @@ type Paxos struct {
 	// Round 1 and 2 majorities
 	// For correctness it is not necessary to get exactly half + 1 responses
 	// in every round, but it is necessary to get an intersection between two
-	// majorities
+	// majorities.
+	// If Weights are provided majorities are expressed as a total weight
+	// of the replicas, otherwise as a number of replicas.
 	R1Majority, R2Majority int
 
+	// voting weight of every replica. if nil every replica has weight 1,
+	// otherwise replica that is not in the map has weight 0.
+	Weights map[int]int
+
+	// MaxBallot is the highest ballot that can be used by the replica.
+	// Once it is reached replica stops proposing. If zero, max int is used.
+	MaxBallot int
+
+	// Witnesses never vote and never propose, they learn a value by
+	// collecting Accepted messages that are sent to them by every acceptor.
+	Witnesses []int
+
 	// current ballot. monotonically growing.
 	// persisted in practice
 	ballot int
 
+	// phase of the proposal started by this replica.
+	phase Phase
+
 	// tracking Promises
 	promises map[int]struct{}
 	// value from a Promise with a highest observed ballot.
@@ -79,6 +123,11 @@ type Paxos struct {
 	// tracking Accepted
 	accepts map[int]struct{}
 
+	// tracking Accepted on a witness for the highest observed ballot.
+	learnBallot  int
+	learnValue   Value
+	learnAccepts map[int]struct{}
+
 	// selected value. persisted
 	votedValue Value
 	// ballot when the value was selected. persisted
@@ -87,24 +136,101 @@ type Paxos struct {
 	// value proposed by this node.
 	value Value
 
+	// values of every proposal started by this node. proposal id is an index + 1.
+	proposals []Value
+
 	// value selected by the majority.
 	// Algorithm is invalid if it is modified more to any other value after
 	// it was updated the first time.
 	LearnedValue Value
 
+	// first invariant violation observed by the replica.
+	err error
+
 	// current replica outbox. only messages to other nodes.
 	// state changes to the current replica are applied immediatly.
-	Messages []Message
+	// drained by ReadMessages or Step.
+	messages []Message
+}
+
+type PaxosOption func(p *Paxos) error
+
+// WithMajorities overwrites majorities that are computed from the replicas.
+func WithMajorities(r1, r2 int) PaxosOption {
+	return func(p *Paxos) error {
+		p.R1Majority = r1
+		p.R2Majority = r2
+		return nil
+	}
+}
+
+// WithWeights configures voting weight of every replica.
+func WithWeights(weights map[int]int) PaxosOption {
+	return func(p *Paxos) error {
+		p.Weights = weights
+		return nil
+	}
+}
+
+// WithWitnesses configures replicas that don't vote.
+func WithWitnesses(witnesses ...int) PaxosOption {
+	return func(p *Paxos) error {
+		p.Witnesses = witnesses
+		return nil
+	}
+}
+
+// WithBallotWidth limits ballots to the given number of bits. Allows to exercise
+// ballot exhaustion in a small number of steps.
+func WithBallotWidth(bits int) PaxosOption {
+	return func(p *Paxos) error {
+		if bits <= 0 || bits >= strconv.IntSize {
+			return fmt.Errorf("ballot width %d must be in range of [1, %d)", bits, strconv.IntSize)
+		}
+		p.MaxBallot = 1<<bits - 1
+		return nil
+	}
+}
+
+// NewPaxos creates a replica with id. By default majorities are computed as
+// half of the total weight of the nodes + 1.
+func NewPaxos(id int, nodes []int, opts ...PaxosOption) (*Paxos, error) {
+	p := &Paxos{ID: id, Nodes: nodes}
+	if !p.isMember(id) {
+		return nil, fmt.Errorf("replica %d is not in the list of nodes %v", id, nodes)
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	if p.R1Majority == 0 && p.R2Majority == 0 {
+		total := 0
+		for _, node := range nodes {
+			total += p.Weight(node)
+		}
+		p.R1Majority = total/2 + 1
+		p.R2Majority = total/2 + 1
+	}
+	if err := p.ValidateQuorums(); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 func (p *Paxos) Propose(value Value) {
+	if p.IsWitness(p.ID) || p.Exhausted() {
+		return
+	}
 	// Phase 1A.
 	// Increment a ballot and send Prepare to every other Acceptor.
 	p.value = value
+	p.proposals = append(p.proposals, value)
 	p.ballot++
+	p.phase = PhasePrepare
 	for _, id := range p.Nodes {
 		if id != p.ID {
-			p.Messages = append(p.Messages, Message{
+			p.messages = append(p.messages, Message{
 				From:   p.ID,
 				To:     id,
 				Type:   MessagePrepare,
@@ -120,27 +246,207 @@ func (p *Paxos) Propose(value Value) {
 	p.accepts = map[int]struct{}{}
 }
 
-func (p *Paxos) updatePromise(id int, votedValue Value, votedBallot int) {
-	p.promises[id] = struct{}{}
+// Proposal returns id of the last proposal started by Propose.
+// Ids start from 1 and grow with every Propose that wasn't ignored.
+// 0 if replica never proposed.
+func (p *Paxos) Proposal() int {
+	return len(p.proposals)
+}
+
+// Outcome returns the outcome of the proposal with id.
+// Chosen and Rejected are final. OutcomeUnknown is returned if the replica
+// didn't make a proposal with the id.
+func (p *Paxos) Outcome(id int) Outcome {
+	if id <= 0 || id > len(p.proposals) {
+		return OutcomeUnknown
+	}
+	switch {
+	case p.LearnedValue != nil && bytes.Equal(p.LearnedValue, p.proposals[id-1]):
+		return OutcomeChosen
+	case p.LearnedValue != nil:
+		return OutcomeRejected
+	case id < len(p.proposals):
+		return OutcomeSuperseded
+	}
+	return OutcomePending
+}
+
+// Exhausted returns true if replica can't start a new ballot without overflow.
+func (p *Paxos) Exhausted() bool {
+	max := p.MaxBallot
+	if max == 0 {
+		max = math.MaxInt64 >> (64 - strconv.IntSize)
+	}
+	return p.ballot >= max
+}
+
+// updatePromise returns true once, when promises reach the round 1 majority.
+func (p *Paxos) updatePromise(id int, votedValue Value, votedBallot int) bool {
 	if votedBallot > p.promiseBallot {
 		p.promiseBallot = votedBallot
 		p.promiseValue = votedValue
 	}
+	return p.vote(p.promises, id, p.R1Majority)
+}
+
+// vote adds id to votes and returns true once, when total weight
+// of the votes reaches the majority.
+func (p *Paxos) vote(votes map[int]struct{}, id, majority int) bool {
+	if _, exist := votes[id]; exist {
+		return false
+	}
+	before := p.weightOf(votes)
+	votes[id] = struct{}{}
+	return before < majority && before+p.Weight(id) >= majority
+}
+
+func (p *Paxos) weightOf(votes map[int]struct{}) int {
+	if p.Weights == nil && len(p.Witnesses) == 0 {
+		// every vote has weight of 1
+		return len(votes)
+	}
+	total := 0
+	for id := range votes {
+		total += p.Weight(id)
+	}
+	return total
+}
+
+func (p *Paxos) acceptQuorum() int {
+	return p.R2Majority
+}
+
+// IsWitness returns true if replica doesn't vote.
+func (p *Paxos) IsWitness(id int) bool {
+	for _, witness := range p.Witnesses {
+		if witness == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Weight returns a voting weight of the replica.
+func (p *Paxos) Weight(id int) int {
+	if p.IsWitness(id) {
+		return 0
+	}
+	if p.Weights == nil {
+		return 1
+	}
+	return p.Weights[id]
+}
+
+// ValidateQuorums verifies that majorities can be reached and that every
+// round 1 majority intersects with every round 2 majority.
+func (p *Paxos) ValidateQuorums() error {
+	total := 0
+	for _, id := range p.Nodes {
+		w := p.Weight(id)
+		if w < 0 {
+			return fmt.Errorf("replica %d has negative weight %d", id, w)
+		}
+		total += w
+	}
+	if p.R1Majority <= 0 || p.R1Majority > total {
+		return fmt.Errorf("round 1 majority %d must be in range of [1, %d]", p.R1Majority, total)
+	}
+	if p.R2Majority <= 0 || p.R2Majority > total {
+		return fmt.Errorf("round 2 majority %d must be in range of [1, %d]", p.R2Majority, total)
+	}
+	if p.R1Majority+p.R2Majority <= total {
+		return fmt.Errorf("majorities %d and %d don't intersect with total weight %d",
+			p.R1Majority, p.R2Majority, total)
+	}
+	return nil
+}
+
+// Validate checks internal invariants of the replica. Error means that
+// the replica was modified in a way that is not allowed by the algorithm.
+func (p *Paxos) Validate() error {
+	if err := p.ValidateQuorums(); err != nil {
+		return err
+	}
+	if p.votedBallot > p.ballot {
+		return fmt.Errorf("replica %d voted in ballot %d higher than current ballot %d",
+			p.ID, p.votedBallot, p.ballot)
+	}
+	for _, votes := range [...]map[int]struct{}{p.promises, p.accepts} {
+		for id := range votes {
+			if !p.isMember(id) {
+				return fmt.Errorf("replica %d counted a vote from non-member %d", p.ID, id)
+			}
+		}
+	}
+	switch p.phase {
+	case PhasePrepare:
+		// promises are reset when ballot changes, majority moves replica to the next phase
+		if weight := p.weightOf(p.promises); weight >= p.R1Majority {
+			return fmt.Errorf("replica %d collected promises with weight %d in ballot %d but didn't send accepts",
+				p.ID, weight, p.ballot)
+		}
+	case PhaseAccept:
+		if p.votedBallot != p.ballot {
+			return fmt.Errorf("replica %d accepts ballot %d without own vote, voted ballot %d",
+				p.ID, p.ballot, p.votedBallot)
+		}
+		if _, exist := p.accepts[p.ID]; !exist {
+			return fmt.Errorf("replica %d doesn't count own vote in ballot %d", p.ID, p.ballot)
+		}
+	}
+	// value learned from own accepts must be the value that the replica voted for
+	if p.LearnedValue != nil && p.votedBallot == p.ballot &&
+		p.weightOf(p.accepts) >= p.R2Majority && !bytes.Equal(p.LearnedValue, p.votedValue) {
+		return fmt.Errorf("replica %d learned %v but voted for %v in ballot %d",
+			p.ID, p.LearnedValue, p.votedValue, p.ballot)
+	}
+	return nil
+}
+
+func (p *Paxos) isMember(id int) bool {
+	for _, node := range p.Nodes {
+		if node == id {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Paxos) Next(m Message) {
+	if m.To != p.ID {
+		panic(fmt.Errorf("id mismatch. destination %d, received %d", m.To, p.ID))
+	}
+	if m.Type == MessagePrepare && m.Ballot <= p.ballot && !p.IsWitness(p.ID) {
+		// Prepare is refused. Reply with the current ballot so that the proposer
+		// can start the next ballot right above it.
+		p.messages = append(p.messages, Message{
+			From:   p.ID,
+			To:     m.From,
+			Type:   MessageReject,
+			Ballot: p.ballot,
+		})
+		return
+	}
+	if m.Type == MessageLearned {
+		// Phase 3. Chosen value never changes, therefore it is learned
+		// regardless of the ballot.
+		p.setLearned(m.Value)
+		return
+	}
 	if m.Ballot < p.ballot {
 		return
 	}
-	if m.To != p.ID {
-		panic(fmt.Errorf("id mismatch. destination %d, received %d", m.To, p.ID))
+	if p.IsWitness(p.ID) {
+		p.learn(m)
+		return
 	}
 	switch m.Type {
 	case MessagePrepare:
 		// Phase 1B. If msg ballot is higher than the local ballot reply with Promise and save the ballot.
 		if m.Ballot > p.ballot {
 			p.ballot = m.Ballot
-			p.Messages = append(p.Messages, Message{
+			p.phase = PhaseIdle
+			p.messages = append(p.messages, Message{
 				From:        p.ID,
 				To:          m.From,
 				Type:        MessagePromise,
@@ -154,8 +460,7 @@ func (p *Paxos) Next(m Message) {
 		// promise with the highest observed voted ballot.
 		// If there is no existing non-null promise propose locally chosen value.
 		if m.Ballot == p.ballot {
-			p.updatePromise(m.From, m.Value, m.VotedBallot)
-			if len(p.promises) == p.R1Majority {
+			if p.updatePromise(m.From, m.Value, m.VotedBallot) {
 				if p.promiseValue == nil {
 					p.promiseValue = p.value
 				}
@@ -163,7 +468,7 @@ func (p *Paxos) Next(m Message) {
 					if id == p.ID {
 						continue
 					}
-					p.Messages = append(p.Messages, Message{
+					p.messages = append(p.messages, Message{
 						From:   p.ID,
 						To:     id,
 						Type:   MessageAccept,
@@ -175,30 +480,247 @@ func (p *Paxos) Next(m Message) {
 				p.votedValue = p.promiseValue
 				p.votedBallot = p.ballot
 				p.accepts[p.ID] = struct{}{}
+				p.phase = PhaseAccept
+				p.notifyWitnesses(p.ID, p.ballot, p.votedValue)
 			}
 		}
 	case MessageAccept:
 		// Phase 2B. If Accept ballot is atleast as high as a local ballot
 		// save proposed value and ballot and reply with Accepted.
 		if m.Ballot >= p.ballot {
+			if m.Ballot > p.ballot {
+				p.phase = PhaseIdle
+			}
 			p.ballot = m.Ballot
 			p.votedValue = m.Value
 			p.votedBallot = m.Ballot
-			p.Messages = append(p.Messages, Message{
+			p.messages = append(p.messages, Message{
 				From:   p.ID,
 				To:     m.From,
 				Type:   MessageAccepted,
 				Ballot: m.Ballot,
+				Value:  m.Value,
 			})
+			p.notifyWitnesses(m.From, m.Ballot, m.Value)
+		}
+	case MessageReject:
+		// Proposer abandons current ballot and adopts a higher ballot.
+		// Next Propose will use a ballot that is higher than any ballot known by the acceptor.
+		if m.Ballot > p.ballot {
+			p.ballot = m.Ballot
+			p.phase = PhaseIdle
 		}
 	case MessageAccepted:
 		// Collect Accepted from majority, set Learned value to
 		// previously chosen value.
-		if m.Ballot == p.ballot {
-			p.accepts[m.From] = struct{}{}
-			if len(p.accepts) == p.R2Majority {
-				p.LearnedValue = p.votedValue
+		// Accepted with a value that doesn't match the proposed value is ignored.
+		if m.Ballot == p.ballot && bytes.Equal(m.Value, p.votedValue) {
+			if p.vote(p.accepts, m.From, p.R2Majority) {
+				p.setLearned(p.votedValue)
+				p.phase = PhaseIdle
+				for _, id := range p.Nodes {
+					if id == p.ID {
+						continue
+					}
+					p.messages = append(p.messages, Message{
+						From:   p.ID,
+						To:     id,
+						Type:   MessageLearned,
+						Ballot: p.ballot,
+						Value:  p.LearnedValue,
+					})
+				}
 			}
 		}
 	}
 }
+
+// setLearned updates LearnedValue. If a different value was learned before
+// the original value is preserved and the violation is reported by Err.
+func (p *Paxos) setLearned(value Value) {
+	if p.LearnedValue != nil && !bytes.Equal(p.LearnedValue, value) {
+		if p.err == nil {
+			p.err = fmt.Errorf("replica %d learned %v after %v", p.ID, value, p.LearnedValue)
+		}
+		return
+	}
+	p.LearnedValue = value
+}
+
+// Err returns an error if the replica observed a violation of the
+// algorithm invariants, such as learning two different values.
+func (p *Paxos) Err() error {
+	return p.err
+}
+
+// notifyWitnesses sends Accepted to every witness, except the proposer.
+func (p *Paxos) notifyWitnesses(proposer, ballot int, value Value) {
+	for _, id := range p.Witnesses {
+		if id == proposer {
+			continue
+		}
+		p.messages = append(p.messages, Message{
+			From:   p.ID,
+			To:     id,
+			Type:   MessageAccepted,
+			Ballot: ballot,
+			Value:  value,
+		})
+	}
+}
+
+// learn collects Accepted on a witness.
+// Witness learns a value once majority of acceptors accepted it in the same ballot.
+func (p *Paxos) learn(m Message) {
+	if m.Type != MessageAccepted || m.Ballot < p.learnBallot {
+		return
+	}
+	if m.Ballot > p.learnBallot || p.learnAccepts == nil {
+		p.learnBallot = m.Ballot
+		p.learnValue = m.Value
+		p.learnAccepts = map[int]struct{}{}
+	}
+	if !bytes.Equal(m.Value, p.learnValue) {
+		return
+	}
+	if p.vote(p.learnAccepts, m.From, p.R2Majority) {
+		p.setLearned(p.learnValue)
+	}
+}
+
+// Step delivers a message and returns accumulated outbox.
+func (p *Paxos) Step(m Message) []Message {
+	if m.Type != MessageEmpty {
+		p.Next(m)
+	}
+	messages := p.messages
+	p.messages = p.messages[:0]
+	return messages
+}
+
+// ReadMessages appends outbox to buf and clears the outbox.
+// Internal buffer of the outbox is reused, so that runners that pass
+// the same buf don't allocate on every step.
+func (p *Paxos) ReadMessages(buf []Message) []Message {
+	buf = append(buf, p.messages...)
+	p.messages = p.messages[:0]
+	return buf
+}
+
+func (p *Paxos) Learned() Value {
+	return p.LearnedValue
+}
+
+// Clone returns a deep copy of the replica. Values are immutable and shared
+// with the copy.
+func (p *Paxos) Clone() *Paxos {
+	clone := *p
+	clone.promises = copySet(p.promises)
+	clone.accepts = copySet(p.accepts)
+	clone.learnAccepts = copySet(p.learnAccepts)
+	if p.proposals != nil {
+		clone.proposals = append([]Value(nil), p.proposals...)
+	}
+	if p.messages != nil {
+		clone.messages = append([]Message(nil), p.messages...)
+	}
+	return &clone
+}
+
+// CloneNode returns Clone as a Node.
+func (p *Paxos) CloneNode() Node {
+	return p.Clone()
+}
+
+func copySet(set map[int]struct{}) map[int]struct{} {
+	if set == nil {
+		return nil
+	}
+	rst := make(map[int]struct{}, len(set))
+	for id := range set {
+		rst[id] = struct{}{}
+	}
+	return rst
+}
+
+// Hash returns a stable digest of the ballot, voted ballot and value, and learned value.
+// Replicas with equal hashes will make the same decisions as acceptors.
+func (p *Paxos) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, v := range [...]int{p.ballot, p.votedBallot} {
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+	for _, v := range [...]Value{p.votedValue, p.LearnedValue} {
+		// length + 1 is written before the value so that values at different
+		// positions produce different digests, and nil is different from empty.
+		binary.LittleEndian.PutUint64(buf[:], 0)
+		if v != nil {
+			binary.LittleEndian.PutUint64(buf[:], uint64(len(v))+1)
+		}
+		h.Write(buf[:])
+		h.Write(v)
+	}
+	return h.Sum64()
+}
+
+// Fingerprint encodes the whole state of the replica, including the state
+// of its own proposal. See ModelCheck.
+func (p *Paxos) Fingerprint() []byte {
+	var buf []byte
+	for _, v := range [...]int{p.ballot, int(p.phase), p.promiseBallot, p.learnBallot, p.votedBallot} {
+		buf = binary.AppendVarint(buf, int64(v))
+	}
+	for _, v := range [...]Value{p.promiseValue, p.learnValue, p.votedValue, p.value, p.LearnedValue} {
+		buf = appendBytes(buf, v)
+	}
+	for _, set := range [...]map[int]struct{}{p.promises, p.accepts, p.learnAccepts} {
+		buf = appendSet(buf, set)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(p.proposals)))
+	for _, v := range p.proposals {
+		buf = appendBytes(buf, v)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(p.messages)))
+	for _, msg := range p.messages {
+		buf = appendMessage(buf, msg)
+	}
+	return appendBool(buf, p.err != nil)
+}
+
+// MarshalBinary encodes persisted state of the replica: ballot, voted ballot and voted value.
+func (p *Paxos) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	lth := int64(-1)
+	if p.votedValue != nil {
+		lth = int64(len(p.votedValue))
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, [...]int64{int64(p.ballot), int64(p.votedBallot), lth}); err != nil {
+		return nil, err
+	}
+	buf.Write(p.votedValue)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores persisted state of the replica.
+// Configuration and volatile state are not modified.
+func (p *Paxos) UnmarshalBinary(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	var header [3]int64
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	var value Value
+	if lth := header[2]; lth >= 0 {
+		if lth > int64(buf.Len()) {
+			return fmt.Errorf("voted value length %d is out of bounds", lth)
+		}
+		value = make(Value, lth)
+		copy(value, buf.Next(int(lth)))
+	}
+	p.ballot = int(header[0])
+	p.votedBallot = int(header[1])
+	p.votedValue = value
+	return nil
+}
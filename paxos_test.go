@@ -1,61 +1,420 @@
 package paxos
 
 import (
-	"bytes"
-	"fmt"
+	"flag"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
-func paxosRunner(tc *TestCase) error {
-	messages := []Message{}
-	delayed := []Message{}
+// runFlags configures Run from the flags, for example -replay or -percent.
+var runFlags = RegisterFlags(flag.CommandLine)
 
-	nodes := tc.Nodes()
-	cluster := make(map[int]*Paxos, len(nodes))
-	for _, id := range nodes {
-		cluster[id] = &Paxos{ID: id, Nodes: nodes, R1Majority: 3, R2Majority: 3}
+func paxosFactory(id int, nodes []int) (Node, error) {
+	return NewPaxos(id, nodes)
+}
+
+// brokenQuorumFactory creates replicas with quorums of 2, that don't intersect
+// in a cluster of 5 replicas.
+func brokenQuorumFactory(id int, nodes []int) (Node, error) {
+	p, err := NewPaxos(id, nodes)
+	if err != nil {
+		return nil, err
 	}
+	p.R1Majority, p.R2Majority = 2, 2
+	return p, nil
+}
 
-	for {
-		network, actions := tc.Next()
-		if network == nil || actions == nil {
-			return nil
-		}
+func TestPaxos(t *testing.T) {
+	steps := 9
+	if testing.Short() {
+		steps = 7
+	}
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+				{4, 5},
+			},
+			[][]int{
+				{1, 2},
+				{3, 4, 5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(steps),
+	)
+}
 
-		for _, node := range cluster {
-			if actions.IsLeader(node.ID) {
-				node.Propose([]byte{byte(node.ID)})
-			}
-			messages = append(messages, node.Messages...)
-			node.Messages = node.Messages[:0]
-		}
+func TestAcceptedValueMismatch(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	p.Propose(Value{1})
+	p.Step(Message{From: 2, To: 1, Type: MessagePromise, Ballot: 1})
+
+	p.Step(Message{From: 2, To: 1, Type: MessageAccepted, Ballot: 1, Value: Value{2}})
+	require.Nil(t, p.Learned())
+
+	p.Step(Message{From: 3, To: 1, Type: MessageAccepted, Ballot: 1, Value: Value{1}})
+	require.Equal(t, Value{1}, p.Learned())
+}
+
+func TestWeightedPaxos(t *testing.T) {
+	// two strong replicas (1, 2) and three weak replicas (3, 4, 5)
+	weights := map[int]int{1: 2, 2: 2, 3: 1, 4: 1, 5: 1}
+	Run(t, Simulate(func(id int, nodes []int) (Node, error) {
+		return NewPaxos(id, nodes, WithWeights(weights))
+	}, WithValidation()), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2},
+				{3, 4, 5},
+			},
+			[][]int{
+				{1, 3, 4},
+				{2, 5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(6),
+	)
+}
 
-		for _, msg := range messages {
-			// messages that can't reach other node are delayed not dropped
-			if network.Reachable(msg.From, msg.To) {
-				cluster[msg.To].Next(msg)
+func TestValidateQuorums(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		weights map[int]int
+		r1, r2  int
+		err     bool
+	}{
+		{desc: "majority", r1: 2, r2: 2},
+		{desc: "flexible", r1: 3, r2: 1},
+		{desc: "no intersection", r1: 1, r2: 2, err: true},
+		{desc: "unreachable", r1: 4, r2: 2, err: true},
+		{desc: "weighted", weights: map[int]int{1: 2, 2: 1, 3: 1}, r1: 3, r2: 2},
+		{desc: "weighted no intersection", weights: map[int]int{1: 2, 2: 1, 3: 1}, r1: 2, r2: 2, err: true},
+		{desc: "negative weight", weights: map[int]int{1: -1, 2: 1, 3: 1}, r1: 1, r2: 1, err: true},
+	} {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			p := &Paxos{ID: 1, Nodes: []int{1, 2, 3}, R1Majority: tc.r1, R2Majority: tc.r2, Weights: tc.weights}
+			err := p.ValidateQuorums()
+			if tc.err {
+				require.Error(t, err)
 			} else {
-				delayed = append(delayed, msg)
+				require.NoError(t, err)
 			}
-		}
-		messages = delayed
-		delayed = delayed[:0]
-
-		var learned Value
-		for _, node := range cluster {
-			if node.LearnedValue != nil && learned == nil {
-				learned = node.LearnedValue
-			} else if node.LearnedValue != nil {
-				if bytes.Compare(learned, node.LearnedValue) != 0 {
-					return fmt.Errorf("%v != %v", learned, node.LearnedValue)
-				}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, p.Validate())
+
+	p.Propose(Value{1})
+	p.Step(Message{From: 2, To: 1, Type: MessagePromise, Ballot: 1})
+	require.NoError(t, p.Validate())
+
+	p.Step(Message{From: 2, To: 1, Type: MessageAccepted, Ballot: 1, Value: Value{1}})
+	require.NoError(t, p.Validate())
+
+	broken := p.Clone()
+	broken.votedBallot = broken.ballot + 1
+	require.Error(t, broken.Validate())
+
+	broken = p.Clone()
+	broken.votedValue = Value{2}
+	require.Error(t, broken.Validate())
+
+	broken = p.Clone()
+	broken.accepts[4] = struct{}{}
+	require.Error(t, broken.Validate())
+
+	broken = p.Clone()
+	broken.R2Majority = 1
+	require.Error(t, broken.Validate())
+
+	p.Propose(Value{1})
+	p.promises[2] = struct{}{}
+	require.Error(t, p.Validate())
+}
+
+func TestProposalOutcome(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, 0, p.Proposal())
+	require.Equal(t, OutcomeUnknown, p.Outcome(1))
+
+	p.Propose(Value{1})
+	first := p.Proposal()
+	require.Equal(t, OutcomePending, p.Outcome(first))
+
+	p.Propose(Value{2})
+	second := p.Proposal()
+	require.Equal(t, OutcomeSuperseded, p.Outcome(first))
+	require.Equal(t, OutcomePending, p.Outcome(second))
+
+	p.Step(Message{From: 2, To: 1, Type: MessagePromise, Ballot: 2})
+	p.Step(Message{From: 2, To: 1, Type: MessageAccepted, Ballot: 2, Value: Value{2}})
+	require.Equal(t, OutcomeRejected, p.Outcome(first))
+	require.Equal(t, OutcomeChosen, p.Outcome(second))
+}
+
+func TestNewPaxos(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3, 4})
+	require.NoError(t, err)
+	require.Equal(t, 3, p.R1Majority)
+	require.Equal(t, 3, p.R2Majority)
+
+	p, err = NewPaxos(1, []int{1, 2, 3, 4}, WithMajorities(4, 1))
+	require.NoError(t, err)
+	require.Equal(t, 4, p.R1Majority)
+	require.Equal(t, 1, p.R2Majority)
+
+	_, err = NewPaxos(1, []int{1, 2, 3, 4}, WithMajorities(2, 2))
+	require.Error(t, err)
+
+	_, err = NewPaxos(5, []int{1, 2, 3, 4})
+	require.Error(t, err)
+}
+
+func TestWitnessPaxos(t *testing.T) {
+	Run(t, Simulate(func(id int, nodes []int) (Node, error) {
+		return NewPaxos(id, nodes, WithWitnesses(5))
+	}, WithValidation()), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 5},
+				{3, 4},
+			},
+			[][]int{
+				{1, 2, 3},
+				{4, 5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(6),
+	)
+}
+
+func TestRejectJumpsBallot(t *testing.T) {
+	nodes := []int{1, 2, 3, 4, 5}
+	cluster, err := NewCluster(nodes, paxosFactory)
+	require.NoError(t, err)
+
+	isolated := Partition{}
+	healed := Partition{}
+	for i, from := range nodes {
+		for _, to := range nodes[i+1:] {
+			if from != 3 && to != 3 {
+				isolated.Add(from, to)
+			}
+			if !(from == 1 && to == 3) {
+				healed.Add(from, to)
 			}
 		}
 	}
+
+	// replica 3 bumps ballot in isolation and then its prepares reach everyone
+	// except replica 1.
+	for i := 0; i < 5; i++ {
+		cluster.Step(isolated, Actions{3: ActionLead})
+	}
+	cluster.Step(healed, Actions{})
+	cluster.Step(healed, Actions{})
+
+	// replica 1 must learn about higher ballot after the first rejection
+	// and get a value chosen with the second proposal.
+	proposals := 0
+	for i := 0; i < 10 && cluster.Node(1).Learned() == nil; i++ {
+		actions := Actions{}
+		if i%4 == 0 {
+			actions[1] = ActionLead
+			proposals++
+		}
+		cluster.Step(healed, actions)
+		require.NoError(t, cluster.Check())
+	}
+	require.NotNil(t, cluster.Node(1).Learned())
+	require.Equal(t, 2, proposals)
 }
 
-func TestPaxos(t *testing.T) {
-	Run(t, paxosRunner,
+func TestStatus(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, PhaseIdle, p.Status().Phase)
+
+	p.Propose(Value{1})
+	require.Equal(t, Status{ID: 1, Ballot: 1, Phase: PhasePrepare, Promises: 1}, p.Status())
+
+	p.Step(Message{From: 2, To: 1, Type: MessagePromise, Ballot: 1})
+	require.Equal(t, Status{
+		ID: 1, Ballot: 1, Phase: PhaseAccept, Promises: 2, Accepts: 1,
+		VotedBallot: 1, VotedValue: Value{1},
+	}, p.Status())
+
+	p.Step(Message{From: 2, To: 1, Type: MessageAccepted, Ballot: 1, Value: Value{1}})
+	require.Equal(t, Status{
+		ID: 1, Ballot: 1, Phase: PhaseIdle, Promises: 2, Accepts: 2,
+		VotedBallot: 1, VotedValue: Value{1}, LearnedValue: Value{1},
+	}, p.Status())
+}
+
+func TestCloneAndHash(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	p.Propose(Value{1})
+
+	clone := p.Clone()
+	require.Equal(t, p.Hash(), clone.Hash())
+	require.Equal(t, p.Status(), clone.Status())
+
+	p.Step(Message{From: 2, To: 1, Type: MessagePromise, Ballot: 1})
+	require.NotEqual(t, p.Hash(), clone.Hash())
+	require.Equal(t, 1, clone.Status().Promises)
+	require.Len(t, clone.Step(Message{}), 2)
+
+	empty := clone.Clone()
+	empty.LearnedValue = Value{}
+	require.NotEqual(t, clone.Hash(), empty.Hash())
+}
+
+func TestReadMessages(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	p.Propose(Value{1})
+	buf := p.ReadMessages(nil)
+	require.Len(t, buf, 2)
+	for _, msg := range buf {
+		require.Equal(t, MessagePrepare, msg.Type)
+	}
+	// outbox is cleared after read
+	require.Empty(t, p.ReadMessages(buf[:0]))
+
+	prefix := []Message{{From: 2}}
+	p.Propose(Value{2})
+	buf = p.ReadMessages(prefix)
+	require.Len(t, buf, 3)
+	require.Equal(t, prefix[0], buf[0])
+}
+
+func TestPersistedState(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	p.Step(Message{From: 2, To: 1, Type: MessageAccept, Ballot: 7, Value: Value{7}})
+
+	buf, err := p.MarshalBinary()
+	require.NoError(t, err)
+
+	restored, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, restored.UnmarshalBinary(buf))
+	require.Equal(t, p.Hash(), restored.Hash())
+
+	require.Error(t, restored.UnmarshalBinary(buf[:len(buf)-2]))
+}
+
+func TestMessageEncoding(t *testing.T) {
+	for _, msg := range []Message{
+		{},
+		{From: 1, To: 2, Type: MessagePrepare, Ballot: 3},
+		{From: 2, To: 1, Type: MessagePromise, Ballot: 3, VotedBallot: 2, Value: Value{1, 2}},
+		{From: 1, To: 2, Type: MessageAccept, Ballot: 3, Value: Value{}},
+		{From: 1, To: 2, Type: MessageLearned, Ballot: 3, Value: Value{1}, Slot: 4},
+	} {
+		buf, err := msg.Marshal()
+		require.NoError(t, err)
+		var decoded Message
+		require.NoError(t, decoded.Unmarshal(buf))
+		require.Equal(t, msg, decoded)
+		require.Error(t, decoded.Unmarshal(buf[:len(buf)-1]))
+	}
+}
+
+func TestLearnedValueImmutable(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3})
+	require.NoError(t, err)
+	p.Propose(Value{1})
+	p.Step(Message{From: 2, To: 1, Type: MessagePromise, Ballot: 1})
+	p.Step(Message{From: 2, To: 1, Type: MessageAccepted, Ballot: 1, Value: Value{1}})
+	require.Equal(t, Value{1}, p.Learned())
+	require.NoError(t, p.Err())
+
+	// forged promise makes proposer to select a different value
+	p.Propose(Value{2})
+	p.Step(Message{From: 3, To: 1, Type: MessagePromise, Ballot: 2, VotedBallot: 2, Value: Value{2}})
+	p.Step(Message{From: 3, To: 1, Type: MessageAccepted, Ballot: 2, Value: Value{2}})
+	require.Equal(t, Value{1}, p.Learned())
+	require.Error(t, p.Err())
+}
+
+func TestLearnedBroadcast(t *testing.T) {
+	nodes := []int{1, 2, 3, 4, 5}
+	cluster, err := NewCluster(nodes, func(id int, nodes []int) (Node, error) {
+		return NewPaxos(id, nodes, WithWitnesses(5))
+	})
+	require.NoError(t, err)
+	full := Partition{}
+	for i, from := range nodes {
+		for _, to := range nodes[i+1:] {
+			full.Add(from, to)
+		}
+	}
+	cluster.Step(full, Actions{1: ActionLead})
+	for i := 0; i < 4; i++ {
+		cluster.Step(full, Actions{})
+	}
+	require.NoError(t, cluster.Check())
+	for _, id := range nodes {
+		require.Equal(t, Value{1}, cluster.Node(id).Learned(), "replica %d", id)
+	}
+}
+
+func TestPaxosElection(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithOmega(2, 4)), runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3, 4, 5},
+			},
+			[][]int{
+				{1, 2},
+				{3, 4, 5},
+			},
+			[][]int{
+				{1},
+				{2, 3, 4},
+				{5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithElectedLeaders(),
+		WithSteps(8),
+	)
+}
+
+func TestBallotExhaustion(t *testing.T) {
+	p, err := NewPaxos(1, []int{1, 2, 3}, WithBallotWidth(2))
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		p.Propose(Value{1})
+		p.Step(Message{})
+	}
+	require.Equal(t, 3, p.Status().Ballot)
+	require.True(t, p.Status().Exhausted)
+
+	_, err = NewPaxos(1, []int{1, 2, 3}, WithBallotWidth(0))
+	require.Error(t, err)
+}
+
+func TestPaxosTinyBallots(t *testing.T) {
+	Run(t, Simulate(func(id int, nodes []int) (Node, error) {
+		return NewPaxos(id, nodes, WithBallotWidth(2))
+	}), runFlags(),
 		WithExplicitPartitions(
 			[][]int{
 				{1, 2, 3},
@@ -68,6 +427,305 @@ func TestPaxos(t *testing.T) {
 		),
 		WithReplicas(1, 2, 3, 4, 5),
 		WithLeaders(1, 3),
-		WithSteps(9),
+		WithSteps(6),
+	)
+}
+
+func TestPaxosLinkFailures(t *testing.T) {
+	Run(t, Simulate(paxosFactory), runFlags(),
+		WithReplicas(1, 2, 3),
+		WithLinkFailures(1),
+		WithLeaders(1, 2),
+		WithSteps(5),
+	)
+}
+
+func TestPaxosOneWayLinkFailures(t *testing.T) {
+	Run(t, Simulate(paxosFactory), runFlags(),
+		WithReplicas(1, 2, 3),
+		WithOneWayLinkFailures(1),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	)
+}
+
+func TestPaxosDrops(t *testing.T) {
+	Run(t, Simulate(paxosFactory), runFlags(),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1}, {2, 3}},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithDrops(1),
+		WithSteps(5),
+	)
+}
+
+func TestClusterDropsMessages(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	cluster, err := NewCluster(nodes, paxosFactory)
+	require.NoError(t, err)
+	isolated := Partition{}
+	isolated.Add(1, 2)
+	healed := Partition{}
+	healed.Add(1, 2)
+	healed.Add(1, 3)
+	healed.Add(2, 3)
+
+	// prepare to 3 is dropped, promise from 2 is delivered
+	cluster.Step(isolated, Actions{1: ActionLead, 3: ActionDrop})
+	require.Len(t, cluster.messages, 1)
+	require.Equal(t, 2, cluster.messages[0].From)
+
+	// accept to 3 is delayed and delivered once the network is healed
+	cluster.Step(isolated, Actions{})
+	cluster.Step(healed, Actions{})
+	require.Equal(t, Value{1}, cluster.Node(3).(*Paxos).votedValue)
+}
+
+func TestClusterSlowLinks(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	cluster, err := NewCluster(nodes, paxosFactory)
+	require.NoError(t, err)
+	slow := Partition{}
+	slow.Add(1, 2)
+	slow.Add(2, 3)
+	slow.AddOneWay(3, 1)
+	slow.AddDelayed(1, 3, 2)
+
+	// prepare to 3 arrives 2 steps later
+	cluster.Step(slow, Actions{1: ActionLead})
+	require.Zero(t, cluster.Node(3).(*Paxos).ballot)
+	cluster.Step(slow, Actions{})
+	require.Zero(t, cluster.Node(3).(*Paxos).ballot)
+	cluster.Step(slow, Actions{})
+	require.Equal(t, 1, cluster.Node(3).(*Paxos).ballot)
+}
+
+func TestPaxosSlowLinks(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1, 2}, {3}},
+		),
+		WithSlowLinks(1, 2),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	)
+}
+
+func TestClusterLossyLinks(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	cluster, err := NewCluster(nodes, paxosFactory)
+	require.NoError(t, err)
+	lossy := Partition{}
+	lossy.Add(1, 2)
+	lossy.AddLink(1, 3, Link{Loss: 100})
+
+	cluster.Step(lossy, Actions{1: ActionLead})
+	require.Equal(t, 1, cluster.Node(2).(*Paxos).ballot)
+	require.Zero(t, cluster.Node(3).(*Paxos).ballot)
+	// lost message is not delivered once the link is healthy
+	lossy.AddOneWay(1, 3)
+	cluster.Step(lossy, Actions{})
+	require.Zero(t, cluster.Node(3).(*Paxos).ballot)
+}
+
+func TestPaxosLossyLinks(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithLossyLinks(30),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	)
+}
+
+func TestPaxosDeliveryOrders(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1}, {2, 3}},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithDeliveryOrders(3),
+		WithSteps(4),
+	)
+}
+
+func TestPaxosSingleDelivery(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSingleDelivery(3),
+		WithSteps(6),
+	)
+}
+
+func TestClusterStepOne(t *testing.T) {
+	cluster, err := NewCluster([]int{1, 2, 3}, paxosFactory)
+	require.NoError(t, err)
+	network := Partition{}
+	network.Add(1, 2)
+	network.Add(1, 3)
+
+	// prepare to 3 is delivered, prepare to 2 is pending
+	cluster.StepOne(network, Actions{1: ActionLead}, 1)
+	require.Len(t, cluster.messages, 2)
+	require.Equal(t, 2, cluster.messages[0].To)
+	require.Equal(t, MessagePromise, cluster.messages[1].Type)
+
+	// out of range, nothing is delivered
+	cluster.StepOne(network, Actions{}, 2)
+	require.Len(t, cluster.messages, 2)
+}
+
+func TestPaxosCrashRecovery(t *testing.T) {
+	Run(t, Simulate(paxosFactory), runFlags(),
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithCrashes(1, 3),
+		WithCrashBudget(1),
+		WithSteps(5),
+	)
+}
+
+func TestClusterCrashRecovery(t *testing.T) {
+	cluster, err := NewCluster([]int{1, 2, 3}, paxosFactory)
+	require.NoError(t, err)
+	network := Partition{}
+	network.Add(1, 2)
+	network.Add(1, 3)
+	network.Add(2, 3)
+	isolated := Partition{}
+	isolated.Add(1, 3)
+
+	for _, actions := range []Actions{
+		{1: ActionLead},
+		{},
+		{},
+		// 1 learns value with a vote from 3
+		{},
+	} {
+		cluster.Step(isolated, actions)
+		require.NoError(t, cluster.Check())
+	}
+	require.Equal(t, Value{1}, cluster.Node(1).Learned())
+
+	cluster.Step(network, Actions{3: ActionCrash, 1: ActionCrash})
+	require.Empty(t, cluster.messages)
+	before := cluster.Node(3)
+	// 1 stays crashed, 3 must remember its ballot and vote after recovery
+	for _, actions := range []Actions{
+		{3: ActionRecover, 2: ActionLead},
+		// reject from 3
+		{},
+		{2: ActionLead},
+		{},
+		{},
+		{},
+	} {
+		cluster.Step(network, actions)
+		require.NoError(t, cluster.Check())
+	}
+	require.NotSame(t, before, cluster.Node(3))
+	require.Equal(t, Value{1}, cluster.Node(2).Learned())
+}
+
+func TestPaxosLeadersFirst(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+				{4, 5},
+			},
+			[][]int{
+				{1, 2},
+				{3, 4, 5},
+			},
+		),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithLeaders(1, 3),
+		WithSteps(8),
+	}
+	// leaders propose only in the first 4 steps
+	for step := 5; step <= 8; step++ {
+		opts = append(opts, WithStepActions(step, Actions{}))
+	}
+	Run(t, Simulate(paxosFactory), runFlags(), opts...)
+}
+
+func TestPaxosConcurrentLeaders(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1}, {2, 3}},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaderSets(2, 1, 2, 3),
+		WithSteps(4),
+	)
+}
+
+func TestPaxosProposedValues(t *testing.T) {
+	Run(t, Simulate(paxosFactory), runFlags(),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3}},
+			[][]int{{1}, {2, 3}},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaderSets(2, 1, 2),
+		WithProposedValues(2),
+		WithSteps(3),
+	)
+}
+
+// constantNode learns a value regardless of what was proposed.
+type constantNode Value
+
+func (constantNode) Propose(Value)          {}
+func (constantNode) Step(Message) []Message { return nil }
+func (n constantNode) Learned() Value       { return Value(n) }
+
+func TestLearnedValueWasProposed(t *testing.T) {
+	cluster, err := NewCluster([]int{1}, func(id int, nodes []int) (Node, error) {
+		return constantNode{9}, nil
+	})
+	require.NoError(t, err)
+	cluster.Step(Partition{}, Actions{1: ActionLead.WithValue(1)})
+	require.Error(t, cluster.Check())
+}
+
+func TestAssignedValueDiffersFromDefault(t *testing.T) {
+	cluster, err := NewCluster([]int{1, 2}, func(id int, nodes []int) (Node, error) {
+		// default value of replica 2
+		return constantNode{2}, nil
+	})
+	require.NoError(t, err)
+	cluster.Step(Partition{}, Actions{1: ActionLead.WithValue(2)})
+	require.Error(t, cluster.Check())
+}
+
+func TestPaxosShorterSchedules(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3),
+		WithSteps(4),
+		WithShorterSchedules(),
+	)
+}
+
+func BenchmarkPaxos(b *testing.B) {
+	RunBench(b, Simulate(paxosFactory, WithValidation()),
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(5),
 	)
 }
@@ -0,0 +1,135 @@
+package paxos
+
+import "errors"
+
+// WithPartialOrderReduction skips schedules of the single delivery mode that are
+// equivalent to other schedules. Consecutive steps that deliver messages to
+// different replicas, without actions and in the same partition, commute:
+// the order of such deliveries doesn't change the state of the cluster.
+// Only the order where the first message is delivered to the replica with
+// the lower id is explored. Steps without actions that don't deliver
+// anything are explored only with the lowest index that is out of range.
+// Runner reports non-canonical prefixes once it executes them, and
+// the exhaustive product skips every test case with such prefix.
+// Reduction is exact only if maxPending of WithSingleDelivery is not lower than
+// the number of pending messages. Replicas that depend on time or actions,
+// and failure detectors disable the reduction.
+func WithPartialOrderReduction() GenOption {
+	return func(g *Generator) error {
+		g.reduction = true
+		return nil
+	}
+}
+
+func (g *Generator) validateReduction() error {
+	if !g.reduction {
+		return nil
+	}
+	if g.single == 0 {
+		return errors.New("partial order reduction requires single delivery")
+	}
+	if g.order != nil {
+		return errors.New("partial order reduction requires lexicographic order of the exhaustive product")
+	}
+	return nil
+}
+
+// prune records a prefix of the test case that is equivalent to a prefix
+// that is explored. Safe to use from multiple goroutines.
+func (g *Generator) prune(prefix []int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.pruned == nil {
+		g.pruned = map[string]struct{}{}
+	}
+	g.pruned[prefixKey(prefix)] = struct{}{}
+}
+
+// prunedPrefix returns the length of the pruned prefix of states, or 0.
+func (g *Generator) prunedPrefix(states []int) int {
+	for i := 1; i <= len(states) && len(g.pruned) > 0; i++ {
+		if _, exist := g.pruned[prefixKey(states[:i])]; exist {
+			return i
+		}
+	}
+	return 0
+}
+
+// delivery is a message delivered by the step of the single delivery mode.
+type delivery struct {
+	msg Message
+	// index of the message among reachable messages
+	index     int
+	state     stepState
+	delivered bool
+}
+
+// reduce returns true if the last two steps of the test case delivered
+// commuting messages in the non-canonical order, or if the last step
+// didn't deliver anything with an index that is not the lowest out of range,
+// and prunes the prefix.
+func (c *Cluster) reduce(tc *TestCase, index int) bool {
+	previous := c.previous
+	c.previous = delivery{
+		msg:       c.delivered,
+		index:     index,
+		state:     tc.gen.statesAt(tc.step - 1)[tc.states[tc.step-1]],
+		delivered: c.isDelivered,
+	}
+	current := c.previous
+	if !equalActions(tc.gen.actions[current.state.actions], Actions{}) || !c.commutative() {
+		return false
+	}
+	if !current.delivered {
+		// steps that don't deliver anything are equivalent, only the lowest
+		// index that is out of range is explored
+		network := tc.gen.partitions[current.state.partition]
+		reachable := 0
+		for _, msg := range c.messages {
+			if network.Reachable(msg.From, msg.To) {
+				reachable++
+			}
+		}
+		if index > reachable {
+			tc.gen.prune(tc.states[:tc.step])
+			return true
+		}
+		return false
+	}
+	if !previous.delivered || previous.state.partition != current.state.partition ||
+		!equalActions(tc.gen.actions[previous.state.actions], Actions{}) {
+		return false
+	}
+	// second message may be a reply to the first
+	a, b := previous.msg, current.msg
+	if a.To <= b.To || b.From == a.To {
+		return false
+	}
+	// second message must be selectable by the index before the first one
+	// was delivered
+	before := current.index
+	if before >= previous.index {
+		before++
+	}
+	if before >= tc.gen.single {
+		return false
+	}
+	tc.gen.prune(tc.states[:tc.step])
+	return true
+}
+
+// commutative is true if deliveries to different replicas are independent.
+func (c *Cluster) commutative() bool {
+	if c.detectors != nil {
+		return false
+	}
+	for _, node := range c.nodes {
+		if _, ok := node.(ticker); ok {
+			return false
+		}
+		if _, ok := node.(actor); ok {
+			return false
+		}
+	}
+	return true
+}
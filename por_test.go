@@ -0,0 +1,69 @@
+package paxos
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialOrderReduction(t *testing.T) {
+	// leader proposes in the first step, later steps only deliver messages.
+	// number of pending messages never exceeds 4
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1),
+		WithSingleDelivery(4),
+		WithSteps(7),
+		WithStepActions(2, Actions{}),
+		WithStepActions(3, Actions{}),
+		WithStepActions(4, Actions{}),
+		WithStepActions(5, Actions{}),
+		WithStepActions(6, Actions{}),
+		WithStepActions(7, Actions{}),
+	}
+	// final states of the cluster after the test cases that weren't pruned
+	explore := func(opts ...GenOption) (map[string]struct{}, int) {
+		gen, err := NewGen(opts...)
+		require.NoError(t, err)
+		states := map[string]struct{}{}
+		for tc := range gen.All() {
+			cluster, err := NewCluster(tc.Nodes(), paxosFactory)
+			require.NoError(t, err)
+			for {
+				done, err := cluster.stepCase(tc)
+				require.NoError(t, err)
+				if done {
+					break
+				}
+			}
+			if tc.step == len(tc.states) {
+				// pending messages are compared without order
+				var messages []string
+				for _, msg := range cluster.messages {
+					messages = append(messages, string(appendMessage(nil, msg)))
+				}
+				sort.Strings(messages)
+				state := fmt.Sprint(messages)
+				for _, id := range cluster.ids {
+					state += string(cluster.nodes[id].(Fingerprinter).Fingerprint())
+				}
+				states[state] = struct{}{}
+			}
+		}
+		require.NoError(t, gen.Error())
+		return states, gen.Count()
+	}
+	all, total := explore(opts...)
+	reduced, count := explore(append(opts, WithPartialOrderReduction())...)
+	t.Logf("%d test cases, %d after reduction", total, count)
+	require.Less(t, count, total)
+	require.Equal(t, all, reduced)
+
+	_, err := NewGen(WithExplicitPartitions([][]int{{1, 2, 3}}), WithReplicas(1, 2, 3), WithLeaders(1), WithPartialOrderReduction())
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithPartialOrderReduction(), WithGrayOrder())...)
+	require.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package paxos
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress of the run that is reported periodically by Run.
+type Progress struct {
+	// Generated is a number of test cases received from the generator.
+	Generated int
+	// Executed is a number of test cases that were executed without errors.
+	Executed int
+	// Failed is a number of reported failures.
+	Failed int
+	// Total is an expected number of test cases, adjusted for random sample
+	// and shards. Nil if unknown, e.g. when test cases are replayed.
+	Total   *big.Int
+	Elapsed time.Duration
+}
+
+// Rate returns number of executed test cases per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Executed) / p.Elapsed.Seconds()
+}
+
+// Remaining returns estimated time until all test cases are executed.
+// Returns false if estimate is not available.
+func (p Progress) Remaining() (time.Duration, bool) {
+	rate := p.Rate()
+	if p.Total == nil || rate == 0 {
+		return 0, false
+	}
+	left := new(big.Float).SetInt(new(big.Int).Sub(p.Total, big.NewInt(int64(p.Executed))))
+	seconds, _ := left.Quo(left, big.NewFloat(rate)).Float64()
+	if seconds < 0 {
+		return 0, true
+	}
+	if seconds > float64(1<<63-1)/float64(time.Second) {
+		return 1<<63 - 1, true
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+func (p Progress) String() string {
+	total := "?"
+	if p.Total != nil {
+		total = p.Total.String()
+	}
+	rst := fmt.Sprintf("executed %d/%s test cases (generated %d) in %v, %.0f per second",
+		p.Executed, total, p.Generated, p.Elapsed.Round(time.Second), p.Rate())
+	if p.Failed > 0 {
+		rst += fmt.Sprintf(", failed %d", p.Failed)
+	}
+	if remaining, ok := p.Remaining(); ok {
+		rst += fmt.Sprintf(", remaining %v", remaining.Round(time.Second))
+	}
+	return rst
+}
+
+// statusLine displays the latest progress of the run. On a terminal the line
+// is refreshed in place, otherwise every update is written on a new line.
+type statusLine struct {
+	mu       sync.Mutex
+	w        io.Writer
+	name     string
+	terminal bool
+}
+
+func newStatusLine(f *os.File, name string) *statusLine {
+	info, err := f.Stat()
+	terminal := err == nil && info.Mode()&os.ModeCharDevice != 0
+	return &statusLine{w: f, name: name, terminal: terminal}
+}
+
+func (s *statusLine) update(p Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line := s.name + ": " + p.status()
+	if s.terminal {
+		// carriage return and erase the previous line
+		fmt.Fprintf(s.w, "\r\033[K%s", line)
+	} else {
+		fmt.Fprintln(s.w, line)
+	}
+}
+
+// close moves the cursor past the last status, so that it isn't overwritten.
+func (s *statusLine) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.terminal {
+		fmt.Fprintln(s.w)
+	}
+}
+
+// status is a short summary of the progress that fits a single line.
+func (p Progress) status() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d", p.Executed)
+	if p.Total != nil && p.Total.Sign() > 0 {
+		done, _ := new(big.Float).Quo(
+			new(big.Float).SetInt64(int64(p.Executed)*100),
+			new(big.Float).SetInt(p.Total),
+		).Float64()
+		fmt.Fprintf(&b, "/%s (%.1f%%)", p.Total, done)
+	}
+	fmt.Fprintf(&b, " cases, %.0f/s, %d failed, elapsed %v", p.Rate(), p.Failed, p.Elapsed.Round(time.Second))
+	if remaining, ok := p.Remaining(); ok {
+		fmt.Fprintf(&b, ", ETA %v", remaining.Round(time.Second))
+	}
+	return b.String()
+}
+
+// WithTimeBudget stops the Run after the budget, as with RunContext, and logs
+// how many test cases were executed. Run executes test cases in the order of
+// the product, or in the order of WithShuffledOrder with the same seed, so
+// that consecutive runs with the same budget explore the same schedules.
+func WithTimeBudget(budget time.Duration) GenOption {
+	return func(g *Generator) error {
+		if budget <= 0 {
+			return fmt.Errorf("time budget %v must be positive", budget)
+		}
+		g.timeBudget = budget
+		return nil
+	}
+}
+
+// expected returns number of test cases that generator is expected to generate.
+// Symmetry reduction is not accounted for.
+func (g *Generator) expected() *big.Int {
+	if g.coverage != nil {
+		return big.NewInt(int64(g.coverage.count))
+	}
+	if g.swarm != nil {
+		return big.NewInt(int64(g.swarm.count))
+	}
+	if g.capped != nil {
+		if total := g.Total(); total.Cmp(big.NewInt(int64(g.capped.max))) < 0 {
+			return total
+		}
+		return big.NewInt(int64(g.capped.max))
+	}
+	if g.exhaustive == nil {
+		return nil
+	}
+	total := g.Total()
+	if percent, _ := g.Sample(); percent < 100 {
+		total.Mul(total, big.NewInt(int64(percent)))
+		total.Quo(total, big.NewInt(100))
+	}
+	if g.shards > 1 {
+		total.Quo(total, big.NewInt(int64(g.shards)))
+	}
+	return total
+}
@@ -0,0 +1,73 @@
+package paxos
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressRemaining(t *testing.T) {
+	p := Progress{Executed: 100, Total: big.NewInt(1100), Elapsed: 10 * time.Second}
+	require.Equal(t, 10.0, p.Rate())
+	remaining, ok := p.Remaining()
+	require.True(t, ok)
+	require.Equal(t, 100*time.Second, remaining)
+
+	p.Total = nil
+	_, ok = p.Remaining()
+	require.False(t, ok)
+}
+
+func TestRunProgress(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reports []Progress
+	)
+	cfg := runFlags()
+	cfg.Progress = 10 * time.Millisecond
+	cfg.OnProgress = func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, p)
+	}
+	Run(t, func(*TestCase) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}, cfg,
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(3),
+	)
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, reports)
+	last := reports[len(reports)-1]
+	require.NotNil(t, last.Total)
+	require.Equal(t, last.Generated, last.Executed)
+}
+
+func TestStatusLine(t *testing.T) {
+	p := Progress{Executed: 250, Failed: 2, Total: big.NewInt(1000), Elapsed: 10 * time.Second}
+	require.Equal(t, "250/1000 (25.0%) cases, 25/s, 2 failed, elapsed 10s, ETA 30s", p.status())
+	p.Total = nil
+	require.Equal(t, "250 cases, 25/s, 2 failed, elapsed 10s", p.status())
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "status"))
+	require.NoError(t, err)
+	defer f.Close()
+	status := newStatusLine(f, "TestStatus")
+	require.False(t, status.terminal)
+	status.update(p)
+	status.update(p)
+	status.close()
+	buf, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	line := "TestStatus: 250 cases, 25/s, 2 failed, elapsed 10s\n"
+	require.Equal(t, line+line, string(buf))
+}
@@ -1,22 +1,33 @@
 package paxos
 
 import (
+	"hash/fnv"
 	"math/rand"
 )
 
+// randomIterator samples test cases from any other iterator.
 type randomIterator struct {
 	// any number between 1 and 100. where 100 means execute every test case
 	percent int
 	rng     *rand.Rand
-	iter    tcIterator
+	iter    Iterator
+}
+
+func newRandomIterator(iter Iterator, percent int, seed int64) *randomIterator {
+	return &randomIterator{
+		percent: percent,
+		iter:    iter,
+		rng:     rand.New(rand.NewSource(seed)),
+	}
 }
 
 func (r *randomIterator) Next() bool {
 	for {
 		next := r.iter.Next()
-		if !next || r.rng.Intn(99) <= r.percent-1 {
+		if !next || r.rng.Intn(100) < r.percent {
 			return next
 		}
+		r.iter.Current().Release()
 	}
 }
 
@@ -27,3 +38,33 @@ func (r *randomIterator) Error() error {
 func (r *randomIterator) Current() *TestCase {
 	return r.iter.Current()
 }
+
+// shardIterator skips test cases that belong to other shards.
+type shardIterator struct {
+	index, total int
+	iter         Iterator
+}
+
+func (s *shardIterator) Next() bool {
+	for s.iter.Next() {
+		if s.belongs(s.iter.Current()) {
+			return true
+		}
+		s.iter.Current().Release()
+	}
+	return false
+}
+
+func (s *shardIterator) belongs(tc *TestCase) bool {
+	h := fnv.New32a()
+	h.Write(appendStates(nil, tc.states))
+	return int(h.Sum32()%uint32(s.total)) == s.index
+}
+
+func (s *shardIterator) Error() error {
+	return s.iter.Error()
+}
+
+func (s *shardIterator) Current() *TestCase {
+	return s.iter.Current()
+}
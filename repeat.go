@@ -0,0 +1,68 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrNondeterministic is returned for a test case that produced different
+// results when it was executed repeatedly, see WithRepeat.
+var ErrNondeterministic = errors.New("test case is nondeterministic")
+
+// WithRepeat makes Run execute every test case n times and fail it if results
+// differ between repetitions, for example because the runner depends on the
+// order of map iteration or has a data race. Results are compared by the error,
+// and by the trace of the test case, see WithTracing. Nondeterministic runner
+// can't be replayed reliably.
+func WithRepeat(n int) GenOption {
+	return func(g *Generator) error {
+		if n <= 0 {
+			return fmt.Errorf("number of repetitions %d must be positive", n)
+		}
+		g.repeat = n
+		return nil
+	}
+}
+
+// withRepeat executes the test case n times, and returns the result
+// of the first execution if every repetition had the same result.
+func withRepeat(run Runner, n int) Runner {
+	return func(tc *TestCase) error {
+		err := run(tc)
+		trace := slices.Clone(tc.Trace())
+		for i := 1; i < n; i++ {
+			tc.Reset()
+			rerr := run(tc)
+			if describeResult(rerr) != describeResult(err) {
+				return fmt.Errorf("%w: repetition %d %s, the first %s",
+					ErrNondeterministic, i+1, describeResult(rerr), describeResult(err))
+			}
+			if line, ok := traceDiff(trace, tc.Trace()); !ok {
+				return fmt.Errorf("%w: trace of repetition %d differs at line %d",
+					ErrNondeterministic, i+1, line+1)
+			}
+		}
+		return err
+	}
+}
+
+func describeResult(err error) string {
+	if err == nil {
+		return "passed"
+	}
+	return fmt.Sprintf("failed with %q", err)
+}
+
+// traceDiff returns false and the index of the first line that differs.
+func traceDiff(expected, actual []string) (int, bool) {
+	for i := range min(len(expected), len(actual)) {
+		if expected[i] != actual[i] {
+			return i, false
+		}
+	}
+	if len(expected) != len(actual) {
+		return min(len(expected), len(actual)), false
+	}
+	return 0, true
+}
@@ -0,0 +1,50 @@
+package paxos
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRepeat(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(2),
+		WithRepeat(3),
+	}
+	var executions int64
+	Run(t, func(*TestCase) error {
+		atomic.AddInt64(&executions, 1)
+		return nil
+	}, RunConfig{Dir: t.TempDir()}, opts...)
+	require.EqualValues(t, 3*100, executions)
+
+	rec := &recorder{TB: t}
+	executions = 0
+	Run(rec, func(tc *TestCase) error {
+		// fails only on the second execution of the last test case
+		if tc.states[0] == 9 && tc.states[1] == 9 && atomic.AddInt64(&executions, 1) == 2 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, RunConfig{Dir: t.TempDir(), NoShrink: true}, opts...)
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], ErrNondeterministic.Error())
+	require.Contains(t, rec.errors[0], `repetition 2 failed with "flaky", the first passed`)
+}
+
+func TestTraceDiff(t *testing.T) {
+	line, ok := traceDiff([]string{"a", "b"}, []string{"a", "b"})
+	require.True(t, ok)
+	require.Zero(t, line)
+	line, ok = traceDiff([]string{"a", "b"}, []string{"a", "c"})
+	require.False(t, ok)
+	require.Equal(t, 1, line)
+	line, ok = traceDiff([]string{"a"}, []string{"a", "b"})
+	require.False(t, ok)
+	require.Equal(t, 1, line)
+}
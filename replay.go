@@ -2,24 +2,50 @@ package paxos
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"io"
 	"os"
+	"sort"
 	"sync"
 )
 
 var (
 	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errCorrupted = errors.New("file is corrupted")
 )
 
 const (
 	crcWidth    = 4
 	lengthWidth = 4
 	metaWidth   = 8
+
+	// maxRecordSize limits the size of the record, so that a corrupted length
+	// is detected before the buffer for the record is allocated.
+	maxRecordSize = 64 << 20
+)
+
+const (
+	// replayLegacy files have no header, and states of the test cases
+	// are encoded as int16.
+	replayLegacy = 1
+	// replayFingerprint files start with a header with a fingerprint of the
+	// generator configuration, and test cases are encoded with TestCase.Marshal.
+	replayFingerprint = 2
+
+	replayVersion = replayFingerprint
 )
 
+// replayMagic is the first record of the replay file, followed by the version
+// and the fingerprint. Length of the header is odd, and can't be confused
+// with a legacy test case.
+var replayMagic = []byte("paxos-replay")
+
 type flusher interface {
 	Flush() error
 }
@@ -32,6 +58,7 @@ func NewReplay(path string) (*Replay, error) {
 	wr := bufio.NewWriter(r.f)
 	r.writer = wr
 	r.flush = wr
+	r.version = replayVersion
 	return r, nil
 }
 
@@ -41,9 +68,39 @@ func NewReplayReader(path string) (*Replay, error) {
 		return nil, err
 	}
 	r.reader = bufio.NewReader(r.f)
+	if err := r.readHeader(); err != nil {
+		r.f.Close()
+		return nil, err
+	}
 	return r, nil
 }
 
+// readHeader reads the version of the replay file. Legacy files don't have
+// a header, in such case the first record is kept to be decoded as a test case.
+func (r *Replay) readHeader() error {
+	buf, err := readRecord(r.reader, &r.metaBuf)
+	if errors.Is(err, io.EOF) {
+		r.version = replayVersion
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(buf) <= len(replayMagic) || !bytes.Equal(buf[:len(replayMagic)], replayMagic) {
+		r.version = replayLegacy
+		r.first = buf
+		return nil
+	}
+	r.version = int(buf[len(replayMagic)])
+	if r.version != replayFingerprint {
+		return fmt.Errorf("unknown version %d of the replay file", r.version)
+	}
+	r.fingerprint = buf[len(replayMagic)+1:]
+	if len(r.fingerprint) != sha256.Size {
+		return errCorrupted
+	}
+	return nil
+}
+
 func openReplay(path string, flag int) (*Replay, error) {
 	f, err := os.OpenFile(path, flag, 0o644)
 	if err != nil {
@@ -57,6 +114,13 @@ type Replay struct {
 	f  *os.File
 
 	metaBuf [metaWidth]byte
+	version int
+	// first record of the legacy file, that was read instead of the header
+	first []byte
+	// fingerprint of the generator that recorded test cases. nil if unknown.
+	fingerprint []byte
+	// true if header was written
+	header bool
 
 	writer io.Writer
 	flush  flusher
@@ -72,23 +136,132 @@ func (r *Replay) Write(tc *TestCase) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if !r.header {
+		// header is written with the first test case, as the configuration
+		// of the generator is not known in advance
+		header := append(append([]byte{}, replayMagic...), replayVersion)
+		header = append(header, tc.gen.fingerprint()...)
+		if err := writeRecord(r.writer, &r.metaBuf, header); err != nil {
+			return err
+		}
+		r.header = true
+	}
 	buf, err := tc.Marshal()
 	if err != nil {
 		return err
 	}
+	return writeRecord(r.writer, &r.metaBuf, buf)
+}
+
+func (r *Replay) Read() (*TestCase, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := r.first
+	r.first = nil
+	if buf == nil {
+		var err error
+		buf, err = readRecord(r.reader, &r.metaBuf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tc TestCase
+	if r.version == replayLegacy {
+		if err := tc.unmarshalLegacy(buf); err != nil {
+			return nil, err
+		}
+		return &tc, nil
+	}
+	if err := tc.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return &tc, nil
+}
+
+// fingerprint returns a hash of the configuration that defines meaning of the
+// states in the test case: replicas, partitions, actions and possible states
+// of every step. Sampling, shards and symmetry reduction are not included.
+func (g *Generator) fingerprint() []byte {
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(g.stepLimit))
+	buf = binary.AppendUvarint(buf, uint64(g.single))
+	buf = binary.AppendUvarint(buf, uint64(g.crashBudget))
+	buf = binary.AppendUvarint(buf, uint64(len(g.nodes)))
+	for _, id := range g.nodes {
+		buf = binary.AppendVarint(buf, int64(id))
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(g.partitions)))
+	for _, network := range g.partitions {
+		type route struct {
+			from, to int
+			link     Link
+		}
+		var routes []route
+		for from, links := range network {
+			for to, link := range links {
+				routes = append(routes, route{from: from, to: to, link: link})
+			}
+		}
+		sort.Slice(routes, func(i, j int) bool {
+			if routes[i].from != routes[j].from {
+				return routes[i].from < routes[j].from
+			}
+			return routes[i].to < routes[j].to
+		})
+		buf = binary.AppendUvarint(buf, uint64(len(routes)))
+		for _, r := range routes {
+			buf = binary.AppendVarint(buf, int64(r.from))
+			buf = binary.AppendVarint(buf, int64(r.to))
+			buf = binary.AppendUvarint(buf, uint64(r.link.Delay))
+			buf = binary.AppendUvarint(buf, uint64(r.link.Loss))
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(g.actions)))
+	for _, actions := range g.actions {
+		ids := make([]int, 0, len(actions))
+		for id := range actions {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		buf = binary.AppendUvarint(buf, uint64(len(ids)))
+		for _, id := range ids {
+			buf = binary.AppendVarint(buf, int64(id))
+			buf = binary.AppendUvarint(buf, uint64(actions[id]))
+		}
+	}
+	for step := 0; step < g.stepLimit; step++ {
+		states := g.statesAt(step)
+		buf = binary.AppendUvarint(buf, uint64(len(states)))
+		for _, state := range states {
+			buf = binary.AppendUvarint(buf, uint64(state.actions))
+			buf = binary.AppendUvarint(buf, uint64(state.partition))
+			buf = binary.AppendUvarint(buf, uint64(state.order))
+		}
+	}
+	sum := sha256.Sum256(buf)
+	return sum[:]
+}
+
+// writeRecord writes buf prefixed with the length and crc of the buf.
+func writeRecord(w io.Writer, metaBuf *[metaWidth]byte, buf []byte) error {
+	if len(buf) > maxRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds the limit of %d bytes", len(buf), maxRecordSize)
+	}
 	code := crc32.Update(0, crcTable, buf)
 	lth := uint32(len(buf))
 
-	binary.LittleEndian.PutUint32(r.metaBuf[:lengthWidth], lth)
-	binary.LittleEndian.PutUint32(r.metaBuf[lengthWidth:metaWidth], code)
+	binary.LittleEndian.PutUint32(metaBuf[:lengthWidth], lth)
+	binary.LittleEndian.PutUint32(metaBuf[lengthWidth:metaWidth], code)
 
 	sum := 0
-	n, err := r.writer.Write(r.metaBuf[:])
+	n, err := w.Write(metaBuf[:])
 	if err != nil {
 		return err
 	}
 	sum += n
-	n, err = r.writer.Write(buf)
+	n, err = w.Write(buf)
 	if err != nil {
 		return err
 	}
@@ -100,34 +273,30 @@ func (r *Replay) Write(tc *TestCase) error {
 	return nil
 }
 
-func (r *Replay) Read() (*TestCase, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	_, err := io.ReadFull(r.reader, r.metaBuf[:])
+// readRecord reads a record that was written by writeRecord.
+func readRecord(r io.Reader, metaBuf *[metaWidth]byte) ([]byte, error) {
+	_, err := io.ReadFull(r, metaBuf[:])
 	if err != nil {
 		return nil, err
 	}
 
-	lth := binary.LittleEndian.Uint32(r.metaBuf[:lengthWidth])
-	code := binary.LittleEndian.Uint32(r.metaBuf[lengthWidth:metaWidth])
+	lth := binary.LittleEndian.Uint32(metaBuf[:lengthWidth])
+	code := binary.LittleEndian.Uint32(metaBuf[lengthWidth:metaWidth])
+	if lth > maxRecordSize {
+		return nil, errCorrupted
+	}
 
 	buf := make([]byte, lth)
-	_, err = io.ReadFull(r.reader, buf)
+	_, err = io.ReadFull(r, buf)
 	if err != nil {
 		return nil, err
 	}
 
 	rcode := crc32.Update(0, crcTable, buf)
 	if rcode != code {
-		return nil, errors.New("replay file is corrupted")
+		return nil, errCorrupted
 	}
-
-	var tc TestCase
-	if err := tc.Unmarshal(buf); err != nil {
-		return nil, err
-	}
-	return &tc, nil
+	return buf, nil
 }
 
 func (r *Replay) Close() error {
@@ -0,0 +1,164 @@
+package paxos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayLargeStates(t *testing.T) {
+	replicas := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	gen, err := NewGen(
+		WithReplicas(replicas...),
+		WithAllPartitions(0),
+		WithLeaders(replicas...),
+		WithSteps(2),
+	)
+	require.NoError(t, err)
+	// 4140 partitions of 8 replicas and 9 actions
+	require.Len(t, gen.states, 37260)
+
+	path := filepath.Join(t.TempDir(), "large.test")
+	replay, err := NewReplay(path)
+	require.NoError(t, err)
+	tc := &TestCase{gen: gen, states: []int{37259, 40000}}
+	require.NoError(t, replay.Write(tc))
+	require.NoError(t, replay.Close())
+
+	replay, err = NewReplayReader(path)
+	require.NoError(t, err)
+	defer replay.Close()
+	read, err := replay.Read()
+	require.NoError(t, err)
+	require.Equal(t, tc.states, read.states)
+}
+
+func TestReplayLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.test")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	var metaBuf [metaWidth]byte
+	expected := [][]int{{0, 1, 2}, {32767, 0, 5}}
+	for _, states := range expected {
+		var buf bytes.Buffer
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, int64(len(states))))
+		for _, state := range states {
+			require.NoError(t, binary.Write(&buf, binary.LittleEndian, int16(state)))
+		}
+		require.NoError(t, writeRecord(f, &metaBuf, buf.Bytes()))
+	}
+	require.NoError(t, f.Close())
+
+	replay, err := NewReplayReader(path)
+	require.NoError(t, err)
+	defer replay.Close()
+	for _, states := range expected {
+		tc, err := replay.Read()
+		require.NoError(t, err)
+		require.Equal(t, states, tc.states)
+	}
+	_, err = replay.Read()
+	require.Error(t, err)
+}
+
+func TestReplayUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.test")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	var metaBuf [metaWidth]byte
+	require.NoError(t, writeRecord(f, &metaBuf, append(append([]byte{}, replayMagic...), 99)))
+	require.NoError(t, f.Close())
+
+	_, err = NewReplayReader(path)
+	require.Error(t, err)
+}
+
+func TestReplayFingerprint(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 2),
+		WithSteps(3),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "fingerprint.test")
+	replay, err := NewReplay(path)
+	require.NoError(t, err)
+	expected := []*TestCase{gen.Next(), gen.Next(), gen.Next()}
+	for _, tc := range expected {
+		require.NoError(t, replay.Write(tc))
+	}
+	require.NoError(t, replay.Close())
+
+	replay, err = NewReplayReader(path)
+	require.NoError(t, err)
+	gen, err = NewGen(append(opts, WithReplay(replay))...)
+	require.NoError(t, err)
+	for _, tc := range expected {
+		replayed := gen.Next()
+		require.NotNil(t, replayed)
+		require.Equal(t, tc.states, replayed.states)
+	}
+	require.Nil(t, gen.Next())
+	require.NoError(t, gen.Error())
+	require.NoError(t, replay.Close())
+
+	replay, err = NewReplayReader(path)
+	require.NoError(t, err)
+	defer replay.Close()
+	_, err = NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(3),
+		WithReplay(replay),
+	)
+	require.Error(t, err)
+}
+
+func TestFingerprintLinks(t *testing.T) {
+	fingerprint := func(opts ...GenOption) []byte {
+		gen, err := NewGen(append([]GenOption{
+			WithExplicitPartitions([][]int{{1, 2, 3}}),
+			WithReplicas(1, 2, 3),
+			WithLeaders(1),
+		}, opts...)...)
+		require.NoError(t, err)
+		return gen.fingerprint()
+	}
+	require.NotEqual(t, fingerprint(WithLossyLinks(10)), fingerprint(WithLossyLinks(20)))
+	require.NotEqual(t, fingerprint(WithSlowLinks(1, 1)), fingerprint(WithSlowLinks(1, 2)))
+}
+
+func TestReplayOutOfRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.test")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	var (
+		metaBuf [metaWidth]byte
+		buf     bytes.Buffer
+	)
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, int64(2)))
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, []int16{0, 100}))
+	require.NoError(t, writeRecord(f, &metaBuf, buf.Bytes()))
+	require.NoError(t, f.Close())
+
+	replay, err := NewReplayReader(path)
+	require.NoError(t, err)
+	defer replay.Close()
+	gen, err := NewGen(
+		WithExplicitPartitions([][]int{{1, 2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1),
+		WithSteps(2),
+		WithReplay(replay),
+	)
+	require.NoError(t, err)
+	require.Nil(t, gen.Next())
+	require.Error(t, gen.Error())
+}
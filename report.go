@@ -0,0 +1,118 @@
+package paxos
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// Report is a machine readable summary of the Run, see RunConfig.ReportPath.
+type Report struct {
+	Name string `json:"name"`
+	// Fingerprint is a hash of replicas, partitions, actions and states of
+	// every step. Runs with the same fingerprint explore the same space.
+	Fingerprint string `json:"fingerprint"`
+
+	Total      *big.Int `json:"total"`
+	Considered int      `json:"considered"`
+	Skipped    int      `json:"skipped"`
+	Executed   int      `json:"executed"`
+	// Stopped is true if run was stopped before completion, because test
+	// cases failed or the context of RunContext is done.
+	Stopped bool `json:"stopped"`
+
+	Failures []ReportFailure `json:"failures,omitempty"`
+	Tags     []TagStats      `json:"tags,omitempty"`
+
+	// Duration of the run in seconds.
+	Duration float64 `json:"duration"`
+	// Rate is a number of executed test cases per second.
+	Rate float64 `json:"rate"`
+}
+
+// ReportFailure is a failed test case.
+type ReportFailure struct {
+	Error string `json:"error"`
+	// Schedule is a test case encoded with Marshal.
+	Schedule []byte `json:"schedule"`
+	// Steps are human readable steps of the test case.
+	Steps string `json:"steps"`
+}
+
+func newReport(name string, gen *Generator, elapsed time.Duration) *Report {
+	stats := gen.Stats()
+	report := &Report{
+		Name:        name,
+		Fingerprint: hex.EncodeToString(gen.fingerprint()),
+		Total:       stats.Total,
+		Considered:  stats.Considered,
+		Skipped:     stats.Skipped(),
+		Executed:    stats.Executed,
+		Tags:        stats.Tags,
+		Duration:    elapsed.Seconds(),
+	}
+	if elapsed > 0 {
+		report.Rate = float64(stats.Executed) / elapsed.Seconds()
+	}
+	return report
+}
+
+func (r *Report) fail(tc *TestCase, err error) error {
+	schedule, merr := tc.Marshal()
+	if merr != nil {
+		return merr
+	}
+	r.Failures = append(r.Failures, ReportFailure{
+		Error:    err.Error(),
+		Schedule: schedule,
+		Steps:    tc.String(),
+	})
+	return nil
+}
+
+// reports serializes appends to the report files of the tests that run in parallel.
+var reports sync.Mutex
+
+// append writes the report as a single json line to the end of the file,
+// so that the file keeps reports of every test and every run.
+func (r *Report) append(path string) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	reports.Lock()
+	defer reports.Unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadReports reads reports that were appended to the file by Run.
+func ReadReports(path string) ([]*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		rst []*Report
+		dec = json.NewDecoder(f)
+	)
+	for dec.More() {
+		var report Report
+		if err := dec.Decode(&report); err != nil {
+			return nil, err
+		}
+		rst = append(rst, &report)
+	}
+	return rst, nil
+}
@@ -0,0 +1,60 @@
+package paxos
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReport(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "report.json")
+		opts = []GenOption{
+			WithReplicas(1, 2, 3),
+			WithAllPartitions(0),
+			WithLeaders(1, 2),
+			WithSteps(2),
+			WithDefaultTags(),
+		}
+		cfg = RunConfig{Workers: 2, Dir: dir, ReportPath: path, MaxFailures: -1, NoShrink: true}
+	)
+	Run(t, func(*TestCase) error { return nil }, cfg, opts...)
+	rec := &recorder{TB: t}
+	Run(rec, func(tc *TestCase) error {
+		if tc.states[0] == 1 && tc.states[1] < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	}, cfg, opts...)
+	require.Len(t, rec.errors, 3)
+
+	reports, err := ReadReports(path)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	passed := reports[0]
+	require.Equal(t, t.Name(), passed.Name)
+	require.Len(t, passed.Fingerprint, 64)
+	require.EqualValues(t, 225, passed.Total.Int64())
+	require.Equal(t, 225, passed.Executed)
+	require.False(t, passed.Stopped)
+	require.Empty(t, passed.Failures)
+	require.Len(t, passed.Tags, 3)
+
+	failed := reports[1]
+	require.Equal(t, passed.Fingerprint, failed.Fingerprint)
+	require.Equal(t, 222, failed.Executed)
+	require.Len(t, failed.Failures, 3)
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	for _, failure := range failed.Failures {
+		require.Equal(t, "failed", failure.Error)
+		tc := &TestCase{gen: gen}
+		require.NoError(t, tc.Unmarshal(failure.Schedule))
+		require.Equal(t, 1, tc.states[0])
+		require.Equal(t, tc.String(), failure.Steps)
+	}
+}
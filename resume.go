@@ -0,0 +1,119 @@
+package paxos
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// resumer persists a checkpoint of the generator, such that every test case
+// that wasn't completed is generated again after restore.
+type resumer struct {
+	path     string
+	interval time.Duration
+	gen      *Generator
+
+	mu   sync.Mutex
+	seq  int
+	last time.Time
+	// checkpoint taken before the test case was generated
+	pending map[*TestCase]pendingCase
+}
+
+type pendingCase struct {
+	seq        int
+	checkpoint []byte
+}
+
+func newResumer(dir, name string, interval time.Duration, gen *Generator) *resumer {
+	return &resumer{
+		path:     filepath.Join(dir, filepath.Clean(name)+".checkpoint"),
+		interval: interval,
+		gen:      gen,
+		last:     time.Now(),
+		pending:  map[*TestCase]pendingCase{},
+	}
+}
+
+// restore continues generation from the persisted checkpoint if it exists.
+func (r *resumer) restore() (bool, error) {
+	checkpoint, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, r.gen.Restore(checkpoint)
+}
+
+// next generates a test case and remembers how to generate it again.
+func (r *resumer) next() (*TestCase, error) {
+	checkpoint, err := r.gen.Checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	tc := r.gen.Next()
+	if tc == nil {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	r.pending[tc] = pendingCase{seq: r.seq, checkpoint: checkpoint}
+	return tc, nil
+}
+
+// done marks test case as completed and persists the checkpoint of the
+// oldest pending test case if interval elapsed.
+func (r *resumer) done(tc *TestCase) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, tc)
+	if time.Since(r.last) < r.interval {
+		return nil
+	}
+	r.last = time.Now()
+	return r.save()
+}
+
+func (r *resumer) save() error {
+	var oldest *pendingCase
+	for _, pending := range r.pending {
+		pending := pending
+		if oldest == nil || pending.seq < oldest.seq {
+			oldest = &pending
+		}
+	}
+	var (
+		checkpoint []byte
+		err        error
+	)
+	if oldest != nil {
+		checkpoint = oldest.checkpoint
+	} else if checkpoint, err = r.gen.Checkpoint(); err != nil {
+		return err
+	}
+	// write to a temporary file first, so that the checkpoint is never torn
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, checkpoint, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// finish persists the checkpoint if the run was interrupted,
+// or removes it if all test cases were completed.
+func (r *resumer) finish(completed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if completed {
+		err := os.Remove(r.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return r.save()
+}
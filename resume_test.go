@@ -0,0 +1,47 @@
+package paxos
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumer(t *testing.T) {
+	opts := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2}}),
+		WithReplicas(1, 2),
+		WithLeaders(1),
+		WithSteps(3),
+	}
+	dir := t.TempDir()
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	resume := newResumer(dir, t.Name(), 0, gen)
+
+	var cases []*TestCase
+	for i := 0; i < 5; i++ {
+		tc, err := resume.next()
+		require.NoError(t, err)
+		cases = append(cases, tc)
+	}
+	for _, i := range []int{0, 1, 3} {
+		require.NoError(t, resume.done(cases[i]))
+	}
+	require.NoError(t, resume.finish(false))
+
+	gen, err = NewGen(opts...)
+	require.NoError(t, err)
+	resume = newResumer(dir, t.Name(), 0, gen)
+	restored, err := resume.restore()
+	require.NoError(t, err)
+	require.True(t, restored)
+	// the oldest test case that wasn't completed is generated again
+	tc, err := resume.next()
+	require.NoError(t, err)
+	require.Equal(t, cases[2].states, tc.states)
+
+	require.NoError(t, resume.finish(true))
+	_, err = os.Stat(resume.path)
+	require.True(t, os.IsNotExist(err))
+}
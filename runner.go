@@ -1,33 +1,194 @@
 package paxos
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
-
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
-var (
-	workers = flag.Int("workers", runtime.NumCPU(), "number of workers that will run test cases")
-	replay  = flag.String("replay", "", "replay test cases from the file")
-	dir     = flag.String("dir", "", "directory for replay files. current workdir by default")
-	percent = flag.Int("percent", 100, "percent of the test cases to execute")
-	seed    = flag.Int64("seed", time.Now().Unix(), "seed is used only if percent is less then 100. default is a current time in seconds.")
-)
+// batchSize is a number of test cases that are sent to a worker at once.
+const batchSize = 16
+
+// RunConfig configures execution of the test cases by Run. Zero value executes
+// every test case with a worker per CPU and writes replay files of the failed
+// test cases to the current working directory. See RegisterFlags to configure
+// it from command line flags.
+type RunConfig struct {
+	// Workers is a number of workers that run test cases. Number of CPUs if 0.
+	Workers int
+	// ReplayPath is a replay file with test cases that are executed instead of
+	// the generated test cases.
+	ReplayPath string
+	// Dir is a directory for artifacts of the failures: the replay file,
+	// and the error, the steps and the trace of every failed test case.
+	Dir string
+
+	// Percent of the test cases to execute, see WithRandomSample. All if 0.
+	Percent int
+	// MaxCases is a number of sampled test cases, see WithMaxCases. Disabled if 0.
+	MaxCases int
+	// Seed of Percent and MaxCases sampling.
+	Seed int64
+	// Shard out of Shards is executed, see WithShard.
+	Shard, Shards int
+	// Tags filter test cases, see WithTagFilter.
+	Tags []string
+
+	// Checkpoints is a directory for generator checkpoints. If set interrupted
+	// run continues from the checkpoint.
+	Checkpoints string
+	// CheckpointInterval is how often checkpoint is persisted. A minute if 0.
+	CheckpointInterval time.Duration
+	// Progress is how often progress is reported. Disabled if 0.
+	Progress time.Duration
+	// OnProgress receives progress every Progress interval, and the final
+	// progress when the run stops. Progress is logged if nil.
+	OnProgress func(Progress)
+	// Status is how often a status line with progress, failures and ETA is
+	// refreshed on stderr. Unlike logs of the test, it is visible while test
+	// is running. Disabled if 0.
+	Status time.Duration
+
+	// MaxFailures is a number of failed test cases after which run stops.
+	// Every failed test case is written to the replay file. Run stops after
+	// the first failure if 0, and continues until completion if negative.
+	MaxFailures int
+
+	// OnFailure is called for every failed test case, see FailureHandler.
+	OnFailure FailureHandler
+
+	// BeforeCase and AfterCase are called around every execution of the runner,
+	// including executions by Shrink and Neighborhood, for example to collect
+	// metrics or to detect leaks between test cases. See AfterCaseHook.
+	BeforeCase func(*TestCase)
+	AfterCase  AfterCaseHook
+
+	// Profile executes the first failed test case again with CPU and heap
+	// profiles, and with an execution trace if ExecutionTrace is set, and
+	// writes them to the directory of artifacts.
+	Profile, ExecutionTrace bool
+
+	// ReportPath is a file where json Report of the run is appended.
+	ReportPath string
+
+	// CaseTimeout fails a test case that doesn't finish in time, for example
+	// because runner loops forever. Disabled if 0.
+	CaseTimeout time.Duration
 
-func makePath(name string) string {
-	return filepath.Join(*dir, name)
+	// NoShrink disables minimization of the failed test case, see Shrink.
+	NoShrink bool
+	// Neighborhood executes mutations of the failed test case, see Neighborhood.
+	Neighborhood bool
+
+	// report receives the Report of the run, see Check.
+	report func(*Report)
+	// replayHint describes how to replay the file with failed test cases.
+	// go test command line if nil.
+	replayHint func(path string) string
 }
 
 type Runner func(*TestCase) error
 
-func Run(t testing.TB, run Runner, opts ...GenOption) {
+// FailureHandler is called for every failed test case before it is reported,
+// for example to capture custom artifacts or to report the failure to a bug
+// tracker. Returned error is reported instead of the original error. If it is
+// nil the failure is ignored and the test case is considered passed.
+// Handler is called by workers concurrently, and it is not called for test
+// cases that are executed by Shrink and Neighborhood.
+type FailureHandler func(tc *TestCase, err error) error
+
+// AfterCaseHook receives the result of the runner, and returned error
+// becomes the result of the test case. Return err to keep the result, or
+// a new error to fail the test case, e.g. if goroutines leaked. Hooks are
+// called by workers concurrently. AfterCase of the test case that timed out
+// is called only when its runner returns.
+type AfterCaseHook func(tc *TestCase, err error) error
+
+// ErrTimeout is returned for a test case that didn't finish within
+// RunConfig.CaseTimeout.
+var ErrTimeout = errors.New("test case timed out")
+
+// ErrPanic is returned for a test case that panicked in the runner.
+var ErrPanic = errors.New("runner panicked")
+
+// withRecover converts panics of the runner into errors with the stack,
+// so that the test case that caused the panic is reported.
+func withRecover(run Runner) Runner {
+	return func(tc *TestCase) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				// first line is an id of the goroutine, which differs between workers
+				stack := string(debug.Stack())
+				if i := strings.IndexByte(stack, '\n'); i >= 0 {
+					stack = stack[i+1:]
+				}
+				err = fmt.Errorf("%w: %v\n%s", ErrPanic, r, stack)
+			}
+		}()
+		return run(tc)
+	}
+}
+
+// withHooks calls before and after around the runner. Either can be nil.
+func withHooks(run Runner, before func(*TestCase), after AfterCaseHook) Runner {
+	return func(tc *TestCase) error {
+		if before != nil {
+			before(tc)
+		}
+		err := run(tc)
+		if after != nil {
+			err = after(tc, err)
+		}
+		return err
+	}
+}
+
+// withTimeout fails test cases that run longer than the timeout. Runner of
+// the test case that timed out can't be interrupted and is left running.
+// Runner executes a copy of the test case, so that the test case that timed
+// out can be shrunk and replayed while the runner is still using the copy.
+func withTimeout(run Runner, timeout time.Duration) Runner {
+	return func(tc *TestCase) error {
+		var (
+			clone = tc.clone()
+			done  = make(chan error, 1)
+		)
+		go func() {
+			done <- run(clone)
+		}()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case err := <-done:
+			tc.step, tc.crashed, tc.covered, tc.trace = clone.step, clone.crashed, clone.covered, clone.trace
+			return err
+		case <-timer.C:
+			return fmt.Errorf("%w after %v", ErrTimeout, timeout)
+		}
+	}
+}
+
+// Run executes test cases in parallel until all of them are executed or one
+// of them fails. Failed test cases are written to the replay file.
+func Run(t testing.TB, run Runner, cfg RunConfig, opts ...GenOption) {
+	RunContext(context.Background(), t, run, cfg, opts...)
+}
+
+// RunContext is Run that stops dispatching test cases once the context is done
+// or the deadline of the test approaches. Workers finish test cases that are
+// in progress, and the number of executed test cases is logged. Test doesn't
+// fail if the context is done, but fails if the deadline of the test (-timeout)
+// stopped the run before every test case was executed.
+func RunContext(ctx context.Context, t testing.TB, run Runner, cfg RunConfig, opts ...GenOption) {
 	type (
 		tcErr struct {
 			error
@@ -40,95 +201,531 @@ func Run(t testing.TB, run Runner, opts ...GenOption) {
 			mu       sync.Mutex
 			existing bool
 			replay   *Replay
+			failures int
 		}
 
-		path = makePath(fmt.Sprintf("%s-%d.test", t.Name(), time.Now().UnixNano()))
+		// directory with the replay file and artifacts of every failure.
+		// names of subtests are separated by slashes
+		artifacts = filepath.Join(cfg.Dir, fmt.Sprintf("%s-%d", strings.ReplaceAll(t.Name(), "/", "-"), time.Now().UnixNano()))
+		path      = filepath.Join(artifacts, "replay.test")
 
-		workers = *workers
-		queue   = make(chan *TestCase, workers)
+		workers = cfg.Workers
+		queue   chan []*TestCase
 
-		errc = make(chan *tcErr, workers)
+		errc chan *tcErr
 		wg   sync.WaitGroup
+
+		executed    int64
+		failedCount int64
+		start       = time.Now()
 	)
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if deadline, ok := testDeadline(t); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadlineCause(ctx, deadline, errTestDeadline)
+		defer cancel()
+	}
+	queue = make(chan []*TestCase, workers)
+	errc = make(chan *tcErr, workers)
 
-	if len(*replay) > 0 {
-		rpl, err := NewReplayReader(*replay)
-		require.NoError(t, err)
+	if len(cfg.ReplayPath) > 0 {
+		rpl, err := NewReplayReader(cfg.ReplayPath)
+		if err != nil {
+			t.Fatal(err)
+		}
 		opts = append(opts, WithReplay(rpl))
-		path = *replay
+		path = cfg.ReplayPath
 		r.existing = true
 		r.replay = rpl
 	}
-	opts = append(opts, WithRNG(*percent, *seed))
+	if cfg.Percent > 0 && cfg.Percent < 100 {
+		opts = append(opts, WithRandomSample(cfg.Percent, cfg.Seed))
+	}
+	if cfg.MaxCases > 0 && !r.existing {
+		opts = append(opts, WithMaxCases(cfg.MaxCases, cfg.Seed))
+	}
+	if cfg.Shards > 1 {
+		opts = append(opts, WithShard(cfg.Shard, cfg.Shards))
+	}
+	if len(cfg.Tags) > 0 {
+		opts = append(opts, WithTagFilter(cfg.Tags...))
+	}
 
 	gen, err := NewGen(opts...)
-	require.NoError(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen.repeat > 1 {
+		run = withRepeat(run, gen.repeat)
+	}
+	run = withRecover(run)
+	if cfg.BeforeCase != nil || cfg.AfterCase != nil {
+		// hooks receive panics of the runner as errors, and panics
+		// of the hooks are recovered as well
+		run = withRecover(withHooks(run, cfg.BeforeCase, cfg.AfterCase))
+	}
+	if cfg.CaseTimeout > 0 {
+		run = withTimeout(run, cfg.CaseTimeout)
+	}
+	if gen.timeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, gen.timeBudget)
+		defer cancel()
+	}
+	if !r.existing {
+		t.Logf("Total number of test cases %s", gen.Total())
+	}
+	if percent, seed := gen.Sample(); percent < 100 {
+		t.Logf("Sampling %d%% of the test cases with seed %d", percent, seed)
+	}
 
-	onError := func(tcerr *tcErr) {
-		if !assert.NoError(t, tcerr, tcerr.tc.String()) {
-			if r.existing {
-				return
+	next := func() []*TestCase {
+		return gen.NextBatch(batchSize)
+	}
+	var resume *resumer
+	if len(cfg.Checkpoints) > 0 && !r.existing {
+		interval := cfg.CheckpointInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		resume = newResumer(cfg.Checkpoints, t.Name(), interval, gen)
+		restored, err := resume.restore()
+		if err != nil {
+			t.Fatalf("can't restore a checkpoint: %v", err)
+		}
+		if restored {
+			t.Logf("Continue from a checkpoint %s", resume.path)
+		}
+		next = func() []*TestCase {
+			// every test case is checkpointed separately
+			var batch []*TestCase
+			for len(batch) < batchSize {
+				tc, err := resume.next()
+				if err != nil {
+					t.Fatalf("can't checkpoint a generator: %v", err)
+				}
+				if tc == nil {
+					break
+				}
+				batch = append(batch, tc)
 			}
+			return batch
+		}
+	}
+
+	stopProgress := reportProgress(t, gen, cfg, &executed, &failedCount)
+	defer stopProgress()
+
+	onError := func(tcerr *tcErr) {
+		gen.count(tcerr.tc, true)
+		t.Errorf("%v\n%s", tcerr.error, tcerr.tc)
+		if r.existing {
+			return
+		}
 
-			r.mu.Lock()
-			defer r.mu.Unlock()
-			if r.replay == nil {
-				replay, err := NewReplay(path)
-				require.NoError(t, err, "can't create a replay file")
-				r.replay = replay
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.replay == nil {
+			if err := os.MkdirAll(artifacts, 0o755); err != nil {
+				t.Fatalf("can't create a directory for artifacts: %v", err)
+			}
+			replay, err := NewReplay(path)
+			if err != nil {
+				t.Fatalf("can't create a replay file: %v", err)
+			}
+			r.replay = replay
+		}
+		r.failures++
+		dir := filepath.Join(artifacts, fmt.Sprintf("failure-%d", r.failures))
+		if err := writeFailure(dir, tcerr.tc, tcerr.error); err != nil {
+			t.Fatalf("can't write artifacts of the failure: %v", err)
+		}
+		// every mutation of the test case that hangs is likely to hang as well
+		hang := errors.Is(tcerr.error, ErrTimeout)
+		if cfg.Profile && r.failures == 1 && !hang {
+			if err := profile(artifacts, run, tcerr.tc, cfg.ExecutionTrace); err != nil {
+				t.Logf("can't profile the failed test case: %v", err)
+			}
+		}
+		if !cfg.NoShrink && !hang {
+			minimized, merr := Shrink(run, tcerr.tc)
+			if minimized != tcerr.tc {
+				t.Logf("Minimized test case fails with: %v\n%s", merr, minimized)
+				if err := r.replay.Write(minimized); err != nil {
+					t.Fatalf("can't write to a replay file: %v", err)
+				}
+				if err := writeFailure(filepath.Join(dir, "minimized"), minimized, merr); err != nil {
+					t.Fatalf("can't write artifacts of the failure: %v", err)
+				}
+			}
+		}
+		if err := r.replay.Write(tcerr.tc); err != nil {
+			t.Fatalf("can't write to a replay file: %v", err)
+		}
+		if cfg.Neighborhood && !hang {
+			failed, passed := Neighborhood(run, tcerr.tc)
+			t.Logf("%d of %d mutations of the failed test case fail", len(failed), len(failed)+len(passed))
+			for _, mutation := range failed {
+				if err := r.replay.Write(mutation); err != nil {
+					t.Fatalf("can't write to a replay file: %v", err)
+				}
 			}
-			require.NoError(t, r.replay.Write(tcerr.tc), "can't write to a replay file")
 		}
 	}
 
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for tc := range queue {
+	var (
+		failed    []*tcErr
+		exhausted bool
+		stopped   bool
+	)
+	subtests, ok := t.(interface {
+		Run(string, func(*testing.T)) bool
+	})
+	if ok && r.existing {
+		// every replayed test case is executed in a subtest, so that a single
+		// test case can be selected with -run='TestName/case-N$'
+		i := 0
+		for tc := range gen.All() {
+			if ctx.Err() != nil {
+				stopped = true
+				break
+			}
+			i++
+			subtests.Run(fmt.Sprintf("case-%d", i), func(t *testing.T) {
 				err := run(tc)
+				if err != nil && cfg.OnFailure != nil {
+					err = cfg.OnFailure(tc, err)
+				}
 				if err != nil {
-					// make sure to send at most one error from each worker
-					// otherwise there will be a deadlock
-					errc <- &tcErr{error: err, tc: tc}
+					t.Errorf("%v\n%s", err, tc)
+					gen.count(tc, true)
+					failed = append(failed, &tcErr{error: err, tc: tc})
+					atomic.AddInt64(&failedCount, 1)
 					return
 				}
+				gen.Feedback(tc)
+				atomic.AddInt64(&executed, 1)
+			})
+		}
+		exhausted = !stopped
+	} else {
+		limit := int64(cfg.MaxFailures)
+		if limit == 0 {
+			limit = 1
+		}
+		ctx, stop := context.WithCancel(ctx)
+		defer stop()
+		var failures int64
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for batch := range queue {
+					for _, tc := range batch {
+						if ctx.Err() != nil {
+							return
+						}
+						err := run(tc)
+						if err != nil && cfg.OnFailure != nil {
+							err = cfg.OnFailure(tc, err)
+						}
+						if err != nil {
+							errc <- &tcErr{error: err, tc: tc}
+							if limit > 0 && atomic.AddInt64(&failures, 1) >= limit {
+								stop()
+							}
+							continue
+						}
+						gen.Feedback(tc)
+						atomic.AddInt64(&executed, 1)
+						if resume != nil {
+							if err := resume.done(tc); err != nil {
+								t.Errorf("can't persist a checkpoint: %v", err)
+							}
+						}
+						tc.Release()
+					}
+				}
+			}()
+		}
+
+		report := func(tcerr *tcErr) {
+			// failures of the workers that were in progress when limit was reached
+			// are ignored
+			if limit > 0 && int64(len(failed)) >= limit {
+				return
 			}
+			failed = append(failed, tcerr)
+			atomic.AddInt64(&failedCount, 1)
+			onError(tcerr)
+		}
+		var batch []*TestCase
+		for !stopped {
+			if ctx.Err() != nil {
+				stopped = true
+				break
+			}
+			if batch == nil {
+				batch = next()
+				if len(batch) == 0 {
+					exhausted = true
+					break
+				}
+			}
+			select {
+			case queue <- batch:
+				batch = nil
+			case tcerr := <-errc:
+				report(tcerr)
+			case <-ctx.Done():
+				stopped = true
+			}
+		}
+
+		close(queue)
+		go func() {
+			wg.Wait()
+			close(errc)
 		}()
+		for tcerr := range errc {
+			report(tcerr)
+		}
 	}
 
+	if len(failed) > 1 {
+		// failures are distinct if their errors are different
+		var (
+			counts   = map[string]int{}
+			distinct []string
+		)
+		for _, tcerr := range failed {
+			msg := tcerr.Error()
+			if counts[msg] == 0 {
+				distinct = append(distinct, msg)
+			}
+			counts[msg]++
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d test cases failed with %d distinct errors:", len(failed), len(distinct))
+		for _, msg := range distinct {
+			fmt.Fprintf(&b, "\n%d: %s", counts[msg], msg)
+		}
+		t.Log(b.String())
+	}
+
+	if err := gen.Error(); err != nil {
+		t.Fatalf("internal generator error: %v", err)
+	}
+	if stopped && len(failed) == 0 {
+		msg := fmt.Sprintf("Run stopped (%v) after %d test cases", context.Cause(ctx), atomic.LoadInt64(&executed))
+		if total := gen.expected(); total != nil {
+			msg = fmt.Sprintf("Run stopped (%v) after %d out of %s test cases", context.Cause(ctx), atomic.LoadInt64(&executed), total)
+		}
+		if errors.Is(context.Cause(ctx), errTestDeadline) {
+			t.Errorf("%s. Increase -timeout, or execute fewer test cases with -percent or -max-cases", msg)
+		} else {
+			t.Log(msg)
+		}
+	}
+	t.Logf("Generator stats:\n%s", gen.Stats())
+	if len(cfg.ReportPath) > 0 || cfg.report != nil {
+		report := newReport(t.Name(), gen, time.Since(start))
+		report.Stopped = !exhausted
+		for _, tcerr := range failed {
+			if err := report.fail(tcerr.tc, tcerr.error); err != nil {
+				t.Fatalf("can't encode a failed test case: %v", err)
+			}
+		}
+		if len(cfg.ReportPath) > 0 {
+			if err := report.append(cfg.ReportPath); err != nil {
+				t.Fatalf("can't write a report: %v", err)
+			}
+		}
+		if cfg.report != nil {
+			cfg.report(report)
+		}
+	}
+	if resume != nil {
+		if err := resume.finish(exhausted && len(failed) == 0); err != nil {
+			t.Fatalf("can't persist a checkpoint: %v", err)
+		}
+	}
+	if len(failed) > 0 {
+		if err := r.replay.Close(); err != nil {
+			t.Fatalf("can't close a replay file: %v", err)
+		}
+		if !r.existing {
+			t.Logf("Artifacts of the failure are in %s", artifacts)
+		}
+		if cfg.replayHint != nil {
+			t.Log(cfg.replayHint(r.replay.Name()))
+		} else {
+			t.Logf("Replay a failed test with: go test -run=%s -replay=%s",
+				t.Name(), r.replay.Name(),
+			)
+		}
+	}
+}
+
+// errTestDeadline is the cause of the run that was stopped by testDeadline.
+var errTestDeadline = errors.New("deadline of the test is near")
+
+// testDeadline returns the time when dispatching of test cases stops, so that
+// the run finishes before the deadline of the test, which is set with -timeout.
+// A tenth of the remaining time is left to drain workers and write replay files.
+func testDeadline(t testing.TB) (time.Time, bool) {
+	dt, ok := t.(interface{ Deadline() (time.Time, bool) })
+	if !ok {
+		return time.Time{}, false
+	}
+	deadline, ok := dt.Deadline()
+	if !ok {
+		return time.Time{}, false
+	}
+	return deadline.Add(-time.Until(deadline) / 10), true
+}
+
+// reportProgress periodically reports progress of the run, if it was requested
+// with RunConfig. Returned function stops reporting
+// and reports the final progress.
+func reportProgress(t testing.TB, gen *Generator, cfg RunConfig, executed, failed *int64) func() {
+	type reporter struct {
+		interval time.Duration
+		report   func(Progress)
+	}
+	var reporters []reporter
+	if cfg.Progress > 0 {
+		report := cfg.OnProgress
+		if report == nil {
+			report = func(p Progress) {
+				t.Logf("Progress: %s", p)
+			}
+		}
+		reporters = append(reporters, reporter{cfg.Progress, report})
+	}
+	var status *statusLine
+	if cfg.Status > 0 {
+		status = newStatusLine(os.Stderr, t.Name())
+		reporters = append(reporters, reporter{cfg.Status, status.update})
+	}
+	if len(reporters) == 0 {
+		return func() {}
+	}
 	var (
-		tcerr  *tcErr
-		failed bool
+		start    = time.Now()
+		total    = gen.expected()
+		stop     = make(chan struct{})
+		wg       sync.WaitGroup
+		snapshot = func() Progress {
+			return Progress{
+				Generated: gen.Count(),
+				Executed:  int(atomic.LoadInt64(executed)),
+				Failed:    int(atomic.LoadInt64(failed)),
+				Total:     total,
+				Elapsed:   time.Since(start),
+			}
+		}
 	)
-	for tc := gen.Next(); tc != nil && tcerr == nil; tc = gen.Next() {
-		select {
-		case queue <- tc:
-		case tcerr = <-errc:
+	for _, r := range reporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(r.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					r.report(snapshot())
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	return func() {
+		close(stop)
+		wg.Wait()
+		p := snapshot()
+		for _, r := range reporters {
+			r.report(p)
 		}
-		if tcerr != nil {
-			failed = true
-			onError(tcerr)
+		if status != nil {
+			status.close()
 		}
 	}
+}
 
-	close(queue)
-	wg.Wait()
-	close(errc)
-	if !failed {
-		for tcerr = range errc {
-			if tcerr != nil && !failed {
-				failed = true
-				onError(tcerr)
+// RunExpectFailure executes test cases one by one until runner returns an error.
+// Test fails if none of the test cases failed. Useful to demonstrate that
+// generator is capable to find a known bug, for example with byzantine replicas,
+// or with an intentionally broken model. Panic of the runner is a failure too.
+func RunExpectFailure(t testing.TB, run Runner, opts ...GenOption) {
+	RunExpectFailureMatching(t, run, nil, opts...)
+}
+
+// RunExpectFailureMatching is RunExpectFailure that expects a specific class
+// of the failure, for example a violation of the invariant that is broken
+// by the mutation of the model. Test fails immediately if the test case fails
+// with an error that doesn't match, because such mutation proves nothing
+// about the coverage of the generator. Any error matches if match is nil.
+func RunExpectFailureMatching(t testing.TB, run Runner, match func(error) bool, opts ...GenOption) {
+	gen, err := NewGen(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run = withRecover(run)
+	for tc := range gen.All() {
+		if err := run(tc); err != nil {
+			if match != nil && !match(err) {
+				t.Fatalf("unexpected failure after %d test cases: %v\n%s", gen.Count(), err, tc)
 			}
+			t.Logf("expected failure found after %d test cases: %v\n%s", gen.Count(), err, tc)
+			return
 		}
+		gen.Feedback(tc)
+		tc.Release()
+	}
+	if err := gen.Error(); err != nil {
+		t.Fatalf("internal generator error: %v", err)
 	}
+	t.Errorf("none of %d test cases failed", gen.Count())
+}
 
-	require.NoError(t, gen.Error(), "internal generator error")
-	if failed {
-		require.NoError(t, r.replay.Close(), "can't close a replay file")
-		t.Logf("Replay a failed test with: go test -run=%s -replay=%s",
-			t.Name(), r.replay.Name(),
-		)
+// RunBench executes b.N test cases one by one, so that ns/op and allocs/op
+// measure generation and execution of a single test case. Rate is reported
+// as cases/s. Generator is created again once it is exhausted, which is not
+// measured. Benchmark fails if a test case fails.
+func RunBench(b *testing.B, run Runner, opts ...GenOption) {
+	newGen := func() *Generator {
+		b.StopTimer()
+		defer b.StartTimer()
+		gen, err := NewGen(opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return gen
+	}
+	gen := newGen()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tc := gen.Next()
+		if tc == nil {
+			if err := gen.Error(); err != nil {
+				b.Fatalf("internal generator error: %v", err)
+			}
+			gen = newGen()
+			if tc = gen.Next(); tc == nil {
+				b.Fatal("generator is empty")
+			}
+		}
+		if err := run(tc); err != nil {
+			b.Fatalf("%v\n%s", err, tc)
+		}
+		gen.Feedback(tc)
+		tc.Release()
 	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "cases/s")
 }
@@ -0,0 +1,472 @@
+package paxos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		started int64
+		last    Progress
+	)
+	RunContext(ctx, t, func(*TestCase) error {
+		if atomic.AddInt64(&started, 1) == 20 {
+			cancel()
+		}
+		return nil
+	}, RunConfig{Workers: 2, Progress: time.Minute, OnProgress: func(p Progress) {
+		last = p
+	}},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	)
+	require.False(t, t.Failed())
+	// workers finish test cases in progress and don't start new ones
+	require.LessOrEqual(t, last.Executed, 21)
+	require.Less(t, int64(last.Executed), last.Total.Int64())
+}
+
+// recorder records failures of Run, without failing the test.
+type recorder struct {
+	testing.TB
+	mu     sync.Mutex
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.errors) > 0
+}
+
+// deadlineT is a test with the deadline, as set by -timeout.
+type deadlineT struct {
+	*recorder
+	deadline time.Time
+}
+
+func (d deadlineT) Deadline() (time.Time, bool) {
+	return d.deadline, true
+}
+
+func TestRunTestDeadline(t *testing.T) {
+	rec := &recorder{TB: t}
+	Run(deadlineT{rec, time.Now().Add(200 * time.Millisecond)}, func(*TestCase) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}, RunConfig{Workers: 2},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	)
+	require.True(t, rec.Failed())
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "-timeout")
+}
+
+func TestRunCaseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	dir := t.TempDir()
+	rec := &recorder{TB: t}
+	hung := []int{2, 1}
+	Run(rec, func(tc *TestCase) error {
+		if tc.states[0] == hung[0] && tc.states[1] == hung[1] {
+			<-release
+			// test case is still used after it timed out
+			tc.Tracef("released")
+		}
+		return nil
+	}, RunConfig{Workers: 2, Dir: dir, CaseTimeout: 50 * time.Millisecond},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	)
+	require.True(t, rec.Failed())
+	require.Contains(t, rec.errors[0], ErrTimeout.Error())
+
+	// test case that hangs is written to the replay file without shrinking
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "replay.test"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	replay, err := NewReplayReader(paths[0])
+	require.NoError(t, err)
+	defer replay.Close()
+	tc, err := replay.Read()
+	require.NoError(t, err)
+	require.Equal(t, hung, tc.states)
+	_, err = replay.Read()
+	require.Error(t, err)
+}
+
+func TestRunMaxFailures(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		maxFailures int
+		expected    int
+	}{
+		{desc: "first", maxFailures: 0, expected: 1},
+		{desc: "limit", maxFailures: 3, expected: 3},
+		{desc: "completion", maxFailures: -1, expected: 15},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			rec := &recorder{TB: t}
+			Run(rec, func(tc *TestCase) error {
+				if tc.states[0] == 1 {
+					return fmt.Errorf("failed in state %d", tc.states[1]%2)
+				}
+				return nil
+			}, RunConfig{Workers: 4, Dir: dir, MaxFailures: tc.maxFailures, NoShrink: true},
+				WithReplicas(1, 2, 3),
+				WithAllPartitions(0),
+				WithLeaders(1, 2),
+				WithSteps(2),
+			)
+			require.Len(t, rec.errors, tc.expected, "%v", rec.errors)
+
+			paths, err := filepath.Glob(filepath.Join(dir, "*", "replay.test"))
+			require.NoError(t, err)
+			require.Len(t, paths, 1)
+			replay, err := NewReplayReader(paths[0])
+			require.NoError(t, err)
+			defer replay.Close()
+			for i := 0; i < tc.expected; i++ {
+				failed, err := replay.Read()
+				require.NoError(t, err)
+				require.Equal(t, 1, failed.states[0])
+			}
+			_, err = replay.Read()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	dir := t.TempDir()
+	rec := &recorder{TB: t}
+	Run(rec, func(tc *TestCase) error {
+		if tc.states[0] == 1 && tc.states[1] == 1 {
+			panic("unexpected state")
+		}
+		return nil
+	}, RunConfig{Workers: 2, Dir: dir},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	)
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], ErrPanic.Error())
+	require.Contains(t, rec.errors[0], "unexpected state")
+	require.Contains(t, rec.errors[0], "runner_test.go")
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "replay.test"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	replay, err := NewReplayReader(paths[0])
+	require.NoError(t, err)
+	defer replay.Close()
+	var last *TestCase
+	for {
+		tc, err := replay.Read()
+		if err != nil {
+			break
+		}
+		last = tc
+	}
+	require.NotNil(t, last)
+	require.Equal(t, []int{1, 1}, last.states)
+}
+
+// subtests records names of the subtests.
+type subtests struct {
+	*testing.T
+	names []string
+}
+
+func (s *subtests) Run(name string, f func(*testing.T)) bool {
+	s.names = append(s.names, name)
+	return s.T.Run(name, f)
+}
+
+func TestRunReplaySubtests(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "replay.test")
+	replay, err := NewReplay(path)
+	require.NoError(t, err)
+	expected := [][]int{{1, 2}, {3, 4}, {0, 14}}
+	for _, states := range expected {
+		tc, err := gen.NewTestCase(states)
+		require.NoError(t, err)
+		require.NoError(t, replay.Write(tc))
+	}
+	require.NoError(t, replay.Close())
+
+	st := &subtests{T: t}
+	var executed [][]int
+	Run(st, func(tc *TestCase) error {
+		executed = append(executed, append([]int(nil), tc.states...))
+		return nil
+	}, RunConfig{ReplayPath: path}, opts...)
+	require.Equal(t, []string{"case-1", "case-2", "case-3"}, st.names)
+	require.Equal(t, expected, executed)
+}
+
+func TestRunTimeBudget(t *testing.T) {
+	var last Progress
+	start := time.Now()
+	Run(t, func(*TestCase) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}, RunConfig{Workers: 2, Progress: time.Minute, OnProgress: func(p Progress) {
+		last = p
+	}},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(4),
+		WithTimeBudget(50*time.Millisecond),
+	)
+	require.Less(t, time.Since(start), time.Second)
+	require.Positive(t, last.Executed)
+	require.Less(t, int64(last.Executed), last.Total.Int64())
+
+	_, err := NewGen(WithTimeBudget(0))
+	require.Error(t, err)
+}
+
+func TestRunArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	rec := &recorder{TB: t}
+	Run(rec, Simulate(brokenQuorumFactory, WithTracing()), RunConfig{Workers: 1, Dir: dir},
+		WithReplicas(1, 2, 3, 4, 5),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3, 4, 5}},
+			[][]int{{1, 2}, {3, 4, 5}},
+		),
+		WithLeaders(1, 3),
+		WithSteps(8),
+	)
+	require.True(t, rec.Failed())
+
+	failures, err := filepath.Glob(filepath.Join(dir, "*", "failure-*"))
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	for _, failure := range []string{failures[0], filepath.Join(failures[0], "minimized")} {
+		for _, name := range []string{"error.txt", "schedule.txt", "trace.txt"} {
+			buf, err := os.ReadFile(filepath.Join(failure, name))
+			require.NoError(t, err)
+			require.NotEmpty(t, buf)
+		}
+	}
+	trace, err := os.ReadFile(filepath.Join(failures[0], "trace.txt"))
+	require.NoError(t, err)
+	require.Regexp(t, `^step \d+: Msg\[From=`, string(trace))
+	_, err = os.Stat(filepath.Join(filepath.Dir(failures[0]), "replay.test"))
+	require.NoError(t, err)
+}
+
+func TestRunOnFailure(t *testing.T) {
+	var handled int64
+	rec := &recorder{TB: t}
+	Run(rec, func(tc *TestCase) error {
+		if tc.states[0] == 1 {
+			return fmt.Errorf("failed in state %d", tc.states[1])
+		}
+		return nil
+	}, RunConfig{
+		Workers:     4,
+		Dir:         t.TempDir(),
+		MaxFailures: -1,
+		OnFailure: func(tc *TestCase, err error) error {
+			atomic.AddInt64(&handled, 1)
+			// failures in even states are known and ignored
+			if tc.states[1]%2 == 0 {
+				return nil
+			}
+			return fmt.Errorf("tracked: %w", err)
+		},
+	},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	)
+	require.EqualValues(t, 15, handled)
+	require.Len(t, rec.errors, 7)
+	for _, msg := range rec.errors {
+		require.Contains(t, msg, "tracked: failed in state")
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	var before, after, panics int64
+	rec := &recorder{TB: t}
+	Run(rec, func(tc *TestCase) error {
+		if tc.states[0] == 1 && tc.states[1] == 1 {
+			panic("unexpected state")
+		}
+		return nil
+	}, RunConfig{
+		Workers:     4,
+		Dir:         t.TempDir(),
+		MaxFailures: -1,
+		NoShrink:    true,
+		BeforeCase: func(*TestCase) {
+			atomic.AddInt64(&before, 1)
+		},
+		AfterCase: func(tc *TestCase, err error) error {
+			atomic.AddInt64(&after, 1)
+			if errors.Is(err, ErrPanic) {
+				atomic.AddInt64(&panics, 1)
+				return nil
+			}
+			if tc.states[0] == 2 && tc.states[1] == 2 {
+				return errors.New("leaked goroutines")
+			}
+			return err
+		},
+	},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	)
+	require.EqualValues(t, 15*15, before)
+	require.EqualValues(t, before, after)
+	require.EqualValues(t, 1, panics)
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "leaked goroutines")
+}
+
+// fatalRecorder records fatal failures, without stopping the test.
+type fatalRecorder struct {
+	*recorder
+}
+
+func (r fatalRecorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func TestRunExpectFailureMatching(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1),
+		WithSteps(2),
+	}
+	violation := errors.New("violation")
+	run := func(tc *TestCase) error {
+		switch {
+		case tc.states[0] == 1:
+			return errors.New("setup failed")
+		case tc.states[0] == 2:
+			return fmt.Errorf("mutant: %w", violation)
+		}
+		return nil
+	}
+	isViolation := func(err error) bool {
+		return errors.Is(err, violation)
+	}
+
+	rec := fatalRecorder{&recorder{TB: t}}
+	RunExpectFailureMatching(rec, run, isViolation, opts...)
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "unexpected failure")
+	require.Contains(t, rec.errors[0], "setup failed")
+
+	rec = fatalRecorder{&recorder{TB: t}}
+	RunExpectFailureMatching(rec, func(tc *TestCase) error {
+		if tc.states[0] == 1 {
+			return nil
+		}
+		return run(tc)
+	}, isViolation, opts...)
+	require.Empty(t, rec.errors)
+
+	rec = fatalRecorder{&recorder{TB: t}}
+	RunExpectFailure(rec, func(tc *TestCase) error {
+		if tc.states[1] == 2 {
+			panic("broken model")
+		}
+		return nil
+	}, opts...)
+	require.Empty(t, rec.errors)
+
+	rec = fatalRecorder{&recorder{TB: t}}
+	RunExpectFailure(rec, func(*TestCase) error { return nil }, opts...)
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "none of 100 test cases failed")
+}
+
+// TestStdlibRunner checks that the package can be used without testify.
+func TestStdlibRunner(t *testing.T) {
+	paths, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		require.NoError(t, err)
+		for _, spec := range f.Imports {
+			require.NotContains(t, spec.Path.Value, "github.com/stretchr/testify", path)
+		}
+	}
+}
+
+func TestRunProfile(t *testing.T) {
+	dir := t.TempDir()
+	rec := &recorder{TB: t}
+	Run(rec, func(tc *TestCase) error {
+		if tc.states[0] == 1 {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}, RunConfig{Workers: 1, Dir: dir, Profile: true, ExecutionTrace: true, NoShrink: true},
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(2),
+	)
+	require.True(t, rec.Failed())
+	for _, name := range []string{"cpu.pprof", "heap.pprof", "execution.trace"} {
+		paths, err := filepath.Glob(filepath.Join(dir, "*", name))
+		require.NoError(t, err)
+		require.Len(t, paths, 1, name)
+	}
+}
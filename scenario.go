@@ -0,0 +1,191 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Scenario is a hand-written sequence of steps. It is useful to encode
+// known interleavings as regression tests.
+//
+//	s := NewScenario(1, 2, 3)
+//	s.Step().Partition([]int{1, 2}, []int{3}).Leader(1)
+//	s.Step().Leader(3).Crash(2)
+type Scenario struct {
+	nodes []int
+	steps []*ScenarioStep
+}
+
+// NewScenario creates a scenario for a cluster of replicas.
+func NewScenario(replicas ...int) *Scenario {
+	return &Scenario{nodes: replicas}
+}
+
+// Step appends a step where every replica can reach every other replica,
+// and nothing happens until it is configured otherwise.
+func (s *Scenario) Step() *ScenarioStep {
+	step := &ScenarioStep{scenario: s, actions: Actions{}}
+	s.steps = append(s.steps, step)
+	return step
+}
+
+// ScenarioStep is a state of the network and actions in a single step of the scenario.
+type ScenarioStep struct {
+	scenario *Scenario
+
+	network Partition
+	actions Actions
+
+	order    int
+	delivery int
+	single   bool
+}
+
+// Step appends the next step to the scenario.
+func (s *ScenarioStep) Step() *ScenarioStep {
+	return s.scenario.Step()
+}
+
+// Partition splits replicas into groups, replicas from different groups
+// can't reach each other.
+func (s *ScenarioStep) Partition(groups ...[]int) *ScenarioStep {
+	s.network = groupPartition(groups)
+	return s
+}
+
+// Network uses a custom network state, e.g. with one way links.
+func (s *ScenarioStep) Network(network Partition) *ScenarioStep {
+	s.network = network
+	return s
+}
+
+// Leader makes replicas propose their default values.
+func (s *ScenarioStep) Leader(replicas ...int) *ScenarioStep {
+	return s.add(ActionLead, replicas)
+}
+
+// Propose makes replica propose value. See WithProposedValues.
+func (s *ScenarioStep) Propose(replica, value int) *ScenarioStep {
+	s.actions[replica] = (s.actions[replica] | ActionLead).WithValue(value)
+	return s
+}
+
+// Byzantine makes replicas equivocate in the step. See WithByzantine.
+func (s *ScenarioStep) Byzantine(replicas ...int) *ScenarioStep {
+	return s.add(ActionByzantine, replicas)
+}
+
+// Drop drops messages to the replicas that can't be delivered in the step,
+// instead of delaying them. See WithDrops.
+func (s *ScenarioStep) Drop(replicas ...int) *ScenarioStep {
+	return s.add(ActionDrop, replicas)
+}
+
+// Crash crashes replicas, they lose state that wasn't persisted. See WithCrashes.
+func (s *ScenarioStep) Crash(replicas ...int) *ScenarioStep {
+	return s.add(ActionCrash, replicas)
+}
+
+// Recover restarts crashed replicas from the persisted state. See WithCrashes.
+func (s *ScenarioStep) Recover(replicas ...int) *ScenarioStep {
+	return s.add(ActionRecover, replicas)
+}
+
+// Ticks advances clocks of the replicas ticks times in the step. See WithTicks.
+func (s *ScenarioStep) Ticks(ticks int, replicas ...int) *ScenarioStep {
+	for _, id := range replicas {
+		s.actions[id] = s.actions[id].WithTicks(ticks)
+	}
+	return s
+}
+
+// Order delivers messages in the order. See WithDeliveryOrders.
+func (s *ScenarioStep) Order(order int) *ScenarioStep {
+	s.order = order
+	return s
+}
+
+// Deliver delivers a single message with index. See WithSingleDelivery.
+// Either all or none of the steps in the scenario must deliver a single message.
+func (s *ScenarioStep) Deliver(index int) *ScenarioStep {
+	s.single = true
+	s.delivery = index
+	return s
+}
+
+func (s *ScenarioStep) add(action Action, replicas []int) *ScenarioStep {
+	for _, id := range replicas {
+		s.actions[id] |= action
+	}
+	return s
+}
+
+// TestCase returns a test case that executes steps of the scenario.
+func (s *Scenario) TestCase() (*TestCase, error) {
+	if len(s.steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+	gen := &Generator{
+		nodes:      s.nodes,
+		stepLimit:  len(s.steps),
+		orders:     1,
+		stepStates: make(map[int][]stepState, len(s.steps)),
+	}
+	single := s.steps[0].single
+	for i, step := range s.steps {
+		if step.single != single {
+			return nil, fmt.Errorf("step %d: single delivery must be used in every step", i+1)
+		}
+		if single && step.order != 0 {
+			return nil, fmt.Errorf("step %d: order can't be used with single delivery", i+1)
+		}
+		for id := range step.actions {
+			if !contains(s.nodes, id) {
+				return nil, fmt.Errorf("step %d: replica %d is not in the cluster", i+1, id)
+			}
+		}
+		network := step.network
+		if network == nil {
+			network = groupPartition([][]int{s.nodes})
+		}
+		state := stepState{actions: i, partition: i, order: step.order}
+		if single {
+			state.order = step.delivery
+			if step.delivery >= gen.single {
+				gen.single = step.delivery + 1
+			}
+		} else if step.order >= gen.orders {
+			gen.orders = step.order + 1
+		}
+		gen.partitions = append(gen.partitions, network)
+		gen.actions = append(gen.actions, step.actions)
+		gen.stepStates[i] = []stepState{state}
+	}
+	if err := gen.validateReplicas(); err != nil {
+		return nil, err
+	}
+	return &TestCase{gen: gen, states: make([]int, len(s.steps))}, nil
+}
+
+func contains(ids []int, id int) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RunScenarios executes every scenario with run and fails the test on the first error.
+func RunScenarios(t testing.TB, run Runner, scenarios ...*Scenario) {
+	for i, scenario := range scenarios {
+		tc, err := scenario.TestCase()
+		if err != nil {
+			t.Fatalf("scenario %d: %v", i, err)
+		}
+		if err := run(tc); err != nil {
+			t.Fatalf("scenario %d: %v\n%s", i, err, tc)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenarioSteps(t *testing.T) {
+	s := NewScenario(1, 2, 3)
+	s.Step().Partition([]int{1, 2}, []int{3}).Leader(1)
+	s.Step().Propose(3, 2).Crash(2).Order(1)
+	s.Step().Recover(2, 3)
+
+	tc, err := s.TestCase()
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, tc.Nodes())
+
+	network, actions := tc.Next()
+	require.True(t, network.Reachable(1, 2))
+	require.False(t, network.Reachable(1, 3))
+	require.Equal(t, Actions{1: ActionLead}, actions)
+	require.Equal(t, 0, tc.Order())
+
+	network, actions = tc.Next()
+	require.True(t, network.Reachable(1, 3))
+	require.Equal(t, Actions{2: ActionCrash, 3: ActionLead.WithValue(2)}, actions)
+	require.Equal(t, 1, tc.Order())
+
+	// 3 is not crashed and can't recover
+	_, actions = tc.Next()
+	require.True(t, actions.IsRecovered(2))
+	require.False(t, actions.IsRecovered(3))
+
+	network, actions = tc.Next()
+	require.Nil(t, network)
+	require.Nil(t, actions)
+}
+
+func TestScenarioSingleDelivery(t *testing.T) {
+	s := NewScenario(1, 2, 3)
+	s.Step().Leader(1).Deliver(0)
+	s.Step().Deliver(2)
+	tc, err := s.TestCase()
+	require.NoError(t, err)
+	for _, expected := range []int{0, 2} {
+		tc.Next()
+		index, ok := tc.Delivery()
+		require.True(t, ok)
+		require.Equal(t, expected, index)
+	}
+
+	s.Step()
+	_, err = s.TestCase()
+	require.Error(t, err)
+}
+
+func TestScenarioInvalid(t *testing.T) {
+	_, err := NewScenario(1, 2, 3).TestCase()
+	require.Error(t, err)
+
+	s := NewScenario(1, 2, 3)
+	s.Step().Leader(4)
+	_, err = s.TestCase()
+	require.Error(t, err)
+	s = NewScenario(1, 2, 3)
+	s.Step().Partition([]int{1, 5}, []int{2, 3})
+	_, err = s.TestCase()
+	require.Error(t, err)
+}
+
+func TestPaxosScenarios(t *testing.T) {
+	// 3 must remember its ballot and vote after recovery, otherwise
+	// 2 can choose its own value
+	recovery := NewScenario(1, 2, 3)
+	recovery.Step().Partition([]int{1, 3}, []int{2}).Leader(1).
+		Step().Partition([]int{1, 3}, []int{2}).
+		Step().Partition([]int{1, 3}, []int{2}).
+		Step().Partition([]int{1, 3}, []int{2}).
+		Step().Crash(1, 3).
+		Step().Recover(3).Leader(2).
+		Step().
+		Step().Leader(2).
+		Step().
+		Step().
+		Step()
+
+	competing := NewScenario(1, 2, 3, 4, 5)
+	competing.Step().Partition([]int{1, 2, 3}, []int{4, 5}).Leader(1).
+		Step().Partition([]int{1, 2}, []int{3, 4, 5}).Leader(3).
+		Step().Partition([]int{1, 2, 3}, []int{4, 5}).
+		Step().Partition([]int{1, 2}, []int{3, 4, 5}).
+		Step().Order(1).
+		Step().Order(1)
+
+	RunScenarios(t, Simulate(paxosFactory, WithValidation()), recovery, competing)
+}
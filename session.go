@@ -0,0 +1,150 @@
+package paxos
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const requestHeaderWidth = 16
+
+// Request is a client command with a unique (Client, Seq) identifier.
+type Request struct {
+	Client, Seq uint64
+	Payload     []byte
+}
+
+func (r Request) String() string {
+	return fmt.Sprintf("Request[Client=%d Seq=%d Payload=0x%x]", r.Client, r.Seq, r.Payload)
+}
+
+// Value encodes request as a value that can be proposed.
+func (r Request) Value() Value {
+	v := make(Value, requestHeaderWidth+len(r.Payload))
+	binary.LittleEndian.PutUint64(v, r.Client)
+	binary.LittleEndian.PutUint64(v[8:], r.Seq)
+	copy(v[requestHeaderWidth:], r.Payload)
+	return v
+}
+
+// DecodeRequest decodes a value that was encoded with Request.Value.
+func DecodeRequest(v Value) (Request, error) {
+	if len(v) < requestHeaderWidth {
+		return Request{}, fmt.Errorf("value %v is not a request", v)
+	}
+	return Request{
+		Client:  binary.LittleEndian.Uint64(v),
+		Seq:     binary.LittleEndian.Uint64(v[8:]),
+		Payload: v[requestHeaderWidth:],
+	}, nil
+}
+
+// NewClient creates a client that sends requests to the replicas.
+func NewClient(id uint64, replicas []int) *Client {
+	return &Client{ID: id, replicas: replicas}
+}
+
+// Client submits one request at a time and retries it, possibly through
+// different replicas, until it is acknowledged. Retried request keeps the
+// same sequence number, so that duplicates can be detected by the Session.
+type Client struct {
+	ID uint64
+
+	replicas []int
+	target   int
+
+	seq     uint64
+	pending *Request
+}
+
+// Submit assigns the next sequence number to the payload.
+// Previous request is abandoned if it wasn't acknowledged.
+func (c *Client) Submit(payload []byte) Request {
+	c.seq++
+	r := Request{Client: c.ID, Seq: c.seq, Payload: payload}
+	c.pending = &r
+	return r
+}
+
+// Pending returns a request that wasn't acknowledged yet.
+func (c *Client) Pending() (Request, bool) {
+	if c.pending == nil {
+		return Request{}, false
+	}
+	return *c.pending, true
+}
+
+// Replica returns replica that should receive the pending request.
+func (c *Client) Replica() int {
+	return c.replicas[c.target]
+}
+
+// Retry switches to the next replica and returns it.
+func (c *Client) Retry() int {
+	c.target = (c.target + 1) % len(c.replicas)
+	return c.Replica()
+}
+
+// Ack completes pending request if it matches r.
+func (c *Client) Ack(r Request) {
+	if c.pending != nil && c.pending.Client == r.Client && c.pending.Seq == r.Seq {
+		c.pending = nil
+	}
+}
+
+// NewSession creates empty session table.
+func NewSession() *Session {
+	return &Session{applied: map[uint64]map[uint64]struct{}{}}
+}
+
+// Session applies chosen values in the log order and discards Noop
+// and requests that were already applied.
+// Retried request may be chosen in a lower slot than a request that was
+// submitted after it, therefore every applied sequence number is tracked.
+type Session struct {
+	// applied sequence numbers for every client
+	applied map[uint64]map[uint64]struct{}
+	// next slot to apply
+	next int
+}
+
+// Apply returns a request and true if request needs to be executed.
+// Duplicates and Noops return false.
+func (s *Session) Apply(v Value) (Request, bool, error) {
+	if IsNoop(v) {
+		return Request{}, false, nil
+	}
+	r, err := DecodeRequest(v)
+	if err != nil {
+		return Request{}, false, err
+	}
+	seqs, exist := s.applied[r.Client]
+	if !exist {
+		seqs = map[uint64]struct{}{}
+		s.applied[r.Client] = seqs
+	}
+	if _, exist := seqs[r.Seq]; exist {
+		return r, false, nil
+	}
+	seqs[r.Seq] = struct{}{}
+	return r, true, nil
+}
+
+// ApplyLog applies chosen values from the log without gaps, starting
+// after the last applied slot, and returns requests that need to be executed.
+func (s *Session) ApplyLog(log LogNode) ([]Request, error) {
+	var rst []Request
+	for ; s.next < log.Len(); s.next++ {
+		value := log.Chosen(s.next)
+		if value == nil {
+			break
+		}
+		r, apply, err := s.Apply(value)
+		if err != nil {
+			return nil, fmt.Errorf("slot %d: %w", s.next, err)
+		}
+		if apply {
+			rst = append(rst, r)
+		}
+	}
+	return rst, nil
+}
@@ -0,0 +1,144 @@
+package paxos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sessionRunner(tc *TestCase) error {
+	nodes := tc.Nodes()
+	client := NewClient(1, nodes)
+	cluster, err := NewCluster(nodes, func(id int, nodes []int) (Node, error) {
+		return NewLog(id, nodes)
+	}, WithValues(func(leader int) Value {
+		r, exist := client.Pending()
+		if !exist {
+			r = client.Submit([]byte{byte(leader)})
+		}
+		return r.Value()
+	}))
+	if err != nil {
+		return err
+	}
+	sessions := map[int]*Session{}
+	applied := map[int][]Request{}
+	for _, id := range nodes {
+		sessions[id] = NewSession()
+	}
+	for {
+		network, actions := tc.Next()
+		if network == nil || actions == nil {
+			return nil
+		}
+		cluster.Step(network, actions)
+		if err := cluster.Check(); err != nil {
+			return err
+		}
+		for _, id := range nodes {
+			requests, err := sessions[id].ApplyLog(cluster.Node(id).(*Log))
+			if err != nil {
+				return err
+			}
+			for _, r := range requests {
+				for _, prev := range applied[id] {
+					if prev.Client == r.Client && prev.Seq == r.Seq {
+						return fmt.Errorf("replica %d applied %v twice", id, r)
+					}
+				}
+				applied[id] = append(applied[id], r)
+				client.Ack(r)
+			}
+		}
+		// applied requests on every replica are prefixes of each other
+		for _, id := range nodes {
+			for _, other := range nodes {
+				a, b := applied[id], applied[other]
+				for i := 0; i < len(a) && i < len(b); i++ {
+					if a[i].Seq != b[i].Seq {
+						return fmt.Errorf("replica %d applied %v, replica %d applied %v", id, a[i], other, b[i])
+					}
+				}
+			}
+		}
+		if _, exist := client.Pending(); exist {
+			client.Retry()
+		}
+	}
+}
+
+func TestSessionExactlyOnce(t *testing.T) {
+	Run(t, sessionRunner, runFlags(),
+		WithExplicitPartitions(
+			[][]int{
+				{1, 2, 3},
+			},
+			[][]int{
+				{1},
+				{2, 3},
+			},
+			[][]int{
+				{1, 2},
+				{3},
+			},
+		),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1, 3),
+		WithSteps(5),
+	)
+}
+
+func TestSessionDeduplication(t *testing.T) {
+	client := NewClient(7, []int{1, 2})
+	first := client.Submit([]byte("a"))
+	require.Equal(t, 1, client.Replica())
+	require.Equal(t, 2, client.Retry())
+
+	decoded, err := DecodeRequest(first.Value())
+	require.NoError(t, err)
+	require.Equal(t, first, decoded)
+
+	session := NewSession()
+	_, apply, err := session.Apply(first.Value())
+	require.NoError(t, err)
+	require.True(t, apply)
+	_, apply, err = session.Apply(first.Value())
+	require.NoError(t, err)
+	require.False(t, apply)
+	_, apply, err = session.Apply(Noop)
+	require.NoError(t, err)
+	require.False(t, apply)
+
+	client.Ack(first)
+	_, exist := client.Pending()
+	require.False(t, exist)
+}
+
+// sliceLog is a LogNode with values chosen in every slot, nil for a gap.
+type sliceLog []Value
+
+func (l sliceLog) Len() int              { return len(l) }
+func (l sliceLog) Chosen(slot int) Value { return l[slot] }
+
+func TestSessionApplyLog(t *testing.T) {
+	first := Request{Client: 1, Seq: 1, Payload: []byte("a")}
+	second := Request{Client: 1, Seq: 2, Payload: []byte("b")}
+	other := Request{Client: 2, Seq: 1, Payload: []byte("c")}
+
+	session := NewSession()
+	// retry of the first request is chosen after the second
+	log := sliceLog{second.Value(), Noop, first.Value(), nil, first.Value(), other.Value()}
+	requests, err := session.ApplyLog(log)
+	require.NoError(t, err)
+	require.Equal(t, []Request{second, first}, requests)
+
+	log[3] = second.Value()
+	requests, err = session.ApplyLog(log)
+	require.NoError(t, err)
+	require.Equal(t, []Request{other}, requests)
+
+	requests, err = session.ApplyLog(log)
+	require.NoError(t, err)
+	require.Empty(t, requests)
+}
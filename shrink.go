@@ -0,0 +1,109 @@
+package paxos
+
+// Shrink minimizes a failed test case: it removes steps, replaces partitions
+// with a fully connected network, and removes actions and leaders from the
+// steps, while the test case still fails. Returns the minimized test case and
+// its error, or the original test case if it doesn't fail.
+// Failure of the minimized test case may have a different cause.
+func Shrink(run Runner, tc *TestCase) (*TestCase, error) {
+	current := &TestCase{gen: tc.gen, states: append([]int(nil), tc.states...)}
+	err := run(current)
+	if err == nil {
+		return tc, nil
+	}
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for _, candidate := range tc.gen.shrinkCandidates(current.states) {
+			candidate := &TestCase{gen: tc.gen, states: candidate}
+			if cerr := run(candidate); cerr != nil {
+				current, err = candidate, cerr
+				shrunk = true
+				break
+			}
+		}
+	}
+	return current, err
+}
+
+// shrinkCandidates returns schedules that are simpler than states,
+// simplest first.
+func (g *Generator) shrinkCandidates(states []int) [][]int {
+	var rst [][]int
+	// remove a step. later steps are shifted only if their states are
+	// possible one step earlier
+	for i := range states {
+		if len(states) == 1 {
+			break
+		}
+		candidate := append([]int(nil), states[:i]...)
+		possible := true
+		for j := i + 1; j < len(states) && possible; j++ {
+			var index int
+			index, possible = g.findState(j-1, g.statesAt(j)[states[j]])
+			candidate = append(candidate, index)
+		}
+		if possible {
+			rst = append(rst, candidate)
+		}
+	}
+	var (
+		connected   = FullyConnected(g.nodes...)
+		replacement = func(i int, state stepState) {
+			if index, ok := g.findState(i, state); ok && index != states[i] {
+				candidate := append([]int(nil), states...)
+				candidate[i] = index
+				rst = append(rst, candidate)
+			}
+		}
+	)
+	// every replacement is strictly simpler than the current state,
+	// otherwise shrinking may cycle between equivalent states
+	for i := range states {
+		state := g.statesAt(i)[states[i]]
+		if !equalPartitions(g.partitions[state.partition], connected) {
+			for p, network := range g.partitions {
+				if equalPartitions(network, connected) {
+					replacement(i, stepState{actions: state.actions, partition: p, order: state.order})
+				}
+			}
+		}
+		if state.order > 0 {
+			replacement(i, stepState{actions: state.actions, partition: state.partition})
+		}
+		current := g.actions[state.actions]
+		for _, simpler := range []Actions{{}, withoutLeaders(current)} {
+			if equalActions(simpler, current) {
+				continue
+			}
+			for a, actions := range g.actions {
+				if equalActions(actions, simpler) {
+					replacement(i, stepState{actions: a, partition: state.partition, order: state.order})
+				}
+			}
+		}
+	}
+	return rst
+}
+
+// findState returns index of the state in the step.
+func (g *Generator) findState(step int, state stepState) (int, bool) {
+	for i, other := range g.statesAt(step) {
+		if other == state {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func withoutLeaders(actions Actions) Actions {
+	rst := Actions{}
+	for id, action := range actions {
+		if action = action &^ ActionLead; action.Value() > 0 {
+			action = action.WithValue(0)
+		}
+		if action != 0 {
+			rst[id] = action
+		}
+	}
+	return rst
+}
@@ -0,0 +1,86 @@
+package paxos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShrink(t *testing.T) {
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	)
+	require.NoError(t, err)
+	// fails if 2 proposes after 1 while 1 and 3 can't reach each other
+	run := func(tc *TestCase) error {
+		proposed := false
+		for network, actions := range tc.Steps() {
+			if actions.IsLeader(1) && !network.Reachable(1, 3) {
+				proposed = true
+			}
+			if proposed && actions.IsLeader(2) {
+				return errors.New("failed")
+			}
+		}
+		return nil
+	}
+	var failed *TestCase
+	for tc := range gen.All() {
+		if len(tc.states) == 4 && tc.states[3] != 0 && run(&TestCase{gen: gen, states: tc.states}) != nil {
+			failed = tc
+		}
+	}
+	require.NotNil(t, failed)
+
+	minimized, err := Shrink(run, failed)
+	require.Error(t, err)
+	steps := minimized.Schedule()
+	require.Len(t, steps, 2, "%s", minimized)
+	require.True(t, equalActions(Actions{1: ActionLead}, steps[0].Actions))
+	require.True(t, equalPartitions(groupPartition([][]int{{1, 2}, {3}}), steps[0].Network) ||
+		equalPartitions(groupPartition([][]int{{1}, {2, 3}}), steps[0].Network) ||
+		equalPartitions(groupPartition([][]int{{1}, {2}, {3}}), steps[0].Network), "%s", minimized)
+	require.True(t, equalActions(Actions{2: ActionLead}, steps[1].Actions))
+	require.True(t, equalPartitions(FullyConnected(1, 2, 3), steps[1].Network))
+
+	passed := &TestCase{gen: gen, states: make([]int, 4)}
+	minimized, err = Shrink(run, passed)
+	require.NoError(t, err)
+	require.Equal(t, passed, minimized)
+}
+
+func TestShrinkPaxos(t *testing.T) {
+	run := Simulate(brokenQuorumFactory)
+	gen, err := NewGen(
+		WithReplicas(1, 2, 3, 4, 5),
+		WithExplicitPartitions(
+			[][]int{{1, 2, 3, 4, 5}},
+			[][]int{{1, 2}, {3, 4, 5}},
+		),
+		WithLeaders(1, 3),
+		WithSteps(8),
+	)
+	require.NoError(t, err)
+	for tc := range gen.All() {
+		if run(tc) == nil {
+			continue
+		}
+		minimized, err := Shrink(run, tc)
+		require.Error(t, err)
+		require.Less(t, len(minimized.states), len(tc.states), "%s", minimized)
+
+		// minimized test case is replayed
+		buf, err := minimized.Marshal()
+		require.NoError(t, err)
+		replayed := &TestCase{gen: gen}
+		require.NoError(t, replayed.Unmarshal(buf))
+		require.NoError(t, replayed.validate())
+		require.Error(t, run(replayed))
+		return
+	}
+	t.Fatal("none of the test cases failed")
+}
@@ -0,0 +1,498 @@
+package paxos
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+)
+
+// NodeFactory creates a replica with id that is a member of the nodes.
+type NodeFactory func(id int, nodes []int) (Node, error)
+
+type ClusterOption func(c *Cluster) error
+
+// WithOmega elects leaders using Omega failure detector on every replica.
+// Replica that can reach other replica in the current step receives a heartbeat from it.
+// Elected leader proposes once it is elected, and then every retry ticks
+// until it learns a value. Proposal needs at least 4 steps to complete.
+// Leaders elected by the detector propose in addition to the leaders from the actions.
+func WithOmega(timeout, retry int) ClusterOption {
+	return func(c *Cluster) error {
+		if timeout <= 0 || retry <= 0 {
+			return fmt.Errorf("timeout %d and retry %d must be positive", timeout, retry)
+		}
+		c.retry = retry
+		c.detectors = make(map[int]*Omega, len(c.ids))
+		c.terms = make(map[int]int, len(c.ids))
+		for _, id := range c.ids {
+			c.detectors[id] = NewOmega(id, c.ids, timeout)
+		}
+		return nil
+	}
+}
+
+// WithValues configures a value that is proposed by the leader.
+// By default leader proposes its own id as a single byte.
+func WithValues(values func(leader int) Value) ClusterOption {
+	return func(c *Cluster) error {
+		c.values = values
+		return nil
+	}
+}
+
+// WithValidation validates internal invariants of the replica after every
+// delivered message. Replica must implement Validate() error,
+// otherwise it is not validated.
+func WithValidation() ClusterOption {
+	return func(c *Cluster) error {
+		c.validate = true
+		return nil
+	}
+}
+
+// Simulate returns a Runner that executes every test case on a fresh cluster
+// created with factory, and checks safety invariants after every step.
+func Simulate(factory NodeFactory, opts ...ClusterOption) Runner {
+	return func(tc *TestCase) error {
+		cluster, err := NewCluster(tc.Nodes(), factory, opts...)
+		if err != nil {
+			return err
+		}
+		tc.trace = tc.trace[:0]
+		for {
+			done, err := cluster.stepCase(tc)
+			if done || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// stepCase executes the next step of the test case and checks invariants.
+// Returns true if all steps were executed.
+func (c *Cluster) stepCase(tc *TestCase) (bool, error) {
+	network, actions := tc.Next()
+	if network == nil || actions == nil {
+		return true, c.checkLiveness()
+	}
+	c.rng, c.source = nil, tc.Rand
+	if index, ok := tc.Delivery(); ok {
+		c.StepOne(network, actions, index)
+		c.flushTrace(tc)
+		if tc.gen.reduction && c.reduce(tc, index) {
+			return true, c.Check()
+		}
+	} else {
+		c.StepOrdered(network, actions, tc.Order())
+		c.flushTrace(tc)
+	}
+	c.cover(tc)
+	return false, c.Check()
+}
+
+// NewCluster creates a replica for every id in nodes.
+func NewCluster(nodes []int, factory NodeFactory, opts ...ClusterOption) (*Cluster, error) {
+	c := &Cluster{
+		ids:     nodes,
+		nodes:   make(map[int]Node, len(nodes)),
+		factory: factory,
+	}
+	for _, id := range nodes {
+		node, err := factory(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		c.nodes[id] = node
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Cluster is a lock-step simulation of the network of replicas.
+// At every step leaders propose, and every message that can reach
+// destination is delivered. Replies are delivered on the next step.
+type Cluster struct {
+	ids     []int
+	nodes   map[int]Node
+	factory NodeFactory
+
+	// persisted state of the crashed replicas
+	crashed map[int][]byte
+
+	values func(leader int) Value
+
+	retry     int
+	detectors map[int]*Omega
+	// number of ticks since replica became a leader. 0 if replica is not a leader.
+	terms map[int]int
+
+	// messages that will be delivered on the next step
+	messages []Message
+	// messages that couldn't be delivered on the current step
+	delayed []Message
+	// replies to the messages that were delivered on the current step
+	replies []Message
+	// messages that are sent over slow links
+	inflight []inflightMessage
+	// selects messages that are lost on lossy links. created lazily
+	// from the source of the current step, since most steps don't need it.
+	rng    *rand.Rand
+	source func() *rand.Rand
+	// shuffles messages, re-seeded with the order of every step
+	shuffle *rand.Rand
+
+	// values chosen in every slot of the multi-instance log
+	chosen map[int]Value
+	// state of the replicated machines after every applied slot
+	states map[int][]byte
+
+	// every value that was proposed by the leaders
+	proposed map[string]struct{}
+
+	// last reported outcome of every tracked proposal
+	outcomes map[proposal]Outcome
+	// acceptors that ever voted for the value in the ballot, see checkReads
+	votes map[vote]map[int]struct{}
+
+	validate bool
+	// first error reported by Validate
+	err error
+
+	// check that a value was learned at the end of the test case
+	liveness bool
+
+	// message delivered by the last StepOne, if any
+	delivered   Message
+	isDelivered bool
+	// last step of the single delivery mode. See WithPartialOrderReduction.
+	previous delivery
+
+	// messages delivered in the current step. See WithTracing.
+	tracing bool
+	traced  []Message
+}
+
+// Node returns a replica with id or nil.
+func (c *Cluster) Node(id int) Node {
+	return c.nodes[id]
+}
+
+// ticker is implemented by nodes that depend on time. Cluster ticks
+// every node at the start of the step, once unless actions skew the clock.
+type ticker interface {
+	Tick()
+}
+
+// actor is implemented by nodes that change behavior according to actions.
+type actor interface {
+	Act(Actions)
+}
+
+// Step delivers messages in the order they were sent.
+func (c *Cluster) Step(network Partition, actions Actions) {
+	c.StepOrdered(network, actions, 0)
+}
+
+// StepOrdered delivers messages in the order that is derived from order.
+// Messages that arrived over slow links are delivered first.
+// Messages on lossy links are lost with the probability of the link.
+// See WithDeliveryOrders.
+func (c *Cluster) StepOrdered(network Partition, actions Actions, order int) {
+	c.propose(network, actions)
+	c.reorder(c.messages, order)
+	replies := c.replies[:0]
+	deliver := func(msg Message, reachable bool) {
+		// messages that can't reach other node are delayed, unless
+		// the step drops messages to that node
+		if reachable {
+			c.traceDelivery(msg)
+			replies = append(replies, c.nodes[msg.To].Step(msg)...)
+			c.validateNode(msg.To)
+		} else if !actions.IsDropped(msg.To) {
+			c.delayed = append(c.delayed, msg)
+		}
+	}
+	for _, msg := range c.arrived() {
+		deliver(msg, network.Reachable(msg.From, msg.To))
+	}
+	for _, msg := range c.messages {
+		link, reachable := network[msg.From][msg.To]
+		if link.Loss > 0 && c.random().Intn(100) < link.Loss {
+			continue
+		}
+		if link.Delay > 0 {
+			c.inflight = append(c.inflight, inflightMessage{msg: msg, steps: link.Delay})
+			continue
+		}
+		deliver(msg, reachable)
+	}
+	c.messages = append(c.messages[:0], c.delayed...)
+	c.messages = append(c.messages, replies...)
+	c.delayed = c.delayed[:0]
+	c.replies = replies[:0]
+}
+
+// StepOne delivers exactly one pending message, selected by index among
+// messages that are reachable in the network. Replies are added to the end of
+// pending messages. If index is out of range nothing is delivered.
+// Delays and losses of the links are ignored. See WithSingleDelivery.
+func (c *Cluster) StepOne(network Partition, actions Actions, index int) {
+	c.propose(network, actions)
+	c.isDelivered = false
+	reachable := 0
+	for i, msg := range c.messages {
+		if !network.Reachable(msg.From, msg.To) {
+			continue
+		}
+		if reachable < index {
+			reachable++
+			continue
+		}
+		c.delivered, c.isDelivered = msg, true
+		c.traceDelivery(msg)
+		c.messages = append(c.messages[:i], c.messages[i+1:]...)
+		c.messages = append(c.messages, c.nodes[msg.To].Step(msg)...)
+		c.validateNode(msg.To)
+		return
+	}
+}
+
+// propose ticks every replica, starts proposals on leaders, and collects outboxes.
+func (c *Cluster) propose(network Partition, actions Actions) {
+	for _, id := range c.ids {
+		if actions.IsCrashed(id) {
+			c.crash(id)
+		} else if actions.IsRecovered(id) {
+			c.recover(id)
+		}
+	}
+	elected := c.elect(c.alive(network), actions)
+	for _, id := range c.ids {
+		if c.isCrashed(id) {
+			continue
+		}
+		node := c.nodes[id]
+		if t, ok := node.(ticker); ok {
+			for i := 0; i < actions.Ticks(id); i++ {
+				t.Tick()
+			}
+		}
+		if a, ok := node.(actor); ok {
+			a.Act(actions)
+		}
+		if actions.IsLeader(id) || elected[id] {
+			value := c.value(id)
+			if assigned := actions.Value(id); assigned > 0 {
+				value = assignedValue(assigned)
+			}
+			if c.proposed == nil {
+				c.proposed = map[string]struct{}{}
+			}
+			c.proposed[string(value)] = struct{}{}
+			node.Propose(value)
+			c.track(id)
+		}
+		c.messages = append(c.messages, node.Step(Message{})...)
+		c.validateNode(id)
+	}
+	c.dropCrashed()
+}
+
+// random returns a source of randomness for the current step.
+func (c *Cluster) random() *rand.Rand {
+	if c.rng == nil && c.source != nil {
+		c.rng = c.source()
+	} else if c.rng == nil {
+		c.rng = rand.New(rand.NewSource(0))
+	}
+	return c.rng
+}
+
+type inflightMessage struct {
+	msg Message
+	// number of steps until the message arrives
+	steps int
+}
+
+// arrived returns messages that were sent over slow links and
+// arrive in the current step.
+func (c *Cluster) arrived() []Message {
+	var rst []Message
+	inflight := c.inflight[:0]
+	for _, m := range c.inflight {
+		m.steps--
+		if m.steps == 0 {
+			rst = append(rst, m.msg)
+		} else {
+			inflight = append(inflight, m)
+		}
+	}
+	c.inflight = inflight
+	return rst
+}
+
+func (c *Cluster) reorder(messages []Message, order int) {
+	switch order {
+	case 0:
+	case 1:
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	default:
+		if c.shuffle == nil {
+			c.shuffle = rand.New(rand.NewSource(int64(order)))
+		} else {
+			c.shuffle.Seed(int64(order))
+		}
+		c.shuffle.Shuffle(len(messages), func(i, j int) {
+			messages[i], messages[j] = messages[j], messages[i]
+		})
+	}
+}
+
+// assignedValuePrefix distinguishes values assigned with WithProposedValues
+// from the default values, which are ids of the leaders.
+const assignedValuePrefix = 0xff
+
+func assignedValue(n int) Value {
+	return Value{assignedValuePrefix, byte(n)}
+}
+
+func (c *Cluster) value(leader int) Value {
+	if c.values == nil {
+		return []byte{byte(leader)}
+	}
+	return c.values(leader)
+}
+
+// elect returns replicas that need to propose according to failure detectors.
+func (c *Cluster) elect(network Partition, actions Actions) map[int]bool {
+	if c.detectors == nil {
+		return nil
+	}
+	elected := map[int]bool{}
+	for _, id := range c.ids {
+		detector := c.detectors[id]
+		for i := 0; i < actions.Ticks(id); i++ {
+			detector.Tick()
+		}
+		for _, from := range c.ids {
+			if network.Reachable(from, id) {
+				detector.Heartbeat(from)
+			}
+		}
+		if detector.Leader() != id {
+			c.terms[id] = 0
+			continue
+		}
+		if c.terms[id]%c.retry == 0 && c.nodes[id].Learned() == nil {
+			elected[id] = true
+		}
+		c.terms[id]++
+	}
+	return elected
+}
+
+// validator is implemented by nodes that can check internal invariants.
+type validator interface {
+	Validate() error
+}
+
+func (c *Cluster) validateNode(id int) {
+	if !c.validate || c.err != nil {
+		return
+	}
+	if v, ok := c.nodes[id].(validator); ok {
+		if err := v.Validate(); err != nil {
+			c.err = fmt.Errorf("replica %d: %w", id, err)
+		}
+	}
+}
+
+// errorer is implemented by nodes that check invariants internally.
+type errorer interface {
+	Err() error
+}
+
+// Check verifies that all replicas learned the same value and that none of
+// the replicas reported an error.
+func (c *Cluster) Check() error {
+	if c.err != nil {
+		return c.err
+	}
+	var learned Value
+	for _, id := range c.ids {
+		if node, ok := c.nodes[id].(errorer); ok {
+			if err := node.Err(); err != nil {
+				return err
+			}
+		}
+		value := c.nodes[id].Learned()
+		if value != nil && learned == nil {
+			learned = value
+		} else if value != nil {
+			if !bytes.Equal(learned, value) {
+				return fmt.Errorf("%v != %v", learned, value)
+			}
+		}
+	}
+	if learned != nil && !IsNoop(learned) {
+		if _, exist := c.proposed[string(learned)]; !exist {
+			return fmt.Errorf("learned %v was never proposed", learned)
+		}
+	}
+	if err := c.checkLog(); err != nil {
+		return err
+	}
+	if err := c.checkMachines(); err != nil {
+		return err
+	}
+	if err := c.checkOutcomes(); err != nil {
+		return err
+	}
+	return c.checkReads(learned)
+}
+
+// proposer is implemented by nodes that report outcome of every proposal.
+type proposer interface {
+	Proposal() int
+	Outcome(id int) Outcome
+}
+
+type proposal struct {
+	replica, id int
+}
+
+// track starts tracking the last proposal of the replica.
+func (c *Cluster) track(id int) {
+	node, ok := c.nodes[id].(proposer)
+	if !ok || node.Proposal() == 0 {
+		return
+	}
+	if c.outcomes == nil {
+		c.outcomes = map[proposal]Outcome{}
+	}
+	key := proposal{replica: id, id: node.Proposal()}
+	if _, exist := c.outcomes[key]; !exist {
+		c.outcomes[key] = OutcomePending
+	}
+}
+
+// checkOutcomes verifies that outcomes that were reported to clients as
+// final never change.
+func (c *Cluster) checkOutcomes() error {
+	for key, before := range c.outcomes {
+		node := c.nodes[key.replica].(proposer)
+		outcome := node.Outcome(key.id)
+		if (before == OutcomeChosen || before == OutcomeRejected) && outcome != before {
+			return fmt.Errorf("proposal %d on replica %d changed outcome from %s to %s",
+				key.id, key.replica, before, outcome)
+		}
+		c.outcomes[key] = outcome
+	}
+	return nil
+}
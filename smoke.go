@@ -0,0 +1,83 @@
+package paxos
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// WithSmoke generates count diverse test cases before the exhaustive product,
+// so that obvious bugs are found before the long sweep. Test cases are
+// selected with latin hypercube sampling: states of every step are split into
+// count strata, and every stratum of every step is used by exactly one test case.
+// Selection is deterministic, and the exhaustive product skips selected test cases.
+// Ignored when test cases are replayed.
+func WithSmoke(count int) GenOption {
+	return func(g *Generator) error {
+		if count <= 0 {
+			return fmt.Errorf("count %d must be positive", count)
+		}
+		g.smoke = count
+		return nil
+	}
+}
+
+// smokeIterator returns smoke test cases, followed by the test cases
+// from the exhaustive product that weren't returned yet.
+type smokeIterator struct {
+	gen  *Generator
+	iter Iterator
+
+	cases   [][]int
+	seen    map[string]struct{}
+	current *TestCase
+}
+
+func newSmokeIterator(gen *Generator, iter Iterator, count int) *smokeIterator {
+	rng := rand.New(rand.NewSource(1))
+	cases := make([][]int, count)
+	for i := range cases {
+		cases[i] = make([]int, gen.stepLimit)
+	}
+	for step := 0; step < gen.stepLimit; step++ {
+		states := len(gen.statesAt(step))
+		for i, stratum := range rng.Perm(count) {
+			// center of the stratum
+			cases[i][step] = (2*stratum + 1) * states / (2 * count)
+		}
+	}
+	seen := make(map[string]struct{}, count)
+	unique := cases[:0]
+	for _, states := range cases {
+		key := prefixKey(states)
+		if _, exist := seen[key]; !exist {
+			seen[key] = struct{}{}
+			unique = append(unique, states)
+		}
+	}
+	return &smokeIterator{gen: gen, iter: iter, cases: unique, seen: seen}
+}
+
+func (s *smokeIterator) Next() bool {
+	if len(s.cases) > 0 {
+		s.current = &TestCase{gen: s.gen, states: s.cases[0]}
+		s.cases = s.cases[1:]
+		return true
+	}
+	for s.iter.Next() {
+		tc := s.iter.Current()
+		if _, exist := s.seen[prefixKey(tc.states)]; !exist {
+			s.current = tc
+			return true
+		}
+		tc.Release()
+	}
+	return false
+}
+
+func (s *smokeIterator) Current() *TestCase {
+	return s.current
+}
+
+func (s *smokeIterator) Error() error {
+	return s.iter.Error()
+}
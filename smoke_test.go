@@ -0,0 +1,47 @@
+package paxos
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmoke(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3),
+		WithSteps(3),
+	}
+	all := collectCases(t, opts...)
+	cases := collectCases(t, append(opts, WithSmoke(10))...)
+	require.Len(t, cases, len(all))
+
+	// every step of the smoke test cases uses 10 distinct states out of 20
+	for step := 0; step < 3; step++ {
+		used := map[int]struct{}{}
+		for _, buf := range cases[:10] {
+			tc := &TestCase{}
+			require.NoError(t, tc.Unmarshal(buf))
+			used[tc.states[step]] = struct{}{}
+		}
+		require.Len(t, used, 10)
+	}
+	require.NotEqual(t, all[:10], cases[:10])
+
+	sorted := func(cases [][]byte) []string {
+		rst := make([]string, len(cases))
+		for i := range cases {
+			rst[i] = string(cases[i])
+		}
+		sort.Strings(rst)
+		return rst
+	}
+	require.Equal(t, sorted(all), sorted(cases))
+
+	_, err := NewGen(append(opts, WithSmoke(10), WithSwarm(10, 1))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithSmoke(0))...)
+	require.Error(t, err)
+}
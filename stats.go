@@ -0,0 +1,96 @@
+package paxos
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Stats summarizes the space of the generator and the test cases
+// that were generated so far.
+type Stats struct {
+	Replicas   int
+	Partitions int
+	Actions    int
+	// States is a number of possible states in every step.
+	States []int
+	// Total is a number of schedules in the exhaustive product.
+	Total *big.Int
+
+	// Considered is a number of schedules that were produced by the exploration
+	// strategy, before sampling, constraints, shards and symmetry reduction.
+	Considered int
+	// Generated is a number of test cases that were returned by Next.
+	Generated int
+	// Executed is a number of test cases that were reported with Feedback.
+	Executed int
+	// Tags are counts of the test cases with every tag, see WithTag.
+	Tags []TagStats
+}
+
+// TagStats is a number of executed and failed test cases with the tag.
+// Executed includes failed test cases, which are counted only by Run.
+type TagStats struct {
+	Name     string `json:"name"`
+	Executed int    `json:"executed"`
+	Failed   int    `json:"failed"`
+}
+
+// Skipped returns number of schedules that were skipped by sampling,
+// constraints, shards and symmetry reduction.
+func (s Stats) Skipped() int {
+	return s.Considered - s.Generated
+}
+
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "replicas %d, partitions %d, actions %d, total %s\n",
+		s.Replicas, s.Partitions, s.Actions, s.Total)
+	b.WriteString("states per step")
+	for _, states := range s.States {
+		fmt.Fprintf(&b, " %d", states)
+	}
+	fmt.Fprintf(&b, "\nconsidered %d, skipped %d, generated %d, executed %d",
+		s.Considered, s.Skipped(), s.Generated, s.Executed)
+	for _, tag := range s.Tags {
+		fmt.Fprintf(&b, "\ntag %s: executed %d, failed %d", tag.Name, tag.Executed, tag.Failed)
+	}
+	return b.String()
+}
+
+// Stats returns statistics of the generator. Safe to use from multiple goroutines.
+func (g *Generator) Stats() Stats {
+	stats := Stats{
+		Replicas:   len(g.nodes),
+		Partitions: len(g.partitions),
+		Actions:    len(g.actions),
+		States:     make([]int, g.stepLimit),
+		Total:      g.Total(),
+	}
+	for step := range stats.States {
+		stats.States[step] = len(g.statesAt(step))
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats.Considered = g.considered
+	stats.Generated = g.cnt
+	stats.Executed = g.executed
+	for _, t := range g.tags {
+		stats.Tags = append(stats.Tags, TagStats{Name: t.name, Executed: t.executed, Failed: t.failed})
+	}
+	return stats
+}
+
+// countingIterator counts schedules that were produced by the exploration strategy.
+type countingIterator struct {
+	Iterator
+	cnt *int
+}
+
+func (c *countingIterator) Next() bool {
+	if c.Iterator.Next() {
+		*c.cnt++
+		return true
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package paxos
+
+import "fmt"
+
+// Phase of the proposal that was started by the replica.
+type Phase int8
+
+const (
+	// PhaseIdle replica doesn't drive any proposal.
+	PhaseIdle Phase = iota
+	// PhasePrepare replica sent Prepare and waits for Promises.
+	PhasePrepare
+	// PhaseAccept replica sent Accept and waits for Accepted.
+	PhaseAccept
+)
+
+var phaseString = [...]string{
+	"Idle",
+	"Prepare",
+	"Accept",
+}
+
+func (p Phase) String() string {
+	return phaseString[p]
+}
+
+// Outcome of the proposal as it is observed by the client of the replica.
+type Outcome int8
+
+const (
+	// OutcomePending proposal is in progress, it may be chosen or rejected.
+	OutcomePending Outcome = iota
+	// OutcomeChosen value of the proposal was chosen.
+	OutcomeChosen
+	// OutcomeRejected different value was chosen.
+	OutcomeRejected
+	// OutcomeSuperseded replica started a new proposal before anything was chosen.
+	// Superseded proposal may still be chosen if it was accepted by some replicas.
+	OutcomeSuperseded
+	// OutcomeUnknown replica didn't make a proposal with the id.
+	OutcomeUnknown
+)
+
+var outcomeString = [...]string{
+	"Pending",
+	"Chosen",
+	"Rejected",
+	"Superseded",
+	"Unknown",
+}
+
+func (o Outcome) String() string {
+	return outcomeString[o]
+}
+
+// Status is a snapshot of the replica state.
+// Values are shared with the replica and must not be modified.
+type Status struct {
+	ID     int
+	Ballot int
+	Phase  Phase
+
+	// number of Promises and Accepted collected for the last proposal,
+	// including the vote of the replica itself.
+	Promises, Accepts int
+
+	VotedBallot int
+	VotedValue  Value
+
+	LearnedValue Value
+
+	// replica can't propose because ballot reached the limit
+	Exhausted bool
+}
+
+func (s Status) String() string {
+	return fmt.Sprintf("Status[ID=%d Ballot=%d Phase=%s Promises=%d Accepts=%d VBallot=%d VValue=%s Learned=%s Exhausted=%v]",
+		s.ID, s.Ballot, s.Phase, s.Promises, s.Accepts, s.VotedBallot, s.VotedValue, s.LearnedValue, s.Exhausted,
+	)
+}
+
+// Status returns a read-only snapshot of the replica state.
+func (p *Paxos) Status() Status {
+	return Status{
+		ID:           p.ID,
+		Ballot:       p.ballot,
+		Phase:        p.phase,
+		Promises:     len(p.promises),
+		Accepts:      len(p.accepts),
+		VotedBallot:  p.votedBallot,
+		VotedValue:   p.votedValue,
+		LearnedValue: p.LearnedValue,
+		Exhausted:    p.Exhausted(),
+	}
+}
@@ -0,0 +1,154 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// swarm features, every state of the step has a subset of the features.
+const (
+	featurePartition = 1 << iota
+	featureMultiLeader
+	featureByzantine
+	featureDrop
+	featureCrash
+	featureReorder
+
+	featuresCount = iota
+)
+
+// WithSwarm replaces the exhaustive product with count random test cases.
+// Every test case enables a random subset of fault classes: partitions,
+// concurrent leaders, byzantine replicas, drops, crashes and reordered delivery.
+// Steps are selected only from the states with enabled faults. Such test cases
+// push the cluster further in one direction than uniformly sampled test cases,
+// where every fault is present in almost every test case.
+func WithSwarm(count int, seed int64) GenOption {
+	return func(g *Generator) error {
+		if count <= 0 {
+			return fmt.Errorf("count %d must be positive", count)
+		}
+		g.swarm = &swarmIterator{
+			gen:   g,
+			count: count,
+			rng:   rand.New(rand.NewSource(seed)),
+		}
+		return nil
+	}
+}
+
+type swarmIterator struct {
+	gen   *Generator
+	count int
+	rng   *rand.Rand
+
+	generated int
+	// states of the step grouped by features. -1 for steps with default states.
+	groups  map[int]map[int][]int
+	current *TestCase
+}
+
+func (s *swarmIterator) Next() bool {
+	if s.generated == s.count {
+		return false
+	}
+	s.generated++
+	enabled := s.rng.Intn(1 << featuresCount)
+	states := make([]int, s.gen.stepLimit)
+	for i := range states {
+		groups := s.features(i)
+		var allowed []int
+		for features := 0; features < 1<<featuresCount; features++ {
+			if features&^enabled == 0 {
+				allowed = append(allowed, groups[features]...)
+			}
+		}
+		if len(allowed) == 0 {
+			states[i] = s.rng.Intn(len(s.gen.statesAt(i)))
+		} else {
+			states[i] = allowed[s.rng.Intn(len(allowed))]
+		}
+	}
+	s.current = &TestCase{gen: s.gen, states: states}
+	return true
+}
+
+// features returns states of the step grouped by their features.
+func (s *swarmIterator) features(step int) map[int][]int {
+	if _, exist := s.gen.stepStates[step]; !exist {
+		step = -1
+	}
+	if groups, exist := s.groups[step]; exist {
+		return groups
+	}
+	if s.groups == nil {
+		s.groups = map[int]map[int][]int{}
+	}
+	groups := map[int][]int{}
+	states := s.gen.states
+	if step >= 0 {
+		states = s.gen.stepStates[step]
+	}
+	for i, state := range states {
+		features := s.gen.stateFeatures(state)
+		groups[features] = append(groups[features], i)
+	}
+	s.groups[step] = groups
+	return groups
+}
+
+func (g *Generator) stateFeatures(state stepState) int {
+	features := 0
+	network := g.partitions[state.partition]
+	for _, from := range g.nodes {
+		for _, to := range g.nodes {
+			if from != to && !network.Reachable(from, to) {
+				features |= featurePartition
+			}
+		}
+	}
+	leaders := 0
+	for id, action := range g.actions[state.actions] {
+		if g.actions[state.actions].IsLeader(id) {
+			leaders++
+		}
+		if action&ActionByzantine > 0 {
+			features |= featureByzantine
+		}
+		if action&ActionDrop > 0 {
+			features |= featureDrop
+		}
+		if action&(ActionCrash|ActionRecover) > 0 {
+			features |= featureCrash
+		}
+	}
+	if leaders > 1 {
+		features |= featureMultiLeader
+	}
+	if state.order > 0 {
+		features |= featureReorder
+	}
+	return features
+}
+
+func (s *swarmIterator) Current() *TestCase {
+	return s.current
+}
+
+func (s *swarmIterator) Error() error {
+	return nil
+}
+
+func (g *Generator) validateSwarm() error {
+	if g.swarm == nil {
+		return nil
+	}
+	if g.iter != nil || g.coverage != nil {
+		return errors.New("swarm can't be used with replay or coverage guided generation")
+	}
+	if g.shards > 1 || g.symmetry {
+		return errors.New("swarm can't be used with shards or symmetry reduction")
+	}
+	return nil
+}
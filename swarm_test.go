@@ -0,0 +1,63 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwarm(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3),
+		WithCrashes(1, 2, 3),
+		WithSteps(8),
+		WithSwarm(1000, 7),
+	}
+	require.Equal(t, collectCases(t, opts...), collectCases(t, opts...))
+
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	withoutCrashes, withoutPartitions := 0, 0
+	for tc := range gen.All() {
+		crashes, partitions := false, false
+		for network, actions := range tc.Steps() {
+			for _, id := range tc.Nodes() {
+				crashes = crashes || actions[id]&(ActionCrash|ActionRecover) > 0
+				for _, to := range tc.Nodes() {
+					partitions = partitions || id != to && !network.Reachable(id, to)
+				}
+			}
+		}
+		if !crashes {
+			withoutCrashes++
+		}
+		if !partitions {
+			withoutPartitions++
+		}
+	}
+	require.Equal(t, 1000, gen.Count())
+	// every fault class is disabled in approximately half of the test cases.
+	// with uniform sampling almost every test case has both crashes and partitions.
+	require.InDelta(t, 500, withoutCrashes, 100)
+	require.InDelta(t, 500, withoutPartitions, 100)
+}
+
+func TestByzantineSwarm(t *testing.T) {
+	RunExpectFailure(t, Simulate(func(id int, nodes []int) (Node, error) {
+		p, err := NewPaxos(id, nodes)
+		if err != nil {
+			return nil, err
+		}
+		return NewByzantine(p), nil
+	}),
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3),
+		WithByzantine(1, 2, 3),
+		WithCrashes(1, 2, 3),
+		WithSteps(8),
+		WithSwarm(100000, 1),
+	)
+}
@@ -0,0 +1,182 @@
+package paxos
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxSymmetryReplicas limits number of replicas for symmetry reduction,
+// every permutation of the replicas is checked.
+const maxSymmetryReplicas = 8
+
+// WithSymmetryReduction skips test cases that are equal to an already generated
+// test case up to renaming of the replicas. For example, if replicas 1 and 3
+// are symmetric in every partition, a schedule where 3 is a leader is skipped
+// in favor of the same schedule where 1 is a leader.
+//
+// Only renamings that map every configured partition and action to another
+// configured partition and action are used. Reduction is sound only for runners
+// that don't depend on the ids of the replicas, e.g. a model that checks
+// properties of the schedule. Simulate depends on them, since leaders propose
+// their ids by default: a skipped test case may fail while the test case
+// that is executed instead passes.
+func WithSymmetryReduction() GenOption {
+	return func(g *Generator) error {
+		if len(g.nodes) > maxSymmetryReplicas {
+			return fmt.Errorf("symmetry reduction supports at most %d replicas", maxSymmetryReplicas)
+		}
+		g.symmetry = true
+		return nil
+	}
+}
+
+// symmetry maps states of every step to the states with renamed replicas.
+type symmetry struct {
+	states []int
+	steps  map[int][]int
+}
+
+func (s *symmetry) apply(step int, state int) int {
+	if states, exist := s.steps[step]; exist {
+		return states[state]
+	}
+	return s.states[state]
+}
+
+// symmetries returns every renaming of the replicas, except identity,
+// that maps possible states of every step onto themselves.
+func (g *Generator) symmetries() []*symmetry {
+	var rst []*symmetry
+	permute(g.nodes, func(perm map[int]int) {
+		identity := true
+		for from, to := range perm {
+			if from != to {
+				identity = false
+				break
+			}
+		}
+		if identity {
+			return
+		}
+		sym := &symmetry{}
+		var ok bool
+		if sym.states, ok = g.renameStates(g.states, perm); !ok {
+			return
+		}
+		for step, states := range g.stepStates {
+			renamed, ok := g.renameStates(states, perm)
+			if !ok {
+				return
+			}
+			if sym.steps == nil {
+				sym.steps = map[int][]int{}
+			}
+			sym.steps[step] = renamed
+		}
+		rst = append(rst, sym)
+	})
+	return rst
+}
+
+// renameStates returns index of the renamed state for every state.
+// False if any of the renamed states is not possible.
+func (g *Generator) renameStates(states []stepState, perm map[int]int) ([]int, bool) {
+	index := make(map[string]int, len(states))
+	for i, state := range states {
+		index[g.stateKey(state, nil)] = i
+	}
+	rst := make([]int, len(states))
+	for i, state := range states {
+		renamed, exist := index[g.stateKey(state, perm)]
+		if !exist {
+			return nil, false
+		}
+		rst[i] = renamed
+	}
+	return rst, true
+}
+
+// stateKey is a canonical representation of the state with renamed replicas.
+func (g *Generator) stateKey(state stepState, perm map[int]int) string {
+	rename := func(id int) int {
+		if perm == nil {
+			return id
+		}
+		return perm[id]
+	}
+	var links []string
+	for from, routes := range g.partitions[state.partition] {
+		for to, link := range routes {
+			links = append(links, fmt.Sprintf("%d>%d+%d~%d", rename(from), rename(to), link.Delay, link.Loss))
+		}
+	}
+	sort.Strings(links)
+	var actions []string
+	for id, action := range g.actions[state.actions] {
+		if action != 0 {
+			actions = append(actions, fmt.Sprintf("%d=%d", rename(id), action))
+		}
+	}
+	sort.Strings(actions)
+	return fmt.Sprint(links, actions, state.order)
+}
+
+// permute calls f with every permutation of the nodes.
+func permute(nodes []int, f func(map[int]int)) {
+	targets := append([]int(nil), nodes...)
+	perm := make(map[int]int, len(nodes))
+	var generate func(i int)
+	generate = func(i int) {
+		if i == len(nodes) {
+			f(perm)
+			return
+		}
+		for j := i; j < len(targets); j++ {
+			targets[i], targets[j] = targets[j], targets[i]
+			perm[nodes[i]] = targets[i]
+			generate(i + 1)
+			targets[i], targets[j] = targets[j], targets[i]
+		}
+	}
+	generate(0)
+}
+
+// symmetryIterator skips test cases that are not the smallest among
+// their renamings.
+type symmetryIterator struct {
+	iter       Iterator
+	symmetries []*symmetry
+}
+
+func (s *symmetryIterator) Next() bool {
+	for s.iter.Next() {
+		if s.canonical(s.iter.Current()) {
+			return true
+		}
+		s.iter.Current().Release()
+	}
+	return false
+}
+
+func (s *symmetryIterator) canonical(tc *TestCase) bool {
+	for _, sym := range s.symmetries {
+		for step, state := range tc.states {
+			renamed := sym.apply(step, state)
+			if renamed < state {
+				return false
+			}
+			if renamed > state {
+				break
+			}
+		}
+	}
+	return true
+}
+
+func (s *symmetryIterator) Error() error {
+	return s.iter.Error()
+}
+
+func (s *symmetryIterator) Current() *TestCase {
+	return s.iter.Current()
+}
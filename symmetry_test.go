@@ -0,0 +1,61 @@
+package paxos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymmetryReduction(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaders(1, 2, 3),
+		WithSteps(3),
+	}
+	all := collectCases(t, opts...)
+	reduced := collectCases(t, append(opts, WithSymmetryReduction())...)
+	require.Len(t, all, 20*20*20)
+	// every schedule has at most 6 renamings, fixed by at least an identity
+	require.Less(t, len(reduced), len(all)/4)
+	require.Greater(t, len(reduced), len(all)/6)
+
+	// asymmetric leaders: only 2 and 3 are symmetric in the partitions
+	// {1, 2, 3} and {1} {2, 3}, but 1 is the only leader
+	asymmetric := []GenOption{
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithReplicas(1, 2, 3),
+		WithLeaders(1),
+		WithSteps(3),
+	}
+	require.Equal(t,
+		collectCases(t, asymmetric...),
+		collectCases(t, append(asymmetric, WithSymmetryReduction())...),
+	)
+}
+
+// splitLeaders fails if leaders of the same step can't reach each other.
+// Result doesn't depend on the ids of the replicas.
+func splitLeaders(tc *TestCase) error {
+	for network, actions := range tc.Steps() {
+		for a := range actions {
+			for b := range actions {
+				if a != b && actions.IsLeader(a) && actions.IsLeader(b) && !network.Reachable(a, b) {
+					return fmt.Errorf("leaders %d and %d can't reach each other", a, b)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestSymmetryReductionRunner(t *testing.T) {
+	RunExpectFailure(t, splitLeaders,
+		WithReplicas(1, 2, 3),
+		WithAllPartitions(0),
+		WithLeaderSets(2, 1, 2, 3),
+		WithSymmetryReduction(),
+		WithSteps(2),
+	)
+}
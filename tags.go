@@ -0,0 +1,170 @@
+package paxos
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Names of the tags that are added with WithDefaultTags.
+const (
+	// TagHasCrash is a schedule where at least one replica crashes.
+	TagHasCrash = "has-crash"
+	// TagDuelingLeaders is a schedule where at least two different replicas lead.
+	TagDuelingLeaders = "dueling-leaders"
+	// TagNeverHeals is a schedule where network is never fully connected.
+	TagNeverHeals = "never-heals"
+)
+
+type tag struct {
+	name  string
+	match func([]Step) bool
+	// number of executed and failed test cases with the tag
+	executed, failed int
+}
+
+// WithTag tags test cases which schedule matches, for example schedules
+// where leader changes in every step. Run reports number of executed and
+// failed test cases for every tag, and test cases can be filtered by tags
+// with WithTagFilter.
+func WithTag(name string, match func(steps []Step) bool) GenOption {
+	return func(g *Generator) error {
+		if len(name) == 0 {
+			return errors.New("name of the tag is empty")
+		}
+		if match == nil {
+			return fmt.Errorf("tag %q matches nothing", name)
+		}
+		for _, t := range g.tags {
+			if t.name == name {
+				return fmt.Errorf("tag %q is already configured", name)
+			}
+		}
+		g.tags = append(g.tags, &tag{name: name, match: match})
+		return nil
+	}
+}
+
+// WithDefaultTags adds TagHasCrash, TagDuelingLeaders and TagNeverHeals.
+func WithDefaultTags() GenOption {
+	return func(g *Generator) error {
+		for _, opt := range []GenOption{
+			WithTag(TagHasCrash, hasCrash),
+			WithTag(TagDuelingLeaders, duelingLeaders),
+			WithTag(TagNeverHeals, func(steps []Step) bool {
+				connected := FullyConnected(g.nodes...)
+				for _, step := range steps {
+					if equalPartitions(step.Network, connected) {
+						return false
+					}
+				}
+				return true
+			}),
+		} {
+			if err := opt(g); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithTagFilter generates only test cases that have at least one of the tags.
+// Filter is evaluated together with constraints, see WithConstraint.
+func WithTagFilter(tags ...string) GenOption {
+	return func(g *Generator) error {
+		if len(tags) == 0 {
+			return errors.New("tag filter is empty")
+		}
+		g.tagFilter = append(g.tagFilter, tags...)
+		return nil
+	}
+}
+
+// filterTags adds a constraint for the tag filter, once all tags are configured.
+func (g *Generator) filterTags() error {
+	if len(g.tagFilter) == 0 {
+		return nil
+	}
+	var filter []*tag
+	for _, name := range g.tagFilter {
+		t := g.tag(name)
+		if t == nil {
+			return fmt.Errorf("tag %q in the filter is not configured", name)
+		}
+		filter = append(filter, t)
+	}
+	g.constraints = append(g.constraints, func(steps []Step) bool {
+		for _, t := range filter {
+			if t.match(steps) {
+				return true
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func (g *Generator) tag(name string) *tag {
+	for _, t := range g.tags {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Tags returns tags of the test case, in the order they were configured.
+func (t *TestCase) Tags() []string {
+	var rst []string
+	steps := t.Schedule()
+	for _, tag := range t.gen.tags {
+		if tag.match(steps) {
+			rst = append(rst, tag.name)
+		}
+	}
+	return rst
+}
+
+// count reports that the test case was executed and whether it failed.
+func (g *Generator) count(tc *TestCase, failed bool) {
+	if len(g.tags) == 0 {
+		return
+	}
+	tags := tc.Tags()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, name := range tags {
+		t := g.tag(name)
+		t.executed++
+		if failed {
+			t.failed++
+		}
+	}
+}
+
+func hasCrash(steps []Step) bool {
+	for _, step := range steps {
+		for _, action := range step.Actions {
+			if action&ActionCrash > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func duelingLeaders(steps []Step) bool {
+	leader := 0
+	for _, step := range steps {
+		for id := range step.Actions {
+			if !step.Actions.IsLeader(id) {
+				continue
+			}
+			if leader != 0 && leader != id {
+				return true
+			}
+			leader = id
+		}
+	}
+	return false
+}
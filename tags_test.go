@@ -0,0 +1,68 @@
+package paxos
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	opts := []GenOption{
+		WithReplicas(1, 2, 3),
+		WithExplicitPartitions([][]int{{1, 2, 3}}, [][]int{{1}, {2, 3}}),
+		WithLeaders(1, 2),
+		WithCrashes(3),
+		WithSteps(2),
+		WithDefaultTags(),
+		WithTag("leader in the first step", func(steps []Step) bool {
+			return steps[0].Actions.IsLeader(1) || steps[0].Actions.IsLeader(2)
+		}),
+	}
+	gen, err := NewGen(opts...)
+	require.NoError(t, err)
+	counts := map[string]int{}
+	for tc := range gen.All() {
+		tags := tc.Tags()
+		for _, tag := range tags {
+			counts[tag]++
+		}
+		var (
+			steps   = gen.appendSteps(nil, tc.states)
+			crash   = steps[0].Actions.IsCrashed(3) || steps[1].Actions.IsCrashed(3)
+			dueling = (steps[0].Actions.IsLeader(1) || steps[1].Actions.IsLeader(1)) &&
+				(steps[0].Actions.IsLeader(2) || steps[1].Actions.IsLeader(2))
+			heals = steps[0].Network.Reachable(1, 2) || steps[1].Network.Reachable(1, 2)
+		)
+		require.Equal(t, crash, slices.Contains(tags, TagHasCrash), "%s", tc)
+		require.Equal(t, dueling, slices.Contains(tags, TagDuelingLeaders), "%s", tc)
+		require.Equal(t, !heals, slices.Contains(tags, TagNeverHeals), "%s", tc)
+		gen.Feedback(tc)
+	}
+	require.NotZero(t, counts[TagHasCrash])
+	require.NotZero(t, counts[TagDuelingLeaders])
+	require.NotZero(t, counts[TagNeverHeals])
+	stats := gen.Stats()
+	require.Len(t, stats.Tags, 4)
+	for _, tag := range stats.Tags {
+		require.Equal(t, counts[tag.Name], tag.Executed, tag.Name)
+		require.Zero(t, tag.Failed)
+	}
+	require.Contains(t, stats.String(), "tag has-crash: executed")
+
+	filtered, err := NewGen(append(opts, WithTagFilter(TagHasCrash, TagNeverHeals))...)
+	require.NoError(t, err)
+	cnt := 0
+	for tc := range filtered.All() {
+		tags := tc.Tags()
+		require.True(t, slices.Contains(tags, TagHasCrash) || slices.Contains(tags, TagNeverHeals), "%s", tc)
+		cnt++
+	}
+	require.NotZero(t, cnt)
+	require.Less(t, cnt, gen.Count())
+
+	_, err = NewGen(append(opts, WithTagFilter("unknown"))...)
+	require.Error(t, err)
+	_, err = NewGen(append(opts, WithDefaultTags())...)
+	require.Error(t, err)
+}
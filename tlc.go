@@ -0,0 +1,232 @@
+package paxos
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TLCVariables are names of the variables in the TLC trace.
+type TLCVariables struct {
+	// Network is a set of groups of replicas that can reach each other,
+	// e.g. {{1, 2}, {3}}, or a set of one way links, e.g. {<<1, 2>>, <<2, 1>>}.
+	// "network" if empty.
+	Network string
+	// Leaders is a set of replicas that propose in the state, e.g. {1}.
+	// "leaders" if empty.
+	Leaders string
+}
+
+var tlcState = regexp.MustCompile(`^State \d+:`)
+
+// ParseTLCTrace converts an error trace that is printed by TLC into a scenario.
+// Every state of the trace is a step of the scenario, other variables are ignored.
+// Network and leaders variables must be in every state.
+func ParseTLCTrace(r io.Reader, replicas []int, vars TLCVariables) (*Scenario, error) {
+	if vars.Network == "" {
+		vars.Network = "network"
+	}
+	if vars.Leaders == "" {
+		vars.Leaders = "leaders"
+	}
+	var (
+		states [][]string
+		// state ends with an empty line
+		open    bool
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case tlcState.MatchString(line):
+			states = append(states, nil)
+			open = true
+		case len(line) == 0:
+			open = false
+		case !open:
+		case strings.HasPrefix(line, "/\\"):
+			last := states[len(states)-1]
+			states[len(states)-1] = append(last, strings.TrimSpace(strings.TrimPrefix(line, "/\\")))
+		case len(states[len(states)-1]) == 0:
+			// state with a single variable is printed without conjunction
+			states[len(states)-1] = []string{line}
+		default:
+			// continuation of the multiline value
+			last := states[len(states)-1]
+			last[len(last)-1] += " " + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, fmt.Errorf("trace has no states")
+	}
+	scenario := NewScenario(replicas...)
+	for i, state := range states {
+		step := scenario.Step()
+		var network, leaders bool
+		for _, assignment := range state {
+			parts := strings.SplitN(assignment, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("state %d: invalid assignment %q", i+1, assignment)
+			}
+			name := strings.TrimSpace(parts[0])
+			if name != vars.Network && name != vars.Leaders {
+				continue
+			}
+			value, err := parseTLA(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("state %d: variable %s: %w", i+1, name, err)
+			}
+			if name == vars.Network {
+				partition, err := value.partition()
+				if err != nil {
+					return nil, fmt.Errorf("state %d: variable %s: %w", i+1, name, err)
+				}
+				step.Network(partition)
+				network = true
+				continue
+			}
+			ids, err := value.ints()
+			if err != nil {
+				return nil, fmt.Errorf("state %d: variable %s: %w", i+1, name, err)
+			}
+			step.Leader(ids...)
+			leaders = true
+		}
+		if !network {
+			return nil, fmt.Errorf("state %d: variable %s is missing", i+1, vars.Network)
+		}
+		if !leaders {
+			return nil, fmt.Errorf("state %d: variable %s is missing", i+1, vars.Leaders)
+		}
+	}
+	return scenario, nil
+}
+
+// tlaValue is an integer, a set or a sequence.
+type tlaValue struct {
+	num   int
+	items []tlaValue
+	set   bool
+	seq   bool
+}
+
+func (v tlaValue) ints() ([]int, error) {
+	if !v.set && !v.seq {
+		return []int{v.num}, nil
+	}
+	rst := make([]int, 0, len(v.items))
+	for _, item := range v.items {
+		if item.set || item.seq {
+			return nil, fmt.Errorf("expected a set of integers")
+		}
+		rst = append(rst, item.num)
+	}
+	return rst, nil
+}
+
+func (v tlaValue) partition() (Partition, error) {
+	if !v.set {
+		return nil, fmt.Errorf("expected a set")
+	}
+	network := Partition{}
+	for _, item := range v.items {
+		ids, err := item.ints()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case item.set:
+			for i := range ids {
+				for _, to := range ids[i+1:] {
+					network.Add(ids[i], to)
+				}
+			}
+		case item.seq && len(ids) == 2:
+			network.AddOneWay(ids[0], ids[1])
+		default:
+			return nil, fmt.Errorf("expected a set of groups or links")
+		}
+	}
+	return network, nil
+}
+
+func parseTLA(s string) (tlaValue, error) {
+	p := tlaParser{s: strings.TrimSpace(s)}
+	v, err := p.value()
+	if err != nil {
+		return v, err
+	}
+	if p.skip(); p.pos != len(p.s) {
+		return v, fmt.Errorf("unexpected %q", p.s[p.pos:])
+	}
+	return v, nil
+}
+
+type tlaParser struct {
+	s   string
+	pos int
+}
+
+func (p *tlaParser) skip() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *tlaParser) consume(token string) bool {
+	p.skip()
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *tlaParser) value() (tlaValue, error) {
+	switch {
+	case p.consume("{"):
+		items, err := p.items("}")
+		return tlaValue{items: items, set: true}, err
+	case p.consume("<<"):
+		items, err := p.items(">>")
+		return tlaValue{items: items, seq: true}, err
+	}
+	start := p.pos
+	if p.pos < len(p.s) && p.s[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	num, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return tlaValue{}, fmt.Errorf("expected an integer at %q", p.s[start:])
+	}
+	return tlaValue{num: num}, nil
+}
+
+func (p *tlaParser) items(end string) ([]tlaValue, error) {
+	var items []tlaValue
+	if p.consume(end) {
+		return items, nil
+	}
+	for {
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.consume(end) {
+			return items, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected %q or \",\" at %q", end, p.s[p.pos:])
+		}
+	}
+}
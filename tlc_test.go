@@ -0,0 +1,72 @@
+package paxos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLCTrace(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "tlc.trace"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	scenario, err := ParseTLCTrace(f, []int{1, 2, 3, 4, 5}, TLCVariables{})
+	require.NoError(t, err)
+	tc, err := scenario.TestCase()
+	require.NoError(t, err)
+
+	network, actions := tc.Next()
+	require.Equal(t, groupPartition([][]int{{1, 2, 3}, {4, 5}}), network)
+	require.Equal(t, Actions{1: ActionLead}, actions)
+
+	network, actions = tc.Next()
+	require.Equal(t, groupPartition([][]int{{1, 2}, {3, 4, 5}}), network)
+	require.Equal(t, Actions{3: ActionLead}, actions)
+
+	network, actions = tc.Next()
+	require.True(t, network.Reachable(3, 1))
+	require.False(t, network.Reachable(1, 3))
+	require.Empty(t, actions)
+
+	network, _ = tc.Next()
+	require.Equal(t, groupPartition([][]int{{1, 2, 3, 4, 5}}), network)
+
+	network, _ = tc.Next()
+	require.Nil(t, network)
+
+	RunScenarios(t, Simulate(paxosFactory, WithValidation()), scenario)
+}
+
+func TestParseTLCTraceVariables(t *testing.T) {
+	trace := `State 1: <Initial predicate>
+/\ net = {{1}, {2, 3}}
+/\ proposer = 2
+`
+	scenario, err := ParseTLCTrace(strings.NewReader(trace), []int{1, 2, 3},
+		TLCVariables{Network: "net", Leaders: "proposer"})
+	require.NoError(t, err)
+	tc, err := scenario.TestCase()
+	require.NoError(t, err)
+	network, actions := tc.Next()
+	require.Equal(t, groupPartition([][]int{{2, 3}}), network)
+	require.Equal(t, Actions{2: ActionLead}, actions)
+}
+
+func TestParseTLCTraceErrors(t *testing.T) {
+	for _, trace := range []string{
+		"",
+		"State 1: <Initial predicate>\n/\\ network = {{1, 2}\n",
+		"State 1: <Initial predicate>\n/\\ network = {1, 2}\n",
+		"State 1: <Initial predicate>\n/\\ leaders = {a}\n",
+		"State 1: <Initial predicate>\n/\\ leaders = {1}\n",
+		"State 1: <Initial predicate>\n/\\ network = {{1, 2, 3}}\n",
+		"State 1: <Initial predicate>\n/\\ network = {{1, 2, 3}}\n/\\ leaders = {1}\n\nState 2: <Next>\n/\\ leaders = {}\n",
+	} {
+		_, err := ParseTLCTrace(strings.NewReader(trace), []int{1, 2, 3}, TLCVariables{})
+		require.Error(t, err, trace)
+	}
+}
@@ -0,0 +1,94 @@
+package paxos
+
+import "fmt"
+
+// Names of the canonical fault topologies. See Topology.
+const (
+	TopologyFullMesh       = "full mesh"
+	TopologySymmetricSplit = "symmetric split"
+	TopologyIsolatedLeader = "isolated leader"
+	TopologyRingWithCut    = "ring with one cut"
+	TopologyBridgeNode     = "bridge node"
+)
+
+var topologies = []struct {
+	name  string
+	build func(nodes []int) Partition
+}{
+	{TopologyFullMesh, func(nodes []int) Partition {
+		return FullyConnected(nodes...)
+	}},
+	// halves of the replicas can't reach each other, the second half is
+	// larger by one replica if number of replicas is odd
+	{TopologySymmetricSplit, func(nodes []int) Partition {
+		return groupPartition([][]int{nodes[:len(nodes)/2], nodes[len(nodes)/2:]})
+	}},
+	// first replica is expected to be a leader
+	{TopologyIsolatedLeader, func(nodes []int) Partition {
+		return FullyConnected(nodes...).IsolateNode(nodes[0])
+	}},
+	// every replica can reach only its neighbours, and the ring is cut
+	// between the last and the first replicas
+	{TopologyRingWithCut, func(nodes []int) Partition {
+		ring := Partition{}
+		for i := 1; i < len(nodes); i++ {
+			ring.Add(nodes[i-1], nodes[i])
+		}
+		return ring
+	}},
+	// first replica can reach every other replica, and the rest are split
+	// into halves that can reach each other only through the first replica
+	{TopologyBridgeNode, func(nodes []int) Partition {
+		rest := nodes[1:]
+		bridge := groupPartition([][]int{rest[:len(rest)/2], rest[len(rest)/2:]})
+		for _, id := range rest {
+			bridge.Add(nodes[0], id)
+		}
+		return bridge
+	}},
+}
+
+// Topologies returns names of the canonical fault topologies.
+func Topologies() []string {
+	names := make([]string, len(topologies))
+	for i, topology := range topologies {
+		names[i] = topology.name
+	}
+	return names
+}
+
+// Topology returns a canonical fault topology of the replicas.
+func Topology(name string, nodes ...int) (Partition, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("topology %q requires at least one replica", name)
+	}
+	for _, topology := range topologies {
+		if topology.name == name {
+			return topology.build(nodes), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown topology %q", name)
+}
+
+// WithTopologies adds canonical fault topologies of the configured replicas,
+// every topology is named after itself in the description of the test case.
+// All topologies are added if names are empty. Must be configured after replicas.
+func WithTopologies(names ...string) GenOption {
+	return func(g *Generator) error {
+		if g.nodes == nil {
+			return fmt.Errorf("replicas must be configured earlier than topologies")
+		}
+		if len(names) == 0 {
+			names = Topologies()
+		}
+		for _, name := range names {
+			network, err := Topology(name, g.nodes...)
+			if err != nil {
+				return err
+			}
+			g.partitions = append(g.partitions, network)
+			g.names = append(g.names, pendingName{name: name, network: network})
+		}
+		return nil
+	}
+}
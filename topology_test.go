@@ -0,0 +1,51 @@
+package paxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologies(t *testing.T) {
+	nodes := []int{1, 2, 3, 4, 5}
+	for _, tc := range []struct {
+		name   string
+		expect Partition
+	}{
+		{TopologyFullMesh, FullyConnected(nodes...)},
+		{TopologySymmetricSplit, groupPartition([][]int{{1, 2}, {3, 4, 5}})},
+		{TopologyIsolatedLeader, groupPartition([][]int{{1}, {2, 3, 4, 5}})},
+		{TopologyRingWithCut, groupPartition([][]int{{1, 2}, {2, 3}, {3, 4}, {4, 5}})},
+		{TopologyBridgeNode, groupPartition([][]int{{1, 2, 3}, {1, 4, 5}})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			network, err := Topology(tc.name, nodes...)
+			require.NoError(t, err)
+			require.True(t, equalPartitions(tc.expect, network), "%s", network)
+		})
+	}
+	_, err := Topology("star", nodes...)
+	require.Error(t, err)
+
+	gen, err := NewGen(WithReplicas(nodes...), WithTopologies(), WithLeaders(1), WithSteps(1))
+	require.NoError(t, err)
+	require.Len(t, gen.partitions, len(Topologies()))
+	var descriptions []string
+	for tc := range gen.All() {
+		tc.Next()
+		descriptions = append(descriptions, tc.String())
+	}
+	require.Contains(t, descriptions, "step 1: Routes(bridge node) Cluster(leader=1)\n")
+
+	_, err = NewGen(WithTopologies(TopologyFullMesh), WithReplicas(nodes...))
+	require.Error(t, err)
+}
+
+func TestPaxosTopologies(t *testing.T) {
+	Run(t, Simulate(paxosFactory, WithValidation()), runFlags(),
+		WithReplicas(1, 2, 3, 4, 5),
+		WithTopologies(),
+		WithLeaders(1, 2),
+		WithSteps(4),
+	)
+}
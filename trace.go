@@ -0,0 +1,41 @@
+package paxos
+
+import "fmt"
+
+// WithTracing records every delivered message in the trace of the test case,
+// see TestCase.Trace. Run writes the trace of the failed test case to
+// the artifacts of the failure. SimulateShared records only the steps
+// after the shared prefix.
+func WithTracing() ClusterOption {
+	return func(c *Cluster) error {
+		c.tracing = true
+		return nil
+	}
+}
+
+// Tracef appends a line to the trace of the test case. Trace is reset
+// by Reset and when the test case is executed by Simulate.
+func (t *TestCase) Tracef(format string, args ...any) {
+	t.trace = append(t.trace, fmt.Sprintf(format, args...))
+}
+
+// Trace returns lines that were appended to the trace of the test case.
+func (t *TestCase) Trace() []string {
+	return t.trace
+}
+
+// traceDelivery records the message if tracing is enabled.
+func (c *Cluster) traceDelivery(msg Message) {
+	if c.tracing {
+		c.traced = append(c.traced, msg)
+	}
+}
+
+// flushTrace appends messages that were delivered in the step to the trace
+// of the test case.
+func (c *Cluster) flushTrace(tc *TestCase) {
+	for _, msg := range c.traced {
+		tc.Tracef("step %d: %s", tc.step, msg)
+	}
+	c.traced = c.traced[:0]
+}
@@ -0,0 +1,154 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	paxos "github.com/dshulyak/testing-paxos"
+)
+
+type NetworkOption func(n *Network) error
+
+// WithDelay delays every message by a random duration in range [min, max].
+func WithDelay(min, max time.Duration) NetworkOption {
+	return func(n *Network) error {
+		if min < 0 || max < min {
+			return fmt.Errorf("invalid delay range [%v, %v]", min, max)
+		}
+		n.minDelay = min
+		n.maxDelay = max
+		return nil
+	}
+}
+
+// WithDropRate drops every message with probability rate.
+func WithDropRate(rate float64) NetworkOption {
+	return func(n *Network) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("drop rate %v must be in range of [0, 1]", rate)
+		}
+		n.dropRate = rate
+		return nil
+	}
+}
+
+// WithSeed configures seed for the delays and drops.
+func WithSeed(seed int64) NetworkOption {
+	return func(n *Network) error {
+		n.rng = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// NewNetwork creates in-memory network.
+func NewNetwork(opts ...NetworkOption) (*Network, error) {
+	n := &Network{
+		members: map[int]*Chan{},
+	}
+	for _, opt := range opts {
+		if err := opt(n); err != nil {
+			return nil, err
+		}
+	}
+	if n.rng == nil {
+		n.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return n, nil
+}
+
+// Network is an in-memory asynchronous network. Unlike the lock-step
+// simulator every message is delivered by a separate goroutine after
+// a random delay, or dropped.
+type Network struct {
+	minDelay, maxDelay time.Duration
+	dropRate           float64
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	members map[int]*Chan
+}
+
+// Join creates a transport for replica with id.
+func (n *Network) Join(id int) *Chan {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	c := &Chan{
+		id:       id,
+		net:      n,
+		incoming: make(chan paxos.Message, incomingBuffer),
+		closed:   make(chan struct{}),
+	}
+	n.members[id] = c
+	return c
+}
+
+func (n *Network) send(m paxos.Message) error {
+	n.mu.Lock()
+	dst, exist := n.members[m.To]
+	drop := n.dropRate > 0 && n.rng.Float64() < n.dropRate
+	delay := n.minDelay
+	if n.maxDelay > n.minDelay {
+		delay += time.Duration(n.rng.Int63n(int64(n.maxDelay - n.minDelay)))
+	}
+	n.mu.Unlock()
+	if !exist {
+		return fmt.Errorf("replica %d is not in the network", m.To)
+	}
+	if drop {
+		return nil
+	}
+	deliver := func() {
+		select {
+		case dst.incoming <- m:
+		case <-dst.closed:
+		}
+	}
+	if delay == 0 {
+		go deliver()
+	} else {
+		time.AfterFunc(delay, deliver)
+	}
+	return nil
+}
+
+func (n *Network) leave(id int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.members, id)
+}
+
+// Chan is a transport of a single replica in the in-memory network.
+type Chan struct {
+	id  int
+	net *Network
+
+	incoming chan paxos.Message
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *Chan) Send(m paxos.Message) error {
+	select {
+	case <-c.closed:
+		return errors.New("transport is closed")
+	default:
+	}
+	return c.net.send(m)
+}
+
+func (c *Chan) Receive() <-chan paxos.Message {
+	return c.incoming
+}
+
+// Close removes replica from the network. Messages in flight are dropped.
+func (c *Chan) Close() error {
+	c.once.Do(func() {
+		c.net.leave(c.id)
+		close(c.closed)
+	})
+	return nil
+}
@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	paxos "github.com/dshulyak/testing-paxos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanNetwork(t *testing.T) {
+	network, err := NewNetwork(
+		WithDelay(0, 5*time.Millisecond),
+		WithDropRate(0.2),
+		WithSeed(1),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	nodes := []int{1, 2, 3, 4, 5}
+	replicas := map[int]*Replica{}
+	for _, id := range nodes {
+		node, err := paxos.NewPaxos(id, nodes)
+		require.NoError(t, err)
+		tr := network.Join(id)
+		defer tr.Close()
+		replica := NewReplica(node, tr)
+		replicas[id] = replica
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replica.Run(ctx)
+		}()
+	}
+
+	// proposals are retried because messages may be dropped
+	for _, id := range []int{1, 3} {
+		id := id
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(20 * time.Millisecond)
+			defer ticker.Stop()
+			for replicas[id].Learned() == nil {
+				if replicas[id].Propose(ctx, paxos.Value{byte(id)}) != nil {
+					return
+				}
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return replicas[1].Learned() != nil || replicas[3].Learned() != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	first, second := replicas[1].Learned(), replicas[3].Learned()
+	if first != nil && second != nil {
+		require.Equal(t, first, second)
+	}
+}
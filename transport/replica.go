@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"context"
+	"sync"
+
+	paxos "github.com/dshulyak/testing-paxos"
+)
+
+// NewReplica creates a replica that exchanges messages of the node using transport.
+func NewReplica(node paxos.Node, tr Transport) *Replica {
+	return &Replica{
+		node:      node,
+		tr:        tr,
+		proposals: make(chan paxos.Value),
+	}
+}
+
+// Replica drives a single node from one goroutine. Node is not accessed
+// concurrently.
+type Replica struct {
+	node paxos.Node
+	tr   Transport
+
+	proposals chan paxos.Value
+
+	mu      sync.Mutex
+	learned paxos.Value
+}
+
+// Propose submits a value to the node. Blocks until the value is consumed
+// by Run or the context is done.
+func (r *Replica) Propose(ctx context.Context, value paxos.Value) error {
+	select {
+	case r.proposals <- value:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Learned returns a value learned by the node.
+func (r *Replica) Learned() paxos.Value {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.learned
+}
+
+// Run processes proposals and incoming messages until context is done.
+// Messages that can't be sent are dropped.
+func (r *Replica) Run(ctx context.Context) error {
+	for {
+		var outbox []paxos.Message
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case value := <-r.proposals:
+			r.node.Propose(value)
+			outbox = r.node.Step(paxos.Message{})
+		case m := <-r.tr.Receive():
+			outbox = r.node.Step(m)
+		}
+		for _, m := range outbox {
+			_ = r.tr.Send(m)
+		}
+		r.mu.Lock()
+		r.learned = r.node.Learned()
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,241 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	paxos "github.com/dshulyak/testing-paxos"
+)
+
+const (
+	lengthWidth = 4
+	// messages larger than that are considered corrupted
+	maxMessageSize = 1 << 20
+
+	incomingBuffer = 64
+)
+
+var errClosed = errors.New("transport is closed")
+
+// Listen creates a TCP transport for replica with id that accepts connections on addr.
+func Listen(id int, addr string) (*TCP, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &TCP{
+		id:       id,
+		listener: listener,
+		peers:    map[int]string{},
+		conns:    map[int]*outgoing{},
+		accepted: map[net.Conn]struct{}{},
+		incoming: make(chan paxos.Message, incomingBuffer),
+		closed:   make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.accept()
+	return t, nil
+}
+
+// TCP sends every message as a length prefixed Message.Marshal encoding.
+// Connection to the peer is established lazily on the first message and
+// is re-established after a failure.
+type TCP struct {
+	id       int
+	listener net.Listener
+
+	mu       sync.Mutex
+	peers    map[int]string
+	conns    map[int]*outgoing
+	accepted map[net.Conn]struct{}
+
+	incoming chan paxos.Message
+	closed   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Addr returns an address of the listener.
+func (t *TCP) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// Connect adds addresses of other replicas.
+func (t *TCP) Connect(peers map[int]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, addr := range peers {
+		if id != t.id {
+			t.peers[id] = addr
+		}
+	}
+}
+
+// Send writes message to the connection with m.To.
+func (t *TCP) Send(m paxos.Message) error {
+	buf, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, lengthWidth+len(buf))
+	binary.LittleEndian.PutUint32(frame, uint32(len(buf)))
+	copy(frame[lengthWidth:], buf)
+
+	t.mu.Lock()
+	out, addr, err := t.outgoing(m.To)
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	conn, err := t.dial(out, addr)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.mu.Lock()
+		if out.conn == conn {
+			out.conn = nil
+		}
+		t.mu.Unlock()
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// outgoing is a connection to the peer. Slow peer doesn't block sends
+// to other peers, since dial and write are serialized per connection.
+type outgoing struct {
+	// mu is held for dial and write.
+	mu sync.Mutex
+	// conn is protected by the mutex of the transport, so that Close
+	// doesn't wait for writes in progress.
+	conn net.Conn
+}
+
+// outgoing returns the connection to the peer with id and its address.
+// Must be called with t.mu held.
+func (t *TCP) outgoing(id int) (*outgoing, string, error) {
+	select {
+	case <-t.closed:
+		return nil, "", errClosed
+	default:
+	}
+	addr, exist := t.peers[id]
+	if !exist {
+		return nil, "", fmt.Errorf("address of the replica %d is unknown", id)
+	}
+	out, exist := t.conns[id]
+	if !exist {
+		out = &outgoing{}
+		t.conns[id] = out
+	}
+	return out, addr, nil
+}
+
+// dial returns an established connection, or connects to addr.
+// Must be called with out.mu held.
+func (t *TCP) dial(out *outgoing, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	conn := out.conn
+	t.mu.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.closed:
+		conn.Close()
+		return nil, errClosed
+	default:
+	}
+	out.conn = conn
+	return conn, nil
+}
+
+// Receive returns a channel with messages from all peers.
+func (t *TCP) Receive() <-chan paxos.Message {
+	return t.incoming
+}
+
+func (t *TCP) accept() {
+	defer t.wg.Done()
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		t.accepted[conn] = struct{}{}
+		t.mu.Unlock()
+
+		t.wg.Add(1)
+		go t.read(conn)
+	}
+}
+
+func (t *TCP) read(conn net.Conn) {
+	defer t.wg.Done()
+	defer func() {
+		t.mu.Lock()
+		delete(t.accepted, conn)
+		t.mu.Unlock()
+		conn.Close()
+	}()
+	var (
+		reader = bufio.NewReader(conn)
+		header [lengthWidth]byte
+	)
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return
+		}
+		lth := binary.LittleEndian.Uint32(header[:])
+		if lth > maxMessageSize {
+			return
+		}
+		buf := make([]byte, lth)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return
+		}
+		var m paxos.Message
+		if err := m.Unmarshal(buf); err != nil || m.To != t.id {
+			return
+		}
+		select {
+		case t.incoming <- m:
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+// Close stops the listener and closes all connections.
+func (t *TCP) Close() error {
+	t.mu.Lock()
+	close(t.closed)
+	err := t.listener.Close()
+	for id, out := range t.conns {
+		if out.conn != nil {
+			out.conn.Close()
+		}
+		delete(t.conns, id)
+	}
+	for conn := range t.accepted {
+		conn.Close()
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	return err
+}
@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	paxos "github.com/dshulyak/testing-paxos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPCluster(t *testing.T) {
+	nodes := []int{1, 2, 3}
+	peers := map[int]string{}
+	transports := map[int]*TCP{}
+	for _, id := range nodes {
+		tr, err := Listen(id, "127.0.0.1:0")
+		require.NoError(t, err)
+		transports[id] = tr
+		peers[id] = tr.Addr()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+		for _, tr := range transports {
+			require.NoError(t, tr.Close())
+		}
+	}()
+
+	replicas := map[int]*Replica{}
+	for _, id := range nodes {
+		node, err := paxos.NewPaxos(id, nodes)
+		require.NoError(t, err)
+		transports[id].Connect(peers)
+		replica := NewReplica(node, transports[id])
+		replicas[id] = replica
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replica.Run(ctx)
+		}()
+	}
+
+	require.NoError(t, replicas[1].Propose(ctx, paxos.Value{1}))
+	require.Eventually(t, func() bool {
+		return replicas[1].Learned() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, paxos.Value{1}, replicas[1].Learned())
+}
+
+func TestTCPSlowPeer(t *testing.T) {
+	// slow peer accepts connections but never reads
+	slow, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer slow.Close()
+	go func() {
+		for {
+			conn, err := slow.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tr, err := Listen(1, "127.0.0.1:0")
+	require.NoError(t, err)
+	peer, err := Listen(2, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer peer.Close()
+	tr.Connect(map[int]string{2: peer.Addr(), 3: slow.Addr().String()})
+
+	var sent atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value := make(paxos.Value, maxMessageSize/2)
+		for tr.Send(paxos.Message{From: 1, To: 3, Value: value}) == nil {
+			sent.Add(1)
+		}
+	}()
+	// wait until the write to the slow peer is blocked
+	last := int64(-1)
+	require.Eventually(t, func() bool {
+		current := sent.Load()
+		blocked := current == last
+		last = current
+		return blocked
+	}, 10*time.Second, 100*time.Millisecond)
+
+	go tr.Send(paxos.Message{From: 1, To: 2})
+	select {
+	case m := <-peer.Receive():
+		require.Equal(t, 2, m.To)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "message to the peer is blocked by the slow peer")
+	}
+	require.NoError(t, tr.Close())
+	<-done
+}
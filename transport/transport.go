@@ -0,0 +1,18 @@
+package transport
+
+import paxos "github.com/dshulyak/testing-paxos"
+
+// Transport delivers messages between replicas. Delivery is not guaranteed,
+// message may be lost, delayed or reordered.
+type Transport interface {
+	// Send message to the m.To replica.
+	Send(paxos.Message) error
+	// Receive returns a channel with messages for this replica.
+	Receive() <-chan paxos.Message
+	Close() error
+}
+
+var (
+	_ Transport = (*TCP)(nil)
+	_ Transport = (*Chan)(nil)
+)
@@ -0,0 +1,155 @@
+package paxos
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Storage persists state of the replica. Save must be durable
+// when it returns.
+type Storage interface {
+	Save([]byte) error
+	// Load returns the last saved state or nil if nothing was saved.
+	Load() ([]byte, error)
+}
+
+// OpenWAL opens an append-only file with the same crc framing
+// as the replay file.
+func OpenWAL(path string) (*WAL, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL{f: f}, nil
+}
+
+// WAL is a Storage that appends every state as a new record.
+type WAL struct {
+	mu sync.Mutex
+	f  *os.File
+
+	metaBuf [metaWidth]byte
+}
+
+func (w *WAL) Save(state []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if err := writeRecord(w.f, &w.metaBuf, state); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// Load reads all records and returns the last one. Incomplete record
+// at the end of the file (write was interrupted by a crash) is ignored
+// and truncated.
+func (w *WAL) Load() ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var (
+		reader = bufio.NewReader(w.f)
+		last   []byte
+		offset int64
+	)
+	for {
+		buf, err := readRecord(reader, &w.metaBuf)
+		if errors.Is(err, io.EOF) {
+			return last, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errCorrupted) {
+			// torn write
+			return last, w.f.Truncate(offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = buf
+		offset += int64(metaWidth + len(buf))
+	}
+}
+
+func (w *WAL) Close() error {
+	return w.f.Close()
+}
+
+// NewDurablePaxos restores persisted state of the p from storage.
+func NewDurablePaxos(p *Paxos, storage Storage) (*DurablePaxos, error) {
+	state, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		if err := p.UnmarshalBinary(state); err != nil {
+			return nil, err
+		}
+	}
+	return &DurablePaxos{Paxos: p, storage: storage, state: state}, nil
+}
+
+// DurablePaxos is an acceptor that saves ballot and vote before
+// any message is sent.
+type DurablePaxos struct {
+	*Paxos
+	storage Storage
+
+	// last saved state
+	state []byte
+	err   error
+}
+
+// Err returns the first error from the storage or from the replica.
+// Replica must not be used after the storage failed.
+func (d *DurablePaxos) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.Paxos.Err()
+}
+
+func (d *DurablePaxos) Propose(value Value) {
+	if d.err != nil {
+		return
+	}
+	d.Paxos.Propose(value)
+	d.persist()
+}
+
+func (d *DurablePaxos) Step(m Message) []Message {
+	if d.err != nil {
+		return nil
+	}
+	if m.Type != MessageEmpty {
+		d.Paxos.Next(m)
+		d.persist()
+	}
+	if d.err != nil {
+		return nil
+	}
+	return d.Paxos.Step(Message{})
+}
+
+func (d *DurablePaxos) persist() {
+	state, err := d.Paxos.MarshalBinary()
+	if err != nil {
+		d.err = err
+		return
+	}
+	if bytes.Equal(state, d.state) {
+		return
+	}
+	if err := d.storage.Save(state); err != nil {
+		d.err = err
+		return
+	}
+	d.state = state
+}
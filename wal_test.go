@@ -0,0 +1,69 @@
+package paxos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurablePaxosRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.wal")
+
+	open := func() (*DurablePaxos, *WAL) {
+		wal, err := OpenWAL(path)
+		require.NoError(t, err)
+		p, err := NewPaxos(1, []int{1, 2, 3})
+		require.NoError(t, err)
+		durable, err := NewDurablePaxos(p, wal)
+		require.NoError(t, err)
+		return durable, wal
+	}
+
+	durable, wal := open()
+	durable.Propose(Value{1})
+	durable.Step(Message{From: 2, To: 1, Type: MessageAccept, Ballot: 3, Value: Value{3}})
+	require.NoError(t, durable.Err())
+	hash := durable.Hash()
+	require.NoError(t, wal.Close())
+
+	// partial write of the next record
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{10, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	recovered, wal := open()
+	defer wal.Close()
+	require.Equal(t, hash, recovered.Hash())
+
+	// acceptor must not promise a ballot lower than persisted one
+	out := recovered.Step(Message{From: 3, To: 1, Type: MessagePrepare, Ballot: 2})
+	require.Len(t, out, 1)
+	require.Equal(t, MessageReject, out[0].Type)
+	require.Equal(t, 3, out[0].Ballot)
+}
+
+func TestWALOversizedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.wal")
+	wal, err := OpenWAL(path)
+	require.NoError(t, err)
+	require.NoError(t, wal.Save([]byte{1, 2, 3}))
+	require.NoError(t, wal.Close())
+
+	// length of the torn record is garbage
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	wal, err = OpenWAL(path)
+	require.NoError(t, err)
+	defer wal.Close()
+	last, err := wal.Load()
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, last)
+}